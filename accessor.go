@@ -0,0 +1,63 @@
+package go_drain
+
+// Accessor runs operations against a recent configuration. If a reload swaps in
+// a newer version while an operation runs, the operation can be retried on the
+// newer version, giving "always operate on a recent config" semantics. Only use
+// it for idempotent operations, as they may run more than once
+type Accessor struct {
+	// drainer is where configurations are claimed from
+	drainer Drainer
+
+	// maxRetries is how many times an operation is retried on a newer version
+	maxRetries int
+}
+
+// currentVersioner is implemented by Drainers that can report the version Claim
+// currently hands out, such as *Drain
+type currentVersioner interface {
+	CurrentVersion() uint64
+}
+
+// NewAccessor creates an Accessor on drainer. Retries require a Drainer that
+// reports its current version, such as *Drain. With other Drainers, operations
+// are never retried
+// @param maxRetries is how many times an operation may be retried because a
+//   newer version was swapped in while it ran. 0 to never retry
+func NewAccessor(drainer Drainer, maxRetries int) *Accessor {
+	return &Accessor{
+		drainer:    drainer,
+		maxRetries: maxRetries,
+	}
+}
+
+// Do claims the current configuration, runs fn with it, and releases it. If a
+// newer version was swapped in while fn ran, fn is run again with the newer
+// version, up to the Accessor's max retries. Retries stop if the Drainer is
+// stopped
+// @param fn is the idempotent operation. It is given a configuration that is
+//   guaranteed to be non-nil
+// @return the error returned by the last run of fn, or the error returned by
+//   Claim if fn was never run
+func (a *Accessor) Do(fn func(config interface{}) error) (err error) {
+	versioner, canRetry := a.drainer.(currentVersioner)
+	for attempt := 0; ; attempt++ {
+		cc, claimErr := a.drainer.Claim()
+		if claimErr != nil {
+			if attempt == 0 {
+				return claimErr
+			}
+			// stopped while retrying, fn already ran
+			return
+		}
+		err = fn(cc.Config())
+		version := cc.Version()
+		a.drainer.Release(&cc)
+		if !canRetry || attempt >= a.maxRetries {
+			return
+		}
+		if current := versioner.CurrentVersion(); current == 0 || current == version {
+			// stopped or nothing newer
+			return
+		}
+	}
+}
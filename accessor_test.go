@@ -0,0 +1,70 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAccessor_Do(t *testing.T) {
+	loaded := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAccessor(d, 1)
+
+	seen := make([]string, 0)
+	err = a.Do(func(config interface{}) error {
+		seen = append(seen, config.(*myConfig).name)
+		if len(seen) == 1 {
+			// reload mid-access
+			_ = d.ReLoad()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(`expected no error, but got: `, err)
+	}
+	if fmt.Sprint(seen) != `[v1 v2]` {
+		t.Error(`expected a retry against the new config, but got: `, seen)
+	}
+
+	// retries are bounded
+	seen = seen[:0]
+	errBusy := errors.New(`busy`)
+	err = a.Do(func(config interface{}) error {
+		seen = append(seen, config.(*myConfig).name)
+		_ = d.ReLoad()
+		return errBusy
+	})
+	if err != errBusy {
+		t.Error(`expected the last error, but got: `, err)
+	}
+	if fmt.Sprint(seen) != `[v2 v3]` {
+		t.Error(`expected one retry, but got: `, seen)
+	}
+
+	// stopping aborts retries
+	seen = seen[:0]
+	err = a.Do(func(config interface{}) error {
+		seen = append(seen, config.(*myConfig).name)
+		_ = d.ReLoad()
+		d.Stop()
+		return nil
+	})
+	if err != nil || fmt.Sprint(seen) != `[v4]` {
+		t.Error(`expected no retry once stopped, but got: `, seen, err)
+	}
+	d.StopAndJoin()
+
+	if err = a.Do(func(config interface{}) error {
+		t.Error(`expected fn not to be called on a stopped drain`)
+		return nil
+	}); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+}
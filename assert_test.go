@@ -0,0 +1,18 @@
+package go_drain
+
+import "testing"
+
+// assertBalanced fails the test if the Drain has any claims that have not been
+// Released, as draintest.AssertBalanced does, which the tests of this package
+// can't import as draintest imports this package
+// @param t is the test to fail
+// @param d is the Drain to check
+func assertBalanced(t testing.TB, d *Drain) {
+	t.Helper()
+	if outstanding := d.TotalOutstandingClaims(); outstanding != 0 {
+		t.Errorf(`drain has %d outstanding claims, expected all claims to be released`, outstanding)
+	}
+	if pending := d.PendingCloses(); pending != 0 {
+		t.Errorf(`drain has %d pending closes, expected all claims to be released`, pending)
+	}
+}
@@ -0,0 +1,84 @@
+package go_drain
+
+// NewAsyncClose is New, but when a Release triggers the cleanup of a
+// configuration, the closer is queued to a background worker instead of being
+// called on the releasing go routine. This keeps slow closers off of
+// latency-sensitive request paths that Release at the end of each request.
+// Closes are performed one at a time in the order they were queued.
+// StopAndJoin waits for all queued closes to complete
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewAsyncClose(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.asyncClose = true
+	})
+}
+
+// queuedClose is a close waiting for the background worker
+type queuedClose struct {
+	// version is the version being closed
+	version uint64
+
+	// run performs the close
+	run func()
+}
+
+// releaseCloseConfig closes a configuration whose last claim was released. If
+// the Drain closes asynchronously, the close is queued instead. The close must
+// already be counted in pendingCloses, which is done once it completes
+//
+// Assumes that the d.mu is not locked
+// @param version is the version of configToClose
+// @param wait is true to wait for a queued close to complete before returning
+func (d *Drain) releaseCloseConfig(version uint64, configToClose interface{}, currentlyRunningConfig interface{}, reason CloseReason, wait bool) {
+	if !d.asyncClose {
+		d.closeConfig(version, configToClose, currentlyRunningConfig, reason)
+		d.pendingCloses.Done()
+		return
+	}
+	done := make(chan struct{})
+	d.enqueueClose(queuedClose{version: version, run: func() {
+		defer close(done)
+		d.closeConfig(version, configToClose, currentlyRunningConfig, reason)
+	}})
+	if wait {
+		<-done
+	}
+}
+
+// enqueueClose queues the close for the background workers, starting a worker if
+// fewer than the limit are running. The close must already be counted in pendingCloses
+func (d *Drain) enqueueClose(q queuedClose) {
+	d.closeQueueMu.Lock()
+	d.closeQueue = append(d.closeQueue, q)
+	start := d.closeWorkersRunning < d.closeWorkerLimit()
+	if start {
+		d.closeWorkersRunning++
+	}
+	d.closeQueueMu.Unlock()
+	if start {
+		go d.runCloseQueue()
+	}
+}
+
+// runCloseQueue performs queued closes until the queue is empty
+func (d *Drain) runCloseQueue() {
+	for {
+		d.closeQueueMu.Lock()
+		if len(d.closeQueue) == 0 {
+			d.closeWorkersRunning--
+			d.closeQueueMu.Unlock()
+			return
+		}
+		q := d.closeQueue[0]
+		d.closeQueue[0] = queuedClose{}
+		d.closeQueue = d.closeQueue[1:]
+		d.closeQueueMu.Unlock()
+
+		q.run()
+		d.pendingCloses.Done()
+	}
+}
@@ -0,0 +1,110 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewAsyncClose(t *testing.T) {
+	var mu sync.Mutex
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := NewAsyncClose(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+	start := time.Now()
+	d.Release(&held)
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Error(`expected Release to return before the slow closer, but took `, elapsed)
+	}
+
+	held, _ = d.Claim()
+	d.Stop()
+	d.Release(&held)
+	d.StopAndJoin()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(closed) != `[v1 v2]` {
+		t.Error(`expected all closes to complete before StopAndJoin returns, but got: `, closed)
+	}
+}
+
+func TestDrain_ReleaseAndWait(t *testing.T) {
+	var mu sync.Mutex
+	closed := 0
+	d, err := NewAsyncClose(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		closed++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+	d.ReleaseAndWait(&held)
+	mu.Lock()
+	if closed != 1 {
+		t.Error(`expected the close to complete before ReleaseAndWait returns, but closed `, closed)
+	}
+	mu.Unlock()
+	if held.Config() != nil {
+		t.Error(`expected the claim to be invalidated`)
+	}
+
+	// no close triggered, returns immediately
+	cc, _ := d.Claim()
+	d.ReleaseAndWait(&cc)
+	d.StopAndJoin()
+}
+
+func TestNewAsyncClose_ReleaseRacesStopAndJoin(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		var mu sync.Mutex
+		closed := 0
+		d, err := NewAsyncClose(func(currentConfig interface{}) (config interface{}, err error) {
+			return &myConfig{}, nil
+		}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			closed++
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// widen the gap between the release and queueing its close
+		d.SetOnVersionDrainComplete(func(version uint64, totalClaims uint64, drainDuration time.Duration) {
+			time.Sleep(5 * time.Millisecond)
+		})
+
+		held, _ := d.Claim()
+		_ = d.ReLoad()
+		go d.Release(&held)
+		d.StopAndJoin()
+
+		mu.Lock()
+		if closed != 2 {
+			t.Fatal(`expected StopAndJoin to wait for every close, but closed `, closed)
+		}
+		mu.Unlock()
+	}
+}
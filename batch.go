@@ -0,0 +1,37 @@
+package go_drain
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrInvalidBatchSize is returned by ClaimBatch when n is less than 1
+var ErrInvalidBatchSize = errors.New(`batch size must be at least 1`)
+
+// ClaimBatch claims the current configuration once on behalf of n units of
+// work, such as the items of a map-reduce job that must all see the same
+// version. The version stays claimed until every unit calls release, so it's
+// pinned without making n separate claims. Do not Release the returned claim
+// yourself, share it among the units and have each call release when done
+// @param n is how many units share the claim
+// @return cc the claim or an invalidated claim if there was an error
+// @return release is called once by each unit. The claim is Released on the nth
+//   call, and calls after that do nothing. It's safe to call from any go routine.
+//   nil if there was an error
+// @return err ErrInvalidBatchSize if n is less than 1, or the error returned by Claim
+func (d *Drain) ClaimBatch(n int) (cc ConfigClaim, release func(), err error) {
+	if n < 1 {
+		return cc, nil, ErrInvalidBatchSize
+	}
+	if cc, err = d.Claim(); err != nil {
+		return
+	}
+	held := cc
+	remaining := int64(n)
+	release = func() {
+		if atomic.AddInt64(&remaining, -1) == 0 {
+			d.Release(&held)
+		}
+	}
+	return
+}
@@ -0,0 +1,63 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestDrain_ClaimBatch(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 8
+	cc, release, err := d.ClaimBatch(workers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.TotalOutstandingClaims() != 1 {
+		t.Error(`expected a single claim for the batch, but got: `, d.TotalOutstandingClaims())
+	}
+	_ = d.ReLoad()
+
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers-1; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if cc.Config().(*myConfig).name != `v1` {
+				t.Error(`expected every unit to see v1, but got: `, cc.Config().(*myConfig).name)
+			}
+			release()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if len(closed) != 0 {
+		t.Error(`expected the version to stay pinned until the last unit is done, but closed: `, closed)
+	}
+	release()
+	if fmt.Sprint(closed) != `[v1]` {
+		t.Error(`expected the version to be closed after the last unit, but got: `, closed)
+	}
+	release()
+	if d.TotalOutstandingClaims() != 0 || d.PendingCloses() != 0 {
+		t.Error(`expected extra calls to release to do nothing`)
+	}
+
+	if _, _, err = d.ClaimBatch(0); err != ErrInvalidBatchSize {
+		t.Error(`expected ErrInvalidBatchSize, but got: `, err)
+	}
+	d.StopAndJoin()
+}
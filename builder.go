@@ -0,0 +1,250 @@
+package go_drain
+
+import (
+	"runtime"
+	"time"
+)
+
+// Builder constructs a Drain with any combination of options, as an
+// alternative to the New* constructors, which each enable a single option.
+// Create one with NewBuilder, chain the options, then call Build
+type Builder struct {
+	// loadAndTest builds and tests each configuration
+	loadAndTest LoadAndTesterFunc
+
+	// closer closes configurations, nil if there is nothing to close
+	closer CloserFunc
+
+	// validators are run in order on each configuration after loadAndTest succeeds
+	validators []func(config interface{}) error
+
+	// opts are applied to the Drain before its initial load
+	opts []drainOption
+
+	// fetchToken identifies the state of the source, nil to always reload, see NewConditional
+	fetchToken func() (string, error)
+
+	// err is the first invalid option, returned by Build
+	err error
+}
+
+// NewBuilder starts building a Drain that loads and tests configurations with loadAndTest
+// @param loadAndTest builds and tests each configuration, as with New
+func NewBuilder(loadAndTest LoadAndTesterFunc) *Builder {
+	return &Builder{loadAndTest: loadAndTest}
+}
+
+// Closer sets the closer, as with New. Without it, nothing is closed
+func (b *Builder) Closer(closer CloserFunc) *Builder {
+	b.closer = closer
+	return b
+}
+
+// ShutdownCloser sets a different closer for the configuration that is current at shutdown, see NewWithClosers
+func (b *Builder) ShutdownCloser(closer CloserFunc) *Builder {
+	return b.with(func(d *Drain) {
+		d.shutdownCloser = closer
+	})
+}
+
+// Validator adds a check that each configuration must pass after loadAndTest
+// succeeds. A failing configuration is declined and closed, just as if
+// loadAndTest had failed. Validators run in the order they are added
+func (b *Builder) Validator(validator func(config interface{}) error) *Builder {
+	b.validators = append(b.validators, validator)
+	return b
+}
+
+// SelfValidating validates configurations that implement Validator, see NewSelfValidating
+func (b *Builder) SelfValidating() *Builder {
+	return b.Validator(selfValidate)
+}
+
+// Warmup warms up each configuration before it's published, see NewWithWarmup
+func (b *Builder) Warmup(warmup WarmupFunc) *Builder {
+	return b.with(func(d *Drain) {
+		d.warmup = warmup
+	})
+}
+
+// Dedupe declines reloads that build a configuration equal to the current one, see NewWithDedupe
+func (b *Builder) Dedupe(equal func(a, b interface{}) bool) *Builder {
+	return b.with(func(d *Drain) {
+		d.equal = equal
+	})
+}
+
+// CloseTimeout abandons calls to the closer that take longer than timeout, see NewWithCloseTimeout
+func (b *Builder) CloseTimeout(timeout time.Duration) *Builder {
+	return b.with(func(d *Drain) {
+		d.closeTimeout = timeout
+	})
+}
+
+// AsyncClose closes released configurations in the background, see NewAsyncClose
+func (b *Builder) AsyncClose() *Builder {
+	return b.with(func(d *Drain) {
+		d.asyncClose = true
+	})
+}
+
+// Linger keeps drained versions open for linger before closing them, see NewWithLinger
+func (b *Builder) Linger(linger time.Duration) *Builder {
+	return b.with(func(d *Drain) {
+		d.linger = linger
+	})
+}
+
+// ClaimLimit bounds the number of outstanding claims, see NewWithClaimLimit.
+// Build returns ErrInvalidClaimLimit if limit is less than 1
+func (b *Builder) ClaimLimit(limit int) *Builder {
+	if limit < 1 && b.err == nil {
+		b.err = ErrInvalidClaimLimit
+	}
+	return b.with(func(d *Drain) {
+		d.claimSlots = make(chan struct{}, limit)
+	})
+}
+
+// ClaimSmoothing smooths the migration of claims to new versions, see
+// NewWithClaimSmoothing. Build returns ErrInvalidSmoothingRate if rate is less than 1
+func (b *Builder) ClaimSmoothing(rate int) *Builder {
+	if rate < 1 && b.err == nil {
+		b.err = ErrInvalidSmoothingRate
+	}
+	return b.with(func(d *Drain) {
+		d.smoothingRate = rate
+	})
+}
+
+// GoroutineTracking records the caller of every claim, see NewWithGoroutineTracking
+func (b *Builder) GoroutineTracking() *Builder {
+	return b.with(func(d *Drain) {
+		d.trackGoroutines = true
+		d.claimCallers = make(map[uint64]CallerInfo)
+	})
+}
+
+// ShardedClaims counts claims in shards to reduce contention, see NewWithShardedClaims
+func (b *Builder) ShardedClaims(shards int) *Builder {
+	if shards < 1 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	return b.with(func(d *Drain) {
+		d.claimShards = shards
+	})
+}
+
+// HoldWatchdog reports claims held longer than threshold, see NewWithHoldWatchdog
+func (b *Builder) HoldWatchdog(threshold time.Duration, onLongHold func(version uint64, age time.Duration, stack []byte)) *Builder {
+	return b.with(func(d *Drain) {
+		d.holdThreshold = threshold
+		d.onLongHold = onLongHold
+		d.holdWatches = make(map[uint64]Timer)
+	})
+}
+
+// Retention bounds the memory held by lingering versions, see SetRetentionSizer and Linger
+func (b *Builder) Retention(sizer func(config interface{}) int64, maxRetainedBytes int64) *Builder {
+	return b.with(func(d *Drain) {
+		d.retentionSizer = sizer
+		d.maxRetainedBytes = maxRetainedBytes
+	})
+}
+
+// Conditional only reloads when the source has changed, see NewConditional
+func (b *Builder) Conditional(fetchToken func() (string, error)) *Builder {
+	b.fetchToken = fetchToken
+	return b
+}
+
+// CloseWorkers closes released configurations in the background with workers
+// go routines, see NewWithCloseWorkers
+func (b *Builder) CloseWorkers(workers int) *Builder {
+	if workers < 1 {
+		workers = 1
+	}
+	return b.with(func(d *Drain) {
+		d.asyncClose = true
+		d.closeWorkers = workers
+	})
+}
+
+// CloseExecutor runs the closer with exec, see NewWithCloseExecutor
+func (b *Builder) CloseExecutor(exec func(func())) *Builder {
+	return b.with(func(d *Drain) {
+		d.closeExecutor = exec
+	})
+}
+
+// ClaimSampling samples the durations of the most recent claims, see NewWithClaimSampling
+func (b *Builder) ClaimSampling(size int) *Builder {
+	if size < 1 {
+		size = 1
+	}
+	return b.with(func(d *Drain) {
+		d.claimSamples = &claimSampleRing{slots: make([]claimSampleSlot, size)}
+	})
+}
+
+// OnReload sets the callback for the old and new configurations of each reload, see SetOnReloadConfigs
+func (b *Builder) OnReload(onReload func(oldConfig, newConfig interface{}, oldVersion, newVersion uint64)) *Builder {
+	return b.with(func(d *Drain) {
+		d.hooks.onReloadConfigs = onReload
+	})
+}
+
+// OnSuperseded sets the callback for versions superseded by a reload, see SetOnSuperseded
+func (b *Builder) OnSuperseded(onSuperseded func(version uint64)) *Builder {
+	return b.with(func(d *Drain) {
+		d.hooks.onSuperseded = onSuperseded
+	})
+}
+
+// OnReloadError sets the callback for failed reloads, see SetOnReloadError
+func (b *Builder) OnReloadError(onReloadError func(err error)) *Builder {
+	return b.with(func(d *Drain) {
+		d.hooks.onReloadError = onReloadError
+	})
+}
+
+// Build creates the Drain with the options and performs the initial load
+// @return c the Drain object or nil, if there was an error
+// @return err the error of an invalid option, or any errors encountered when
+//   fetching the source token, or loading, testing, or validating the config
+func (b *Builder) Build() (c *Drain, err error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	opts := b.opts
+	if fetchToken := b.fetchToken; fetchToken != nil {
+		token, err := protectedFetchToken(fetchToken)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(append([]drainOption{}, opts...), func(d *Drain) {
+			d.fetchToken = fetchToken
+			d.lastToken = token
+		})
+	}
+	if len(b.validators) != 0 {
+		validators := append([]func(config interface{}) error{}, b.validators...)
+		opts = append(append([]drainOption{}, opts...), func(d *Drain) {
+			d.validate = func(config interface{}) error {
+				for _, validator := range validators {
+					if err := validator(config); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		})
+	}
+	return newDrain(b.loadAndTest, b.closer, opts...)
+}
+
+// with adds an option to apply to the Drain
+func (b *Builder) with(opt drainOption) *Builder {
+	b.opts = append(b.opts, opt)
+	return b
+}
@@ -0,0 +1,137 @@
+package go_drain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	errInvalid := errors.New(`invalid`)
+	next := `v1`
+	replaced := make([]string, 0)
+	shutdown := make([]string, 0)
+	superseded := make([]uint64, 0)
+	reloadErrs := make([]error, 0)
+	d, err := NewBuilder(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: next}, nil
+	}).Closer(func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		replaced = append(replaced, configToClose.(*myConfig).name)
+	}).ShutdownCloser(func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		shutdown = append(shutdown, configToClose.(*myConfig).name)
+	}).Validator(func(config interface{}) error {
+		if config.(*myConfig).name == `bad` {
+			return errInvalid
+		}
+		return nil
+	}).Dedupe(func(a, b interface{}) bool {
+		return a.(*myConfig).name == b.(*myConfig).name
+	}).CloseTimeout(time.Second).OnSuperseded(func(version uint64) {
+		superseded = append(superseded, version)
+	}).OnReloadError(func(err error) {
+		reloadErrs = append(reloadErrs, err)
+	}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// validator
+	next = `bad`
+	if err = d.ReLoad(); err != errInvalid {
+		t.Error(`expected the validation error, but got: `, err)
+	}
+	// dedupe
+	next = `v1`
+	if err = d.ReLoad(); err != ErrNoChange {
+		t.Error(`expected ErrNoChange, but got: `, err)
+	}
+	next = `v2`
+	if err = d.ReLoad(); err != nil {
+		t.Error(`expected the reload to succeed, but got: `, err)
+	}
+	d.StopAndJoin()
+
+	if fmt.Sprint(replaced) != `[bad v1 v1]` || fmt.Sprint(shutdown) != `[v2]` {
+		t.Error(`expected the closers to be honored, but got: `, replaced, shutdown)
+	}
+	if fmt.Sprint(superseded) != `[1]` {
+		t.Error(`expected v1 to be superseded, but got: `, superseded)
+	}
+	if len(reloadErrs) != 1 || reloadErrs[0] != errInvalid {
+		t.Error(`expected the validation failure to be reported, but got: `, reloadErrs)
+	}
+}
+
+func TestBuilder_ClaimLimitAndLinger(t *testing.T) {
+	closed := make(chan string, 2)
+	defer close(closed)
+	d, err := NewBuilder(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v`}, nil
+	}).Closer(func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed <- configToClose.(*myConfig).name
+	}).ClaimLimit(1).Linger(time.Hour).SelfValidating().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, _ := d.Claim()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = d.ClaimContextLimited(ctx); err == nil {
+		t.Error(`expected the claim limit to be honored`)
+	}
+	d.Release(&held)
+	_ = d.ReLoad()
+	if ops := d.PendingOperations(); len(ops) != 1 || ops[0].Kind != OperationLinger {
+		t.Error(`expected the linger to be honored, but got: `, ops)
+	}
+	d.StopAndJoin()
+}
+
+func TestBuilder_LaterOptions(t *testing.T) {
+	etag := `"a"`
+	loaded := 0
+	closed := make([]string, 0)
+	executed := 0
+	reloads := make([]string, 0)
+	d, err := NewBuilder(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}).Closer(func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	}).Conditional(func() (string, error) {
+		return etag, nil
+	}).CloseExecutor(func(close func()) {
+		executed++
+		close()
+	}).ClaimSampling(4).Linger(time.Hour).Retention(func(config interface{}) int64 {
+		return 1
+	}, 0).OnReload(func(oldConfig, newConfig interface{}, oldVersion, newVersion uint64) {
+		reloads = append(reloads, fmt.Sprintf(`%s->%s`, oldConfig.(*myConfig).name, newConfig.(*myConfig).name))
+	}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// conditional
+	if err = d.ReLoad(); err != ErrNotModified {
+		t.Error(`expected ErrNotModified, but got: `, err)
+	}
+	etag = `"b"`
+	if err = d.ReLoad(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(reloads) != `[v1->v2]` {
+		t.Error(`expected the reload to be reported, but got: `, reloads)
+	}
+	// the lingering v1 is over the retention budget, so it closes early on the executor
+	if fmt.Sprint(closed) != `[v1]` || executed != 1 {
+		t.Error(`expected v1 to be closed by the executor, but got: `, closed, executed)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {})
+	if samples := d.RecentClaims(4); len(samples) != 1 {
+		t.Error(`expected the claim to be sampled, but got: `, samples)
+	}
+	d.StopAndJoin()
+}
@@ -0,0 +1,118 @@
+package go_drain
+
+import "sync"
+
+// goroutineCachedDrainer shares a claim per go routine, see NewGoroutineCachedDrainer
+type goroutineCachedDrainer struct {
+	// drainer is the underlying Drainer
+	drainer Drainer
+
+	// mu guards claims
+	mu sync.Mutex
+
+	// claims are the shared claims by go routine id
+	claims map[uint64]*cachedClaim
+}
+
+// cachedClaim is a claim on the underlying Drainer shared by one go routine
+type cachedClaim struct {
+	// claim is the claim on the underlying Drainer
+	claim ConfigClaim
+
+	// refs is how many times the go routine has claimed without releasing
+	refs int
+}
+
+// NewGoroutineCachedDrainer wraps a Drainer so that nested claims within a
+// single go routine share one claim on the underlying Drainer. This removes the
+// Claim and Release churn from hot loops that claim many times per unit of
+// work: claim once around the unit of work, and the claims made inside it get
+// the same configuration without claiming again.
+//
+// Claim and Release must be called from the same go routine, and Release is
+// still required for every Claim. The underlying claim is released with the
+// last Release, so a go routine's first Claim after that gets the latest
+// version, and an idle go routine never keeps an old version from closing.
+// Finding the calling go routine costs more than an uncontended Claim, so this
+// only pays off when the underlying claims are contended or costly, such as
+// with a claim limit or goroutine tracking
+// @param d is the Drainer to share claims from
+// @return the caching Drainer
+func NewGoroutineCachedDrainer(d Drainer) Drainer {
+	return &goroutineCachedDrainer{
+		drainer: d,
+		claims:  make(map[uint64]*cachedClaim),
+	}
+}
+
+// Claim returns the calling go routine's shared claim, claiming if it has none
+func (g *goroutineCachedDrainer) Claim() (ConfigClaim, error) {
+	id := currentGoroutineID()
+	g.mu.Lock()
+	if cached, ok := g.claims[id]; ok {
+		cached.refs++
+		cc := cached.claim
+		g.mu.Unlock()
+		return cc, nil
+	}
+	g.mu.Unlock()
+
+	// only this go routine adds its own entry, so nothing was cached in the meantime
+	cc, err := g.drainer.Claim()
+	if err != nil {
+		return cc, err
+	}
+	g.mu.Lock()
+	g.claims[id] = &cachedClaim{claim: cc, refs: 1}
+	g.mu.Unlock()
+	return cc, nil
+}
+
+// Release counts down the calling go routine's use of its shared claim,
+// releasing the underlying claim with the last Release
+func (g *goroutineCachedDrainer) Release(cc *ConfigClaim) {
+	if cc == nil || cc.version == 0 {
+		return
+	}
+	defer cc.Invalidate()
+	id := currentGoroutineID()
+	g.mu.Lock()
+	cached, ok := g.claims[id]
+	if !ok || cached.claim.version != cc.version {
+		g.mu.Unlock()
+		return
+	}
+	if cached.refs--; cached.refs > 0 {
+		g.mu.Unlock()
+		return
+	}
+	delete(g.claims, id)
+	g.mu.Unlock()
+	g.drainer.Release(&cached.claim)
+}
+
+// ClaimRelease is Claim and Release around closure
+func (g *goroutineCachedDrainer) ClaimRelease(closure func(currentlyRunningConfig interface{})) error {
+	cc, err := g.Claim()
+	if err != nil {
+		return err
+	}
+	defer g.Release(&cc)
+	closure(cc.Config())
+	return nil
+}
+
+// ReLoad reloads the underlying Drainer
+func (g *goroutineCachedDrainer) ReLoad() error {
+	return g.drainer.ReLoad()
+}
+
+// Stop stops the underlying Drainer
+func (g *goroutineCachedDrainer) Stop() {
+	g.drainer.Stop()
+}
+
+// StopAndJoin stops the underlying Drainer, waiting for all claims to be released
+func (g *goroutineCachedDrainer) StopAndJoin() {
+	g.drainer.StopAndJoin()
+}
@@ -0,0 +1,71 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewGoroutineCachedDrainer(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := NewGoroutineCachedDrainer(d)
+
+	outer, _ := cached.Claim()
+	_ = d.ReLoad()
+	for i := 0; i < 10; i++ {
+		cc, err := cached.Claim()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cc.Config() != outer.Config() {
+			t.Error(`expected the same config within a go routine`)
+		}
+		if outstanding := d.TotalOutstandingClaims(); outstanding != 1 {
+			t.Error(`expected the underlying drain to see one claim, but got `, outstanding)
+		}
+		cached.Release(&cc)
+	}
+
+	// the last Release releases the underlying claim, so v1 is not pinned
+	cached.Release(&outer)
+	if outstanding := d.TotalOutstandingClaims(); outstanding != 0 {
+		t.Error(`expected the underlying claim to be released, but got `, outstanding)
+	}
+	if fmt.Sprint(closed) != `[v1]` {
+		t.Error(`expected v1 to close once released, but got: `, closed)
+	}
+
+	// the next claim gets the latest version
+	cc, _ := cached.Claim()
+	if cc.Config().(*myConfig).name != `v2` {
+		t.Error(`expected a new claim on v2, but got: `, cc.Config().(*myConfig).name)
+	}
+
+	// a different go routine gets its own claim
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		other, _ := cached.Claim()
+		if outstanding := d.TotalOutstandingClaims(); outstanding != 2 {
+			t.Error(`expected each go routine to have a claim, but got `, outstanding)
+		}
+		cached.Release(&other)
+	}()
+	<-done
+	cached.Release(&cc)
+
+	cached.StopAndJoin()
+	assertBalanced(t, d)
+	if fmt.Sprint(closed) != `[v1 v2]` {
+		t.Error(`expected v2 to be closed on shutdown, but got: `, closed)
+	}
+}
@@ -0,0 +1,84 @@
+package go_drain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrGracePeriodExpired is returned by CancelOutstanding when claims are still
+// held once the grace period is over
+var ErrGracePeriodExpired = errors.New(`grace period expired with claims outstanding`)
+
+// ClaimCancelable is Claim, but registers onCancel to be called if
+// CancelOutstanding is called while the claim is held. The holder should
+// wrap up and Release the claim as soon as possible when onCancel is called
+// @param onCancel asks the holder to release the claim. It is called at most
+//   once, from the go routine calling CancelOutstanding, and must not block. If
+//   CancelOutstanding already ran, it's called at once, from this go routine
+// @return cc the claim or an invalidated claim if there was an error
+// @return err the error returned by Claim
+func (d *Drain) ClaimCancelable(onCancel func()) (cc ConfigClaim, err error) {
+	if cc, err = d.Claim(); err != nil {
+		return
+	}
+	d.mu.Lock()
+	if d.canceledOutstanding {
+		// CancelOutstanding ran between the claim and registering onCancel
+		d.mu.Unlock()
+		protect(CallbackSiteOnCancel, onCancel)
+		return
+	}
+	defer d.mu.Unlock()
+	if cc.id == 0 {
+		d.lastClaimID++
+		cc.id = d.lastClaimID
+	}
+	if d.cancelers == nil {
+		d.cancelers = make(map[uint64]func())
+	}
+	d.cancelers[cc.id] = onCancel
+	return
+}
+
+// CancelOutstanding is an emergency shutdown. It stops the Drain so that no new
+// claims are made, asks every holder of a claim made by ClaimCancelable to
+// release it by calling its onCancel, then gives the holders up to grace to
+// release their claims. If they do, it finishes as StopAndJoin does. Otherwise,
+// it gives up waiting so that the caller may escalate, such as by exiting. The
+// Drain stays stopped and closes the remaining versions as their claims are
+// released, and StopAndJoin may still be called to wait for them. Claims made
+// without ClaimCancelable are waited on, but cannot be asked to release
+// @param grace is how long to wait for claims to be released after they are canceled
+// @return ErrGracePeriodExpired if claims were still held after grace, nil if
+//   the Drain stopped and joined
+func (d *Drain) CancelOutstanding(grace time.Duration) error {
+	d.Stop()
+
+	d.mu.Lock()
+	d.canceledOutstanding = true
+	cancelers := make([]func(), 0, len(d.cancelers))
+	for id, onCancel := range d.cancelers {
+		cancelers = append(cancelers, onCancel)
+		delete(d.cancelers, id)
+	}
+	d.mu.Unlock()
+
+	for _, onCancel := range cancelers {
+		protect(CallbackSiteOnCancel, onCancel)
+	}
+
+	released := make(chan struct{})
+	go func() {
+		defer close(released)
+		d.closeWg.Wait()
+	}()
+	timer := d.clock().NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-released:
+	case <-timer.C():
+		return ErrGracePeriodExpired
+	}
+	d.StopAndJoin()
+	return nil
+}
@@ -0,0 +1,133 @@
+package go_drain
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrain_CancelOutstanding(t *testing.T) {
+	closed := int32(0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		atomic.AddInt32(&closed, 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const holders = 3
+	canceled := int32(0)
+	claimed := sync.WaitGroup{}
+	claimed.Add(holders)
+	for i := 0; i < holders; i++ {
+		go func() {
+			cancel := make(chan struct{})
+			cc, err := d.ClaimCancelable(func() {
+				atomic.AddInt32(&canceled, 1)
+				close(cancel)
+			})
+			claimed.Done()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			// long running work that stops when canceled
+			select {
+			case <-cancel:
+			case <-time.After(10 * time.Second):
+			}
+			d.Release(&cc)
+		}()
+	}
+	claimed.Wait()
+
+	// released claims are not canceled
+	cc, _ := d.ClaimCancelable(func() {
+		t.Error(`expected a released claim not to be canceled`)
+	})
+	d.Release(&cc)
+
+	start := time.Now()
+	if err = d.CancelOutstanding(5 * time.Second); err != nil {
+		t.Error(`expected every holder to release within the grace period, but got: `, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Error(`expected holders to be canceled promptly, but took `, elapsed)
+	}
+	if n := atomic.LoadInt32(&canceled); n != holders {
+		t.Error(`expected `, holders, ` cancel callbacks, but got `, n)
+	}
+	if n := atomic.LoadInt32(&closed); n != 1 {
+		t.Error(`expected the config to be closed once, but got `, n)
+	}
+	assertBalanced(t, d)
+}
+
+func TestDrain_CancelOutstanding_GracePeriodExpired(t *testing.T) {
+	closed := int32(0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		atomic.AddInt32(&closed, 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a holder that ignores cancellation
+	stubborn, _ := d.Claim()
+	if err = d.CancelOutstanding(10 * time.Millisecond); err != ErrGracePeriodExpired {
+		t.Error(`expected the grace period to expire, but got: `, err)
+	}
+	if n := atomic.LoadInt32(&closed); n != 0 {
+		t.Error(`expected the held config to stay open, but closed `, n)
+	}
+
+	// claimed before, but registered after, the cancellation
+	canceled := false
+	cc, _ := d.ClaimCancelable(func() {
+		canceled = true
+	})
+	if cc.Version() != 0 || canceled {
+		t.Error(`expected no claim from a stopped Drain, but got `, cc.Version(), canceled)
+	}
+
+	d.Release(&stubborn)
+	d.StopAndJoin()
+	if n := atomic.LoadInt32(&closed); n != 1 {
+		t.Error(`expected the config to be closed once released, but closed `, n)
+	}
+	assertBalanced(t, d)
+}
+
+func TestDrain_CancelOutstanding_Reset(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.CancelOutstanding(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled := false
+	cc, err := d.ClaimCancelable(func() {
+		canceled = true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canceled {
+		t.Error(`expected a claim made after Reset not to be canceled`)
+	}
+	d.Release(&cc)
+	d.StopAndJoin()
+	assertBalanced(t, d)
+}
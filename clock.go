@@ -0,0 +1,131 @@
+package go_drain
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for a Drain. Every feature that reads the time
+// or waits, such as lingering, close timeouts, the hold watchdog, and the
+// periodic monitors, goes through the Drain's Clock. Tests can install a fake
+// Clock with SetClock to advance time deterministically, and the default Clock
+// uses the time package, so it's compatible with testing/synctest
+type Clock interface {
+	// Now gets the current time
+	Now() time.Time
+
+	// NewTimer creates a Timer that sends the time on its channel after d
+	NewTimer(d time.Duration) Timer
+
+	// After waits for d, then sends the time on the returned channel
+	After(d time.Duration) <-chan time.Time
+
+	// AfterFunc waits for d, then calls f in its own go routine
+	// @return a Timer that can cancel the call. Its channel is nil
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event created by a Clock
+type Timer interface {
+	// C is the channel the time is sent on when the Timer fires
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing
+	// @return true if the call stopped the Timer, false if it already fired or was stopped
+	Stop() bool
+}
+
+// realClock is the Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{timer: time.NewTimer(d)}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{timer: time.AfterFunc(d, f)}
+}
+
+// realTimer is the Timer backed by a time.Timer
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// clockHolder wraps the Clock so that it can be stored in an atomic.Value,
+// which requires the same concrete type on every Store
+type clockHolder struct {
+	clock Clock
+}
+
+// SetClock sets the source of time for the Drain. Timers that are already
+// running keep the Clock they were started with, so set it right after
+// creating the Drain
+// @param clock is the Clock to use. Pass nil to use the time package
+func (d *Drain) SetClock(clock Clock) {
+	d.clockSource.Store(clockHolder{clock: clock})
+}
+
+// clock gets the Clock set by SetClock, or the one backed by the time package if none is set
+func (d *Drain) clock() Clock {
+	if h, ok := d.clockSource.Load().(clockHolder); ok && h.clock != nil {
+		return h.clock
+	}
+	return realClock{}
+}
+
+// now gets the current time from the Drain's Clock
+func (d *Drain) now() time.Time {
+	return d.clock().Now()
+}
+
+// since gets the time elapsed since t according to the Drain's Clock
+func (d *Drain) since(t time.Time) time.Duration {
+	return d.now().Sub(t)
+}
+
+// newTicker sends the time on ticks every interval, according to the Drain's
+// Clock, until stop is called. Ticks are dropped if the receiver falls behind
+// @param interval must be positive, as with time.NewTicker
+// @return ticks receives the time of each tick
+// @return stop halts the ticks. It's safe to call more than once
+func (d *Drain) newTicker(interval time.Duration) (ticks <-chan time.Time, stop func()) {
+	if interval <= 0 {
+		panic(errors.New(`non-positive interval for ticker`))
+	}
+	c := make(chan time.Time, 1)
+	halt := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case t := <-d.clock().After(interval):
+				select {
+				case c <- t:
+				default:
+				}
+			case <-halt:
+				return
+			}
+		}
+	}()
+	once := sync.Once{}
+	return c, func() {
+		once.Do(func() { close(halt) })
+	}
+}
@@ -0,0 +1,132 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a Timer of a fakeClock
+type fakeTimer struct {
+	clock *fakeClock
+	when  time.Time
+	c     chan time.Time
+	f     func()
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	return c.schedule(d, make(chan time.Time, 1), nil)
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.schedule(d, nil, f)
+}
+
+func (c *fakeClock) schedule(d time.Duration, ch chan time.Time, f func()) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), c: ch, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the time forward by d, firing the timers that are due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	due := make([]*fakeTimer, 0)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	now := c.now
+	c.mu.Unlock()
+	for _, t := range due {
+		if t.f != nil {
+			go t.f()
+		} else {
+			t.c <- now
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func TestDrain_SetClock(t *testing.T) {
+	clock := newFakeClock()
+	loaded := 0
+	closed := make(chan string, 2)
+	d, err := NewWithLinger(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed <- configToClose.(*myConfig).name
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetClock(clock)
+
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+	d.Release(&held)
+
+	// the linger only expires on the fake clock
+	clock.Advance(59 * time.Minute)
+	select {
+	case name := <-closed:
+		t.Error(`expected v1 to linger, but closed: `, name)
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Minute)
+	select {
+	case name := <-closed:
+		if name != `v1` {
+			t.Error(`expected v1 to be closed, but closed: `, name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal(`expected v1 to be closed once the linger expired`)
+	}
+	d.StopAndJoin()
+}
@@ -0,0 +1,37 @@
+package go_drain
+
+// NewWithCloseExecutor is New, but every call to closer is dispatched through
+// exec, for resources that must be closed on a particular go routine or OS
+// thread, such as CGO handles or GUI toolkits. exec might hand the function to
+// the task queue of a go routine pinned with runtime.LockOSThread.
+//
+// The Drain waits for exec to run the close before it continues, so closes
+// are still ordered as they would be without an executor. Because of this,
+// the go routine exec runs closes on must not Release claims, reload, or stop
+// this Drain, as that may wait on a close queued behind the current task
+// @param exec runs the function it's given, exactly once, on the go routine of
+//   your choosing
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithCloseExecutor(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	exec func(func()),
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.closeExecutor = exec
+	})
+}
+
+// executeCloser wraps closer so that it's run by closeExecutor
+// @return a CloserFunc that returns once closeExecutor has run closer
+func (d *Drain) executeCloser(closer CloserFunc) CloserFunc {
+	return func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		done := make(chan struct{})
+		d.closeExecutor(func() {
+			defer close(done)
+			protect(CallbackSiteCloser, func() { closer(configToClose, currentlyRunningConfig) })
+		})
+		<-done
+	}
+}
@@ -0,0 +1,56 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestNewWithCloseExecutor(t *testing.T) {
+	// a dedicated go routine that runs the tasks it's given in order
+	tasks := make(chan func())
+	executorID := make(chan uint64, 1)
+	go func() {
+		executorID <- currentGoroutineID()
+		for task := range tasks {
+			task()
+		}
+	}()
+	expectedID := <-executorID
+
+	loadCalled := 0
+	var mu sync.Mutex
+	closed := make([]string, 0)
+	d, err := NewWithCloseExecutor(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if id := currentGoroutineID(); id != expectedID {
+			t.Error(`expected the close to run on the executor, but it ran on: `, id)
+		}
+		closed = append(closed, configToClose.(*myConfig).name)
+	}, func(close func()) {
+		tasks <- close
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// closed by the reload
+	_ = d.ReLoad()
+	// closed by the release
+	claim, _ := d.Claim()
+	_ = d.ReLoad()
+	d.Release(&claim)
+	// closed by stopping
+	d.StopAndJoin()
+	close(tasks)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(closed) != `[v1 v2 v3]` {
+		t.Error(`expected every version to be closed in order, but got: `, closed)
+	}
+}
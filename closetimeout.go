@@ -0,0 +1,57 @@
+package go_drain
+
+import "time"
+
+// NewWithCloseTimeout is New, but each call to closer is abandoned if it runs
+// longer than timeout, so a misbehaving closer cannot block StopAndJoin or a
+// releasing go routine forever. The callback set by SetOnCloseTimeout is
+// notified of each abandoned close.
+//
+// An abandoned closer keeps running in its own go routine and whatever it was
+// stuck on, along with any resources it had yet to free, is leaked. This trades
+// a leak for a deadlock. If the closer eventually returns, nothing is leaked
+// @param timeout is how long each call to closer may run
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithCloseTimeout(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	timeout time.Duration,
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.closeTimeout = timeout
+	})
+}
+
+// SetOnCloseTimeout sets the callback that is notified when a call to closer
+// runs longer than the timeout given to NewWithCloseTimeout and is abandoned
+// @param onCloseTimeout receives the configuration that may not have been
+//   fully closed and why it was being closed. Pass nil to disable
+func (d *Drain) SetOnCloseTimeout(onCloseTimeout func(configToClose interface{}, reason CloseReason)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.onCloseTimeout = onCloseTimeout
+}
+
+// closeWithTimeout calls closer in a go routine and waits up to closeTimeout for it to return
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) closeWithTimeout(closer CloserFunc, configToClose interface{}, currentlyRunningConfig interface{}, reason CloseReason) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		protect(CallbackSiteCloser, func() { closer(configToClose, currentlyRunningConfig) })
+	}()
+	timer := d.clock().NewTimer(d.closeTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C():
+		d.mu.Lock()
+		onCloseTimeout := d.hooks.onCloseTimeout
+		d.mu.Unlock()
+		if onCloseTimeout != nil {
+			protect(CallbackSiteOnCloseTimeout, func() { onCloseTimeout(configToClose, reason) })
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package go_drain
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewWithCloseTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	d, err := NewWithCloseTimeout(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		// hang until the test ends
+		<-unblock
+	}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timedOutMu := sync.Mutex{}
+	timedOut := make([]CloseReason, 0)
+	d.SetOnCloseTimeout(func(configToClose interface{}, reason CloseReason) {
+		timedOutMu.Lock()
+		defer timedOutMu.Unlock()
+		timedOut = append(timedOut, reason)
+	})
+
+	returned := make(chan struct{})
+	go func() {
+		d.StopAndJoin()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal(`expected StopAndJoin to return despite the hanging closer`)
+	}
+	timedOutMu.Lock()
+	defer timedOutMu.Unlock()
+	if len(timedOut) != 1 || timedOut[0] != CloseReasonShutdown {
+		t.Error(`expected the shutdown close to time out, but got: `, timedOut)
+	}
+}
+
+func TestNewWithCloseTimeout_FastCloser(t *testing.T) {
+	closed := 0
+	d, err := NewWithCloseTimeout(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed++
+	}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetOnCloseTimeout(func(configToClose interface{}, reason CloseReason) {
+		t.Error(`expected the closer not to time out`)
+	})
+	_ = d.ReLoad()
+	d.StopAndJoin()
+	if closed != 2 {
+		t.Error(`expected both configs to be closed, but closed `, closed)
+	}
+}
@@ -0,0 +1,35 @@
+package go_drain
+
+// NewWithCloseWorkers is NewAsyncClose, but the queued closes are performed by
+// up to workers background go routines at a time. When many versions drain at
+// once and each closer is slow, such as when draining connection pools, this
+// tears down independent versions in parallel instead of one after another.
+//
+// Each version is still closed exactly once, but closes of different versions
+// may overlap and complete in any order. Use NewAsyncClose, which is one
+// worker, if closes must be performed in the order they were queued.
+// StopAndJoin waits for all queued closes to complete
+// @param workers is the most closes performed at a time. Less than 1 is treated as 1
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithCloseWorkers(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	workers int,
+) (c *Drain, err error) {
+	if workers < 1 {
+		workers = 1
+	}
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.asyncClose = true
+		d.closeWorkers = workers
+	})
+}
+
+// closeWorkerLimit gets the most background workers that may perform queued closes at a time
+func (d *Drain) closeWorkerLimit() int {
+	if d.closeWorkers < 1 {
+		return 1
+	}
+	return d.closeWorkers
+}
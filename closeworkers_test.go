@@ -0,0 +1,60 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewWithCloseWorkers(t *testing.T) {
+	const versions = 8
+	stopWith := func(workers int) (elapsed time.Duration, closed int) {
+		var mu sync.Mutex
+		loadCalled := 0
+		d, err := NewWithCloseWorkers(func(currentConfig interface{}) (config interface{}, err error) {
+			loadCalled++
+			return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+		}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			closed++
+		}, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		held := make([]ConfigClaim, 0, versions)
+		for i := 0; i < versions; i++ {
+			cc, _ := d.Claim()
+			held = append(held, cc)
+			if i < versions-1 {
+				_ = d.ReLoad()
+			}
+		}
+
+		start := time.Now()
+		d.Stop()
+		for i := range held {
+			d.Release(&held[i])
+		}
+		d.StopAndJoin()
+		elapsed = time.Since(start)
+
+		mu.Lock()
+		defer mu.Unlock()
+		return elapsed, closed
+	}
+
+	serial, closed := stopWith(1)
+	if closed != versions {
+		t.Error(`expected every version to be closed with one worker, but closed `, closed)
+	}
+	parallel, closed := stopWith(4)
+	if closed != versions {
+		t.Error(`expected every version to be closed with four workers, but closed `, closed)
+	}
+	if parallel >= serial {
+		t.Error(`expected four workers to stop faster than one, but took `, parallel, ` vs `, serial)
+	}
+}
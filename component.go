@@ -1,5 +1,11 @@
 package go_drain
 
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
 // ComponentOpenTestFunc creates the object from the configuration
 // @param buildingConfig is the configuration to use when creating
 //   this configuration. This will always be non-nil
@@ -49,15 +55,30 @@ type ComponentReloader interface {
 	Close(buildingConfig interface{})
 
 	// ShouldCopy compare the new and currentlyRunningConfig and if the old config value
-	// should be used, return true. To close the old one and create a new one, return false
+	// should be used, return true. To close the old one and create a new one, return false.
+	// Neither configuration is ever nil: when there is no running configuration, such
+	// as on the first build or at shutdown, ShouldCopy is not called and the component
+	// is never copied
 	ShouldCopy(buildingConfig interface{}, currentlyRunningConfig interface{}) bool
 
 	// Copy move the component from src to dst.
 	Copy(dst interface{}, src interface{})
 }
 
+// NamedComponent is a ComponentReloader with a name, used to identify it in
+// ComponentStats and by other component-level features
+type NamedComponent interface {
+	ComponentReloader
+
+	// Name identifies this component. It should be unique within a build order
+	Name() string
+}
+
 // baseComponent concretion used in NewDrainWithComponents
 type baseComponent struct {
+	// name identifies the component, empty if unnamed
+	name string
+
 	// openAndTestFunc function to call to create and test the new component, required
 	openAndTestFunc ComponentOpenTestFunc
 
@@ -86,34 +107,267 @@ type baseComponent struct {
 // @return Drainer object, ready for work or nil if error
 // @return error if there was an error building any of the components the first time, nil if no errors
 func NewDrainWithComponents(configBuilder ConfigurationBuilderFunc, buildOrder []ComponentReloader) (Drainer, error) {
-	return New(func(currentlyRunningConfig interface{}) (newConfig interface{}, err error) {
+	return NewComponentDrain(configBuilder, buildOrder)
+}
+
+// ValidateAllComponents opens and tests every component in buildOrder against a
+// configuration built by configBuilder, without creating a Drain. Unlike a
+// build, it does not stop at the first failure, so config-check tools can
+// report every broken component in one pass. Each component that opens
+// successfully is closed immediately, before the next one is opened
+// @param configBuilder builds the configuration the components are opened with
+// @param buildOrder are the components, opened in order
+// @return the error from each component's OpenAndTest, aligned to buildOrder,
+//   with nil for the components that opened. If configBuilder fails, no
+//   component is opened and its error is at every index
+func ValidateAllComponents(configBuilder ConfigurationBuilderFunc, buildOrder []ComponentReloader) []error {
+	errs := make([]error, len(buildOrder))
+	cfg, err := configBuilder()
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	for i, component := range buildOrder {
+		if errs[i] = openAndTestComponent(component, cfg); errs[i] == nil {
+			closeComponent(component, cfg)
+		}
+	}
+	return errs
+}
+
+// ComponentDrain is a Drain built from components by NewComponentDrain. It
+// adds component-level introspection on top of Drain
+type ComponentDrain struct {
+	*Drain
+
+	// cache holds closed components for re-use, nil to disable caching
+	cache *componentCache
+
+	// setMu guards set, counts, builtWith, instanceHolders, and lastInstance
+	setMu sync.Mutex
+
+	// set are the components the next reload builds, see SetBuildOrder
+	set *componentSet
+
+	// counts are the copy and rebuild counts by component name
+	counts map[string]*componentCounts
+
+	// builtWith maps live configurations to how they were built
+	builtWith map[interface{}]*componentBuild
+
+	// instanceHolders counts the live configurations that hold each component instance, see componentBuild
+	instanceHolders map[uint64]int
+
+	// lastInstance is the last component instance identifier handed out
+	lastInstance uint64
+}
+
+// ComponentCounts are how often a component was copied from the running
+// configuration or rebuilt during reloads
+type ComponentCounts struct {
+	// Copied is how many reloads copied the component forward
+	Copied uint64
+
+	// Rebuilt is how many reloads opened or re-used a new component
+	Rebuilt uint64
+}
+
+// componentCounts are the atomically updated counts behind ComponentCounts
+type componentCounts struct {
+	copied  uint64
+	rebuilt uint64
+}
+
+// NewComponentDrain is NewDrainWithComponents, but returns the ComponentDrain
+// for access to component-level features
+// @return ComponentDrain object, ready for work or nil if error
+// @return error if there was an error building any of the components the first time, nil if no errors
+func NewComponentDrain(configBuilder ConfigurationBuilderFunc, buildOrder []ComponentReloader) (*ComponentDrain, error) {
+	return newComponentDrain(configBuilder, buildOrder, nil, nil)
+}
+
+// ComponentStats gets how often each component was copied or rebuilt across
+// reloads, keyed by the component's name. Components that do not implement
+// NamedComponent, or have an empty name, are keyed by their index in the build
+// order. This reveals whether ShouldCopy is working: a component that never
+// copies when its settings do not change has a bug
+// @return the counts by component name
+func (c *ComponentDrain) ComponentStats() map[string]ComponentCounts {
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+	stats := make(map[string]ComponentCounts, len(c.counts))
+	for name, counts := range c.counts {
+		stats[name] = ComponentCounts{
+			Copied:  atomic.LoadUint64(&counts.copied),
+			Rebuilt: atomic.LoadUint64(&counts.rebuilt),
+		}
+	}
+	return stats
+}
+
+// newComponentDrain builds the ComponentDrain
+// @param cache holds closed components for re-use, nil to disable caching
+// @param closeOrder are the indices of buildOrder in the order components are
+//   closed, nil to close in reverse build order
+func newComponentDrain(configBuilder ConfigurationBuilderFunc, buildOrder []ComponentReloader, cache *componentCache, closeOrder []int) (*ComponentDrain, error) {
+	c := &ComponentDrain{
+		cache:           cache,
+		set:             newComponentSet(buildOrder, closeOrder),
+		counts:          make(map[string]*componentCounts, len(buildOrder)),
+		builtWith:       make(map[interface{}]*componentBuild),
+		instanceHolders: make(map[uint64]int),
+	}
+	for _, name := range c.set.names {
+		c.counts[name] = &componentCounts{}
+	}
+	d, err := New(func(currentlyRunningConfig interface{}) (newConfig interface{}, err error) {
 		cfg, err := configBuilder()
 		if err != nil {
 			// If there was an error with the builder, halt
 			return nil, err
 		}
-		for levelsBuilt := 0; levelsBuilt < len(buildOrder); levelsBuilt++ {
+		set := c.currentSet()
+		var runningSet *componentSet
+		var runningBuild *componentBuild
+		if currentlyRunningConfig != nil {
+			runningBuild = c.buildOf(currentlyRunningConfig)
+			runningSet = c.setOf(currentlyRunningConfig)
+		}
+		// shared are the instances of the components copied from the running configuration
+		shared := make(map[string]uint64)
+		// opened tracks which components were opened, rather than copied, by this build
+		opened := make([]bool, len(set.buildOrder))
+		for levelsBuilt, component := range set.buildOrder {
+			name := set.names[levelsBuilt]
 			// if already created and not changed, use that old configuration
-			if currentlyRunningConfig != nil && buildOrder[levelsBuilt].ShouldCopy(cfg, currentlyRunningConfig) {
-				buildOrder[levelsBuilt].Copy(cfg, currentlyRunningConfig)
+			// components new to the build order have nothing to copy
+			if runningSet != nil && runningSet.has(name) && shouldCopyComponent(component, cfg, currentlyRunningConfig) {
+				copyComponent(component, cfg, currentlyRunningConfig)
+				c.countCopied(name)
+				if runningBuild != nil {
+					shared[name] = runningBuild.instances[name]
+				}
+				continue
+			}
+			if cache.restore(name, component, cfg) {
+				// re-used a previously built component, it's owned by this configuration now
+				opened[levelsBuilt] = true
 			} else {
 				// if nothing running, or changed, create a new item
-				err = buildOrder[levelsBuilt].OpenAndTest(cfg)
+				err = openAndTestComponent(component, cfg)
 				if err != nil {
 					// error encountered when creating or testing this component
+					// close only what this build opened. Copied components are
+					// owned by the running configuration and the rest were never opened
+					closeOpened(cfg, set.buildOrder, set.closeOrder, opened)
 					return nil, err
 				}
+				opened[levelsBuilt] = true
+			}
+			if currentlyRunningConfig != nil {
+				c.countRebuilt(name)
 			}
 		}
+		c.rememberBuild(cfg, set, shared)
 		return cfg, nil
 	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
-		for i := len(buildOrder) - 1; i >= 0; i-- {
-			// no config is currently running, always close OR the config has changed, OK to close it
-			if currentlyRunningConfig == nil || !buildOrder[i].ShouldCopy(configToClose, currentlyRunningConfig) {
-				buildOrder[i].Close(configToClose)
+		if build, unshared := c.forgetBuild(configToClose); build != nil {
+			// close only the components that no other live configuration holds,
+			// such as an older version still claimed across RebuildComponent calls
+			for _, i := range build.set.closeOrder {
+				component, name := build.set.buildOrder[i], build.set.names[i]
+				if unshared[name] && (currentlyRunningConfig == nil || !cache.store(name, component, configToClose)) {
+					closeComponent(component, configToClose)
+				}
 			}
+		} else {
+			c.closeChanged(configToClose, currentlyRunningConfig)
+		}
+		if currentlyRunningConfig == nil {
+			// shutting down, nothing will be re-used
+			cache.flush()
 		}
 	})
+	if err != nil {
+		return nil, err
+	}
+	c.Drain = d
+	return c, nil
+}
+
+// closeChanged closes the components of configToClose that were not copied
+// into currentlyRunningConfig. It's used for configurations that cannot be
+// tracked by their build, such as ones that are not comparable
+func (c *ComponentDrain) closeChanged(configToClose interface{}, currentlyRunningConfig interface{}) {
+	set := c.setOf(configToClose)
+	var runningSet *componentSet
+	if currentlyRunningConfig != nil {
+		runningSet = c.setOf(currentlyRunningConfig)
+	}
+	for _, i := range set.closeOrder {
+		component, name := set.buildOrder[i], set.names[i]
+		// no config is currently running, always close OR the component was
+		// removed from the build order OR the config has changed, OK to close it
+		if runningSet == nil || !runningSet.has(name) || !shouldCopyComponent(component, configToClose, currentlyRunningConfig) {
+			if runningSet == nil || !c.cache.store(name, component, configToClose) {
+				closeComponent(component, configToClose)
+			}
+		}
+	}
+}
+
+// componentName gets the name of a component for reporting
+// @return the component's Name, or its index in the build order if it has none
+func componentName(component ComponentReloader, index int) string {
+	if named, ok := component.(NamedComponent); ok && named.Name() != `` {
+		return named.Name()
+	}
+	return strconv.Itoa(index)
+}
+
+// closeOpened closes, in close order, the components flagged in opened
+// @param cfg is the partially built configuration
+// @param buildOrder is the list of components
+// @param closeOrder are the indices of buildOrder in the order components are closed
+// @param opened is true at each index of buildOrder that was opened during this build
+func closeOpened(cfg interface{}, buildOrder []ComponentReloader, closeOrder []int, opened []bool) {
+	for _, i := range closeOrder {
+		if opened[i] {
+			closeComponent(buildOrder[i], cfg)
+		}
+	}
+}
+
+// openAndTestComponent calls OpenAndTest, failing with ErrCallbackPanicked if it panics and panics are recovered
+func openAndTestComponent(c ComponentReloader, buildingConfig interface{}) (err error) {
+	if protect(CallbackSiteOpenAndTest, func() { err = c.OpenAndTest(buildingConfig) }) {
+		err = ErrCallbackPanicked
+	}
+	return
+}
+
+// closeComponent calls Close, ignoring panics if panics are recovered
+func closeComponent(c ComponentReloader, buildingConfig interface{}) {
+	protect(CallbackSiteClose, func() { c.Close(buildingConfig) })
+}
+
+// shouldCopyComponent calls ShouldCopy, returning false if it panics and panics are recovered.
+// ShouldCopy is not called if either configuration is nil, as there is nothing to copy
+func shouldCopyComponent(c ComponentReloader, buildingConfig interface{}, currentlyRunningConfig interface{}) (shouldCopy bool) {
+	if buildingConfig == nil || currentlyRunningConfig == nil {
+		return false
+	}
+	if protect(CallbackSiteShouldCopy, func() { shouldCopy = c.ShouldCopy(buildingConfig, currentlyRunningConfig) }) {
+		shouldCopy = false
+	}
+	return
+}
+
+// copyComponent calls Copy, ignoring panics if panics are recovered
+func copyComponent(c ComponentReloader, dst interface{}, src interface{}) {
+	protect(CallbackSiteCopy, func() { c.Copy(dst, src) })
 }
 
 // NewAutoComponent creates a new component factory that allows the component-drain to build configs without much intervention on your behalf
@@ -134,6 +388,29 @@ func NewAutoComponent(
 	}
 }
 
+// NewNamedAutoComponent is NewAutoComponent, but with a name to identify the
+// component in ComponentStats and other component-level features
+// @param name identifies the component. It should be unique within a build order
+func NewNamedAutoComponent(
+	name string,
+	openAndTestFunc ComponentOpenTestFunc,
+	closeFunc ComponentCloseFunc,
+	shouldCopyFunc ComponentShouldCopyFunc,
+	copyFunc ComponentCopyFunc) NamedComponent {
+	return &baseComponent{
+		name:            name,
+		openAndTestFunc: openAndTestFunc,
+		closeFunc:       closeFunc,
+		shouldCopyFunc:  shouldCopyFunc,
+		copyFunc:        copyFunc,
+	}
+}
+
+// Name gets the name of the component, empty if it was not given one
+func (a *baseComponent) Name() string {
+	return a.name
+}
+
 // OpenAndTest is a pass-through to the function in the object
 func (a *baseComponent) OpenAndTest(buildingConfig interface{}) error {
 	return a.openAndTestFunc(buildingConfig)
@@ -161,3 +438,16 @@ func (a *baseComponent) Copy(dst interface{}, src interface{}) {
 		a.copyFunc(dst, src)
 	}
 }
+
+// SameBy builds a ComponentShouldCopyFunc that compares a single comparable value
+// extracted from each configuration. This avoids hand-writing comparisons such as
+// a.x == b.x && a.y == b.y, which are easy to get wrong as configurations grow
+// @param extract pulls the value that determines if the component changed out of
+//   a configuration. It is called with both the building and the currently running
+//   configuration
+// @return a ComponentShouldCopyFunc that returns true when the extracted values are equal
+func SameBy[T comparable](extract func(cfg interface{}) T) ComponentShouldCopyFunc {
+	return func(buildingConfig interface{}, currentlyRunningConfig interface{}) bool {
+		return extract(buildingConfig) == extract(currentlyRunningConfig)
+	}
+}
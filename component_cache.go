@@ -0,0 +1,192 @@
+package go_drain
+
+import (
+	"container/list"
+	"sync"
+)
+
+// ComponentFingerprintFunc identifies the settings a component was built with
+// @param cfg is the configuration containing the component's settings. This will always be non-nil
+// @return a string that is equal for any two configurations that would build identical components
+type ComponentFingerprintFunc func(cfg interface{}) string
+
+// FingerprintedComponent is a ComponentReloader that can identify the settings
+// it was built with. Components that implement it can be re-used from the cache
+// of a Drain created with NewDrainWithComponentCache
+type FingerprintedComponent interface {
+	ComponentReloader
+
+	// Fingerprint identifies the settings this component is built with in cfg
+	Fingerprint(cfg interface{}) string
+}
+
+// fingerprintedComponent adds a fingerprint to baseComponent
+type fingerprintedComponent struct {
+	baseComponent
+
+	// fingerprintFunc identifies the settings a component was built with
+	fingerprintFunc ComponentFingerprintFunc
+}
+
+// NewFingerprintedAutoComponent is NewAutoComponent, but with a fingerprint so
+// that it may be re-used from the cache of a Drain created with NewDrainWithComponentCache.
+// copyFunc is required to re-use components from the cache
+// @param fingerprintFunc identifies the settings the component was built with
+func NewFingerprintedAutoComponent(
+	openAndTestFunc ComponentOpenTestFunc,
+	closeFunc ComponentCloseFunc,
+	shouldCopyFunc ComponentShouldCopyFunc,
+	copyFunc ComponentCopyFunc,
+	fingerprintFunc ComponentFingerprintFunc) FingerprintedComponent {
+	return &fingerprintedComponent{
+		baseComponent: baseComponent{
+			openAndTestFunc: openAndTestFunc,
+			closeFunc:       closeFunc,
+			shouldCopyFunc:  shouldCopyFunc,
+			copyFunc:        copyFunc,
+		},
+		fingerprintFunc: fingerprintFunc,
+	}
+}
+
+// Fingerprint is a pass-through to the function in the object
+func (f *fingerprintedComponent) Fingerprint(cfg interface{}) string {
+	return f.fingerprintFunc(cfg)
+}
+
+// NewDrainWithComponentCache is NewDrainWithComponents, but components that
+// would be closed are kept open in a least-recently-used cache instead. When a
+// later reload needs a component with the same fingerprint, such as when a
+// setting toggles from A to B and back to A, the cached component is copied into
+// the new configuration instead of being rebuilt. Only components that implement
+// FingerprintedComponent are cached. Components evicted from the cache and all
+// cached components at shutdown are closed
+// @param cacheSize is the maximum number of components kept in the cache
+// @return ComponentDrain object, ready for work or nil if error
+// @return error if there was an error building any of the components the first time, nil if no errors
+func NewDrainWithComponentCache(configBuilder ConfigurationBuilderFunc, buildOrder []ComponentReloader, cacheSize int) (*ComponentDrain, error) {
+	return newComponentDrain(configBuilder, buildOrder, newComponentCache(cacheSize), nil)
+}
+
+// componentCacheKey identifies a cached component
+type componentCacheKey struct {
+	// name identifies the component, see componentName
+	name string
+
+	// fingerprint identifies the settings the component was built with
+	fingerprint string
+}
+
+// componentCacheEntry is a component kept open for re-use
+type componentCacheEntry struct {
+	key componentCacheKey
+
+	// component is the ComponentReloader that built it
+	component ComponentReloader
+
+	// cfg is the retired configuration that holds the component
+	cfg interface{}
+}
+
+// componentCache is a least-recently-used cache of open components. A nil
+// componentCache caches nothing
+type componentCache struct {
+	// mu guards lru and entries
+	mu sync.Mutex
+
+	// size is the maximum number of entries
+	size int
+
+	// lru holds *componentCacheEntry, most recently stored at the front
+	lru *list.List
+
+	// entries are the elements of lru by key
+	entries map[componentCacheKey]*list.Element
+}
+
+// newComponentCache creates an empty cache of up to size components
+func newComponentCache(size int) *componentCache {
+	return &componentCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[componentCacheKey]*list.Element),
+	}
+}
+
+// store keeps the component in cfg open in the cache, closing the least
+// recently used components if the cache is full
+// @return true if the component was cached and must not be closed, false if it was not
+func (c *componentCache) store(name string, component ComponentReloader, cfg interface{}) bool {
+	if c == nil || c.size <= 0 {
+		return false
+	}
+	f, ok := component.(FingerprintedComponent)
+	if !ok {
+		return false
+	}
+	key := componentCacheKey{name: name, fingerprint: f.Fingerprint(cfg)}
+	evicted := make([]*componentCacheEntry, 0)
+	c.mu.Lock()
+	if e, exists := c.entries[key]; exists {
+		// an identical component is already cached, keep the newest one
+		evicted = append(evicted, c.lru.Remove(e).(*componentCacheEntry))
+		delete(c.entries, key)
+	}
+	c.entries[key] = c.lru.PushFront(&componentCacheEntry{key: key, component: component, cfg: cfg})
+	for c.lru.Len() > c.size {
+		entry := c.lru.Remove(c.lru.Back()).(*componentCacheEntry)
+		delete(c.entries, entry.key)
+		evicted = append(evicted, entry)
+	}
+	c.mu.Unlock()
+
+	for _, entry := range evicted {
+		closeComponent(entry.component, entry.cfg)
+	}
+	return true
+}
+
+// restore copies a cached component with the same fingerprint into cfg and
+// removes it from the cache, as cfg now owns it
+// @return true if a component was restored, false if it needs to be built
+func (c *componentCache) restore(name string, component ComponentReloader, cfg interface{}) bool {
+	if c == nil {
+		return false
+	}
+	f, ok := component.(FingerprintedComponent)
+	if !ok {
+		return false
+	}
+	key := componentCacheKey{name: name, fingerprint: f.Fingerprint(cfg)}
+	c.mu.Lock()
+	e, exists := c.entries[key]
+	if !exists {
+		c.mu.Unlock()
+		return false
+	}
+	entry := c.lru.Remove(e).(*componentCacheEntry)
+	delete(c.entries, key)
+	c.mu.Unlock()
+
+	copyComponent(component, cfg, entry.cfg)
+	return true
+}
+
+// flush closes every cached component
+func (c *componentCache) flush() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	entries := make([]*componentCacheEntry, 0, c.lru.Len())
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		entries = append(entries, e.Value.(*componentCacheEntry))
+	}
+	c.lru.Init()
+	c.entries = make(map[componentCacheKey]*list.Element)
+	c.mu.Unlock()
+
+	for _, entry := range entries {
+		closeComponent(entry.component, entry.cfg)
+	}
+}
@@ -0,0 +1,64 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewDrainWithComponentCache(t *testing.T) {
+	setting := `A`
+	opened := make([]string, 0)
+	closed := make([]string, 0)
+	d, err := NewDrainWithComponentCache(func() (interface{}, error) {
+		return &omniConfig{dbConfig: setting}, nil
+	}, []ComponentReloader{
+		NewFingerprintedAutoComponent(func(buildingConfig interface{}) error {
+			cfg := buildingConfig.(*omniConfig)
+			cfg.dbComp = fmt.Sprintf(`db-%s-%d`, cfg.dbConfig, len(opened))
+			opened = append(opened, cfg.dbComp)
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, buildingConfig.(*omniConfig).dbComp)
+		}, SameBy(func(c interface{}) string {
+			return c.(*omniConfig).dbConfig
+		}), func(dst interface{}, src interface{}) {
+			dst.(*omniConfig).dbComp = src.(*omniConfig).dbComp
+		}, func(cfg interface{}) string {
+			return cfg.(*omniConfig).dbConfig
+		}),
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A -> B caches the A build
+	setting = `B`
+	_ = d.ReLoad()
+	// B -> A re-uses the A build and caches the B build
+	setting = `A`
+	_ = d.ReLoad()
+
+	if fmt.Sprint(opened) != `[db-A-0 db-B-1]` {
+		t.Error(`expected A to be built once, but got: `, opened)
+	}
+	if len(closed) != 0 {
+		t.Error(`expected nothing to be closed while cached, but got: `, closed)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig.(*omniConfig).dbComp != `db-A-0` {
+			t.Error(`expected the cached A build, but got: `, currentlyRunningConfig.(*omniConfig).dbComp)
+		}
+	})
+
+	// A -> C caches A, evicting B
+	setting = `C`
+	_ = d.ReLoad()
+	if fmt.Sprint(closed) != `[db-B-1]` {
+		t.Error(`expected B to be evicted and closed, but got: `, closed)
+	}
+
+	d.StopAndJoin()
+	if fmt.Sprint(closed) != `[db-B-1 db-C-2 db-A-0]` {
+		t.Error(`expected every build to be closed exactly once, but got: `, closed)
+	}
+}
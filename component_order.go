@@ -0,0 +1,55 @@
+package go_drain
+
+import "errors"
+
+// ErrInvalidCloseOrder is returned by NewDrainWithComponentsCloseOrder and
+// SetBuildOrder when closeOrder is not a permutation of the indices of buildOrder
+var ErrInvalidCloseOrder = errors.New(`close order must contain each component index exactly once`)
+
+// NewDrainWithComponentsCloseOrder is NewDrainWithComponents, but components
+// are closed in closeOrder rather than in reverse build order, such as to stop
+// an HTTP server from accepting new work before anything else is torn down,
+// wherever it was built. closeOrder applies everywhere components are closed:
+// when a version is drained, at shutdown, and when a build fails part way
+// @param closeOrder are the indices of buildOrder in the order the components
+//   are closed. It must contain each index exactly once
+// @return Drainer object, ready for work or nil if error
+// @return error ErrInvalidCloseOrder if closeOrder is not a permutation of the
+//   indices of buildOrder, or if there was an error building any of the
+//   components the first time, nil if no errors
+func NewDrainWithComponentsCloseOrder(configBuilder ConfigurationBuilderFunc, buildOrder []ComponentReloader, closeOrder []int) (Drainer, error) {
+	if !isPermutation(closeOrder, len(buildOrder)) {
+		return nil, ErrInvalidCloseOrder
+	}
+	order := make([]int, len(closeOrder))
+	copy(order, closeOrder)
+	c, err := newComponentDrain(configBuilder, buildOrder, nil, order)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// isPermutation is true if order contains each of 0 through n-1 exactly once
+func isPermutation(order []int, n int) bool {
+	if len(order) != n {
+		return false
+	}
+	seen := make([]bool, n)
+	for _, i := range order {
+		if i < 0 || i >= n || seen[i] {
+			return false
+		}
+		seen[i] = true
+	}
+	return true
+}
+
+// reverseBuildOrder is the default close order, the indices of a build order of n components from last to first
+func reverseBuildOrder(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = n - 1 - i
+	}
+	return order
+}
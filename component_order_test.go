@@ -0,0 +1,106 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewDrainWithComponentsCloseOrder(t *testing.T) {
+	failAt := -1
+	closed := make([]int, 0)
+	newComponent := func(index int) ComponentReloader {
+		return NewAutoComponent(func(buildingConfig interface{}) error {
+			if index == failAt {
+				return errors.New(`open failed`)
+			}
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, index)
+		}, nil, nil)
+	}
+	buildOrder := []ComponentReloader{newComponent(0), newComponent(1), newComponent(2), newComponent(3)}
+	builder := func() (interface{}, error) {
+		return &omniConfig{}, nil
+	}
+
+	// the server, built last, closes first, then the rest in build order
+	d, err := NewDrainWithComponentsCloseOrder(builder, buildOrder, []int{3, 0, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = d.ReLoad()
+	if fmt.Sprint(closed) != `[3 0 1 2]` {
+		t.Error(`expected the replaced version to close in close order, but got: `, closed)
+	}
+
+	// a failed build closes what it opened in close order
+	closed = closed[:0]
+	failAt = 3
+	if err = d.ReLoad(); err == nil {
+		t.Error(`expected the reload to fail`)
+	}
+	if fmt.Sprint(closed) != `[0 1 2]` {
+		t.Error(`expected the opened components to close in close order, but got: `, closed)
+	}
+
+	closed = closed[:0]
+	d.StopAndJoin()
+	if fmt.Sprint(closed) != `[3 0 1 2]` {
+		t.Error(`expected shutdown to close in close order, but got: `, closed)
+	}
+}
+
+func TestNewDrainWithComponentsCloseOrder_Invalid(t *testing.T) {
+	buildOrder := []ComponentReloader{
+		NewAutoComponent(func(buildingConfig interface{}) error { return nil }, nil, nil, nil),
+		NewAutoComponent(func(buildingConfig interface{}) error { return nil }, nil, nil, nil),
+	}
+	builder := func() (interface{}, error) {
+		return &omniConfig{}, nil
+	}
+	for _, closeOrder := range [][]int{nil, {0}, {0, 0}, {0, 2}, {-1, 1}, {0, 1, 2}} {
+		d, err := NewDrainWithComponentsCloseOrder(builder, buildOrder, closeOrder)
+		if err != ErrInvalidCloseOrder || d != nil {
+			t.Error(`expected ErrInvalidCloseOrder for `, closeOrder, `, but got: `, d, err)
+		}
+	}
+}
+
+func TestComponentDrain_SetBuildOrderCloseOrder(t *testing.T) {
+	closed := make([]int, 0)
+	newComponent := func(index int) ComponentReloader {
+		return NewAutoComponent(func(buildingConfig interface{}) error {
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, index)
+		}, nil, nil)
+	}
+	buildOrder := []ComponentReloader{newComponent(0), newComponent(1), newComponent(2)}
+	d, err := NewDrainWithComponentsCloseOrder(func() (interface{}, error) {
+		return &omniConfig{}, nil
+	}, buildOrder, []int{2, 0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.(*ComponentDrain)
+
+	if err = c.SetBuildOrder(buildOrder, []int{0}); err != ErrInvalidCloseOrder {
+		t.Error(`expected ErrInvalidCloseOrder, but got: `, err)
+	}
+	if err = c.SetBuildOrder(buildOrder, []int{1, 2, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the replaced version keeps the close order it was built with
+	_ = d.ReLoad()
+	if fmt.Sprint(closed) != `[2 0 1]` {
+		t.Error(`expected the replaced version to close in its close order, but got: `, closed)
+	}
+
+	closed = closed[:0]
+	d.StopAndJoin()
+	if fmt.Sprint(closed) != `[1 2 0]` {
+		t.Error(`expected shutdown to close in the new close order, but got: `, closed)
+	}
+}
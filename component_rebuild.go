@@ -0,0 +1,82 @@
+package go_drain
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrUnknownComponent is returned by RebuildComponent when no component has the given name
+var ErrUnknownComponent = errors.New(`unknown component`)
+
+// ErrConfigNotCopyable is returned by RebuildComponent when the configuration is not a pointer to a struct
+var ErrConfigNotCopyable = errors.New(`configuration is not a pointer to a struct`)
+
+// RebuildComponent reloads a single component without rebuilding the rest of
+// the configuration, such as to rotate just a TLS certificate. A new version is
+// published from a shallow copy of the current configuration, to which mutate
+// is applied to change the named component's settings. Every other component
+// is copied forward, regardless of ShouldCopy, and the named component is
+// always reopened, even if its settings did not change. When the old version
+// is drained, only the named component is closed. A component is never closed
+// while another live version, such as an older one that is still claimed,
+// holds it.
+//
+// The configuration built by ConfigurationBuilderFunc must be a pointer to a
+// struct so that it can be copied. The configuration builder is not called
+// @param name identifies the component, as in ComponentStats
+// @param mutate changes the copy of the configuration before the component is
+//   reopened. Pass nil to reopen the component with the same settings
+// @return ErrUnknownComponent if no component has the name,
+//   ErrConfigNotCopyable if the configuration cannot be copied, or any error
+//   from reloading, such as the error from the component's OpenAndTest
+func (c *ComponentDrain) RebuildComponent(name string, mutate func(cfg interface{})) error {
+	if !c.currentSet().has(name) {
+		return ErrUnknownComponent
+	}
+	return c.reLoad(reloadOptions{load: func(currentlyRunningConfig interface{}) (newConfig interface{}, err error) {
+		// the running configuration keeps the components it was built with, see SetBuildOrder
+		set, runningBuild := c.setOf(currentlyRunningConfig), c.buildOf(currentlyRunningConfig)
+		index, ok := set.indexes[name]
+		if !ok {
+			return nil, ErrUnknownComponent
+		}
+		cfg, ok := shallowCopy(currentlyRunningConfig)
+		if !ok {
+			return nil, ErrConfigNotCopyable
+		}
+		if mutate != nil {
+			mutate(cfg)
+		}
+		// shared are the instances of the components copied from the running configuration
+		shared := make(map[string]uint64)
+		for i, component := range set.buildOrder {
+			if i == index {
+				continue
+			}
+			copyComponent(component, cfg, currentlyRunningConfig)
+			c.countCopied(set.names[i])
+			if runningBuild != nil {
+				shared[set.names[i]] = runningBuild.instances[set.names[i]]
+			}
+		}
+		if err = openAndTestComponent(set.buildOrder[index], cfg); err != nil {
+			return nil, err
+		}
+		c.countRebuilt(name)
+		c.rememberBuild(cfg, set, shared)
+		return cfg, nil
+	}})
+}
+
+// shallowCopy copies the struct that cfg points to
+// @return the pointer to the copy
+// @return ok is false if cfg is not a pointer to a struct
+func shallowCopy(cfg interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, false
+	}
+	cp := reflect.New(v.Elem().Type())
+	cp.Elem().Set(v.Elem())
+	return cp.Interface(), true
+}
@@ -0,0 +1,134 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestComponentDrain_RebuildComponent(t *testing.T) {
+	source := omniConfig{
+		dbConfig:     "db",
+		serverConfig: "cert1",
+	}
+	opened := 0
+	closed := make([]string, 0)
+	d, err := NewComponentDrain(func() (interface{}, error) {
+		x := source
+		return &x, nil
+	}, []ComponentReloader{
+		NewNamedAutoComponent(`db`, func(buildingConfig interface{}) error {
+			opened++
+			buildingConfig.(*omniConfig).dbComp = fmt.Sprintf(`%s#%d`, buildingConfig.(*omniConfig).dbConfig, opened)
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, buildingConfig.(*omniConfig).dbComp)
+		}, SameBy(func(c interface{}) string {
+			return c.(*omniConfig).dbConfig
+		}), func(dst interface{}, src interface{}) {
+			dst.(*omniConfig).dbComp = src.(*omniConfig).dbComp
+		}),
+		NewNamedAutoComponent(`tls`, func(buildingConfig interface{}) error {
+			opened++
+			buildingConfig.(*omniConfig).serverComp = fmt.Sprintf(`%s#%d`, buildingConfig.(*omniConfig).serverConfig, opened)
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, buildingConfig.(*omniConfig).serverComp)
+		}, SameBy(func(c interface{}) string {
+			return c.(*omniConfig).serverConfig
+		}), func(dst interface{}, src interface{}) {
+			dst.(*omniConfig).serverComp = src.(*omniConfig).serverComp
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate the cert without changing its settings
+	if err = d.RebuildComponent(`tls`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(closed) != `[cert1#2]` {
+		t.Error(`expected only the old tls component to be closed, but got: `, closed)
+	}
+	if err = d.RebuildComponent(`tls`, func(cfg interface{}) {
+		cfg.(*omniConfig).serverConfig = `cert2`
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(closed) != `[cert1#2 cert1#3]` {
+		t.Error(`expected only the old tls component to be closed, but got: `, closed)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		cfg := currentlyRunningConfig.(*omniConfig)
+		if cfg.dbComp != `db#1` || cfg.serverComp != `cert2#4` {
+			t.Error(`expected the db to be copied and tls to be reopened, but got: `, cfg.dbComp, cfg.serverComp)
+		}
+	})
+	stats := d.ComponentStats()
+	if stats[`db`] != (ComponentCounts{Copied: 2}) || stats[`tls`] != (ComponentCounts{Rebuilt: 2}) {
+		t.Error(`expected db to be copied and tls rebuilt, but got: `, stats)
+	}
+
+	if err = d.RebuildComponent(`cache`, nil); err != ErrUnknownComponent {
+		t.Error(`expected ErrUnknownComponent, but got: `, err)
+	}
+	d.StopAndJoin()
+	if fmt.Sprint(closed) != `[cert1#2 cert1#3 cert2#4 db#1]` {
+		t.Error(`expected the remaining components to be closed at shutdown, but got: `, closed)
+	}
+}
+
+func TestComponentDrain_RebuildComponentWhileOldVersionHeld(t *testing.T) {
+	source := omniConfig{
+		dbConfig:     "db",
+		serverConfig: "cert1",
+	}
+	opened := 0
+	closed := make([]string, 0)
+	d, err := NewComponentDrain(func() (interface{}, error) {
+		x := source
+		return &x, nil
+	}, []ComponentReloader{
+		NewNamedAutoComponent(`db`, func(buildingConfig interface{}) error {
+			opened++
+			buildingConfig.(*omniConfig).dbComp = fmt.Sprintf(`%s#%d`, buildingConfig.(*omniConfig).dbConfig, opened)
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, buildingConfig.(*omniConfig).dbComp)
+		}, nil, func(dst interface{}, src interface{}) {
+			dst.(*omniConfig).dbComp = src.(*omniConfig).dbComp
+		}),
+		NewNamedAutoComponent(`tls`, func(buildingConfig interface{}) error {
+			opened++
+			buildingConfig.(*omniConfig).serverComp = fmt.Sprintf(`%s#%d`, buildingConfig.(*omniConfig).serverConfig, opened)
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, buildingConfig.(*omniConfig).serverComp)
+		}, nil, func(dst interface{}, src interface{}) {
+			dst.(*omniConfig).serverComp = src.(*omniConfig).serverComp
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, _ := d.Claim()
+	if err = d.RebuildComponent(`tls`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.RebuildComponent(`db`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 0 {
+		t.Error(`expected nothing to be closed while v1 holds the original components, but got: `, closed)
+	}
+
+	d.Release(&held)
+	if fmt.Sprint(closed) != `[cert1#2 db#1]` {
+		t.Error(`expected the original components of v1 to be closed once, but got: `, closed)
+	}
+	d.StopAndJoin()
+	if fmt.Sprint(closed) != `[cert1#2 db#1 cert1#3 db#4]` {
+		t.Error(`expected the rebuilt components to be closed at shutdown, but got: `, closed)
+	}
+}
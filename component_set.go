@@ -0,0 +1,188 @@
+package go_drain
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// componentSet is a build order along with what the ComponentDrain derives from it
+type componentSet struct {
+	// buildOrder are the components in the order they are built
+	buildOrder []ComponentReloader
+
+	// names are the names of the components in buildOrder, see componentName
+	names []string
+
+	// closeOrder are the indices of buildOrder in the order components are closed
+	closeOrder []int
+
+	// indexes are the indices of buildOrder by name
+	indexes map[string]int
+}
+
+// newComponentSet creates the componentSet of buildOrder
+// @param closeOrder are the indices of buildOrder in the order components are
+//   closed, nil to close in reverse build order
+func newComponentSet(buildOrder []ComponentReloader, closeOrder []int) *componentSet {
+	if closeOrder == nil {
+		closeOrder = reverseBuildOrder(len(buildOrder))
+	}
+	s := &componentSet{
+		buildOrder: buildOrder,
+		names:      make([]string, len(buildOrder)),
+		closeOrder: closeOrder,
+		indexes:    make(map[string]int, len(buildOrder)),
+	}
+	for i, component := range buildOrder {
+		s.names[i] = componentName(component, i)
+		s.indexes[s.names[i]] = i
+	}
+	return s
+}
+
+// has is true if the set has a component with the name
+func (s *componentSet) has(name string) bool {
+	_, ok := s.indexes[name]
+	return ok
+}
+
+// SetBuildOrder replaces the components that subsequent reloads build, such as
+// when configuration enables a new subsystem. Components are matched to those
+// of the running configuration by name, see NamedComponent. On the next reload,
+// components that are new to the build order are opened, components that are in
+// both may be copied forward as usual, and components that were removed are
+// closed along with the old version. The running configuration is not changed
+// until then. Components that do not implement NamedComponent are matched by
+// their position in the build order. Configurations built from buildOrder close
+// their components in closeOrder, see NewDrainWithComponentsCloseOrder, while
+// those already built keep the close order they were built with
+// @param buildOrder are the components to build, in order
+// @param closeOrder are the indices of buildOrder in the order the components
+//   are closed. nil closes them in reverse build order, even if the Drain was
+//   created with a close order
+// @return ErrInvalidCloseOrder if closeOrder is not nil and not a permutation
+//   of the indices of buildOrder, nil otherwise
+func (c *ComponentDrain) SetBuildOrder(buildOrder []ComponentReloader, closeOrder []int) error {
+	var order []int
+	if closeOrder != nil {
+		if !isPermutation(closeOrder, len(buildOrder)) {
+			return ErrInvalidCloseOrder
+		}
+		order = make([]int, len(closeOrder))
+		copy(order, closeOrder)
+	}
+	set := newComponentSet(buildOrder, order)
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+	c.set = set
+	for _, name := range set.names {
+		if _, ok := c.counts[name]; !ok {
+			c.counts[name] = &componentCounts{}
+		}
+	}
+	return nil
+}
+
+// currentSet gets the componentSet that the next reload builds
+func (c *ComponentDrain) currentSet() *componentSet {
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+	return c.set
+}
+
+// countsOf gets the counts of the named component
+func (c *ComponentDrain) countsOf(name string) *componentCounts {
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+	return c.counts[name]
+}
+
+// countCopied counts a component copied forward by a reload
+func (c *ComponentDrain) countCopied(name string) {
+	atomic.AddUint64(&c.countsOf(name).copied, 1)
+}
+
+// countRebuilt counts a component opened or re-used by a reload
+func (c *ComponentDrain) countRebuilt(name string) {
+	atomic.AddUint64(&c.countsOf(name).rebuilt, 1)
+}
+
+// componentBuild is how a live configuration was built
+type componentBuild struct {
+	// set are the components the configuration was built from
+	set *componentSet
+
+	// instances identify the open component in the configuration by component
+	// name. Configurations that copied a component share its instance
+	instances map[string]uint64
+}
+
+// rememberBuild records that cfg was built from set. Its components that are
+// shared with other configurations are counted as another holder of the
+// instance, and the rest are new instances
+// @param shared are the instances of the components copied into cfg, by name
+func (c *ComponentDrain) rememberBuild(cfg interface{}, set *componentSet, shared map[string]uint64) {
+	if !reflect.TypeOf(cfg).Comparable() {
+		return
+	}
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+	build := &componentBuild{set: set, instances: make(map[string]uint64, len(set.names))}
+	for _, name := range set.names {
+		instance, ok := shared[name]
+		if !ok {
+			c.lastInstance++
+			instance = c.lastInstance
+		}
+		build.instances[name] = instance
+		c.instanceHolders[instance]++
+	}
+	c.builtWith[cfg] = build
+}
+
+// buildOf gets how cfg was built
+// @return the build or nil if cfg is unknown, such as when it's not comparable
+func (c *ComponentDrain) buildOf(cfg interface{}) *componentBuild {
+	if cfg == nil || !reflect.TypeOf(cfg).Comparable() {
+		return nil
+	}
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+	return c.builtWith[cfg]
+}
+
+// setOf gets the componentSet that cfg was built from
+// @return the set cfg was built from, or the current set if it's unknown
+func (c *ComponentDrain) setOf(cfg interface{}) *componentSet {
+	if build := c.buildOf(cfg); build != nil {
+		return build.set
+	}
+	return c.currentSet()
+}
+
+// forgetBuild forgets cfg, as it's being closed, and releases its hold on its
+// component instances
+// @return the build of cfg or nil if cfg is unknown
+// @return unshared are the names of the components of cfg that no other live
+//   configuration holds, and so must be closed
+func (c *ComponentDrain) forgetBuild(cfg interface{}) (build *componentBuild, unshared map[string]bool) {
+	if cfg == nil || !reflect.TypeOf(cfg).Comparable() {
+		return nil, nil
+	}
+	c.setMu.Lock()
+	defer c.setMu.Unlock()
+	build, ok := c.builtWith[cfg]
+	if !ok {
+		return nil, nil
+	}
+	delete(c.builtWith, cfg)
+	unshared = make(map[string]bool, len(build.instances))
+	for name, instance := range build.instances {
+		c.instanceHolders[instance]--
+		if c.instanceHolders[instance] <= 0 {
+			delete(c.instanceHolders, instance)
+			unshared[name] = true
+		}
+	}
+	return build, unshared
+}
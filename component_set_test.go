@@ -0,0 +1,86 @@
+package go_drain
+
+import (
+	"testing"
+)
+
+func TestComponentDrain_SetBuildOrder(t *testing.T) {
+	opened := make(map[string]int)
+	closed := make(map[string]int)
+	db := NewNamedAutoComponent(`db`, func(buildingConfig interface{}) error {
+		opened[`db`]++
+		buildingConfig.(*omniConfig).dbComp = "db"
+		return nil
+	}, func(buildingConfig interface{}) {
+		closed[`db`]++
+	}, SameBy(func(c interface{}) string {
+		return c.(*omniConfig).dbConfig
+	}), func(dst interface{}, src interface{}) {
+		dst.(*omniConfig).dbComp = src.(*omniConfig).dbComp
+	})
+	server := NewNamedAutoComponent(`server`, func(buildingConfig interface{}) error {
+		opened[`server`]++
+		buildingConfig.(*omniConfig).serverComp = "server"
+		return nil
+	}, func(buildingConfig interface{}) {
+		closed[`server`]++
+	}, SameBy(func(c interface{}) string {
+		return c.(*omniConfig).serverConfig
+	}), func(dst interface{}, src interface{}) {
+		dst.(*omniConfig).serverComp = src.(*omniConfig).serverComp
+	})
+
+	d, err := NewComponentDrain(func() (interface{}, error) {
+		return &omniConfig{dbConfig: "og", serverConfig: "og"}, nil
+	}, []ComponentReloader{db})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.SetBuildOrder([]ComponentReloader{db, server}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if opened[`server`] != 0 {
+		t.Error(`expected the new component to wait for a reload`)
+	}
+	if err = d.ReLoad(); err != nil {
+		t.Fatal(err)
+	}
+	if cc, err := d.Claim(); err == nil {
+		if c := cc.Config().(*omniConfig); c.dbComp != "db" || c.serverComp != "server" {
+			t.Error(`expected both components in the new configuration, got `, *c)
+		}
+		d.Release(&cc)
+	}
+	if opened[`db`] != 1 || closed[`db`] != 0 {
+		t.Error(`expected db to be copied, got opened `, opened[`db`], ` closed `, closed[`db`])
+	}
+	if opened[`server`] != 1 {
+		t.Error(`expected server to be built, got opened `, opened[`server`])
+	}
+	stats := d.ComponentStats()
+	if stats[`db`] != (ComponentCounts{Copied: 1}) {
+		t.Error(`expected db to be copied once, got `, stats[`db`])
+	}
+	if stats[`server`] != (ComponentCounts{Rebuilt: 1}) {
+		t.Error(`expected server to be built once, got `, stats[`server`])
+	}
+
+	if err = d.SetBuildOrder([]ComponentReloader{server}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.ReLoad(); err != nil {
+		t.Fatal(err)
+	}
+	if closed[`db`] != 1 {
+		t.Error(`expected the removed component to be closed, got closed `, closed[`db`])
+	}
+	if opened[`server`] != 1 || closed[`server`] != 0 {
+		t.Error(`expected server to be copied, got opened `, opened[`server`], ` closed `, closed[`server`])
+	}
+
+	d.StopAndJoin()
+	if closed[`db`] != 1 || closed[`server`] != 1 {
+		t.Error(`expected each component to be closed once, got `, closed)
+	}
+}
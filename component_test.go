@@ -1,6 +1,7 @@
 package go_drain
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -120,3 +121,277 @@ func TestNewAuto(t *testing.T) {
 		t.Error(`expected close methods to be called `, 5, ` times but was `, closeDidRun)
 	}
 }
+
+func TestSameBy(t *testing.T) {
+	shouldCopy := SameBy(func(c interface{}) string { return c.(*omniConfig).dbConfig })
+
+	if !shouldCopy(&omniConfig{dbConfig: "og"}, &omniConfig{dbConfig: "og"}) {
+		t.Error(`expected equal extracted values to copy`)
+	}
+	if shouldCopy(&omniConfig{dbConfig: "upd"}, &omniConfig{dbConfig: "og"}) {
+		t.Error(`expected different extracted values to rebuild`)
+	}
+}
+
+func TestSameBy_DrivesCopyAndRebuild(t *testing.T) {
+	copyFromConfig := omniConfig{
+		dbConfig:     "og",
+		serverConfig: "og",
+	}
+	opened := 0
+
+	d, err := NewDrainWithComponents(func() (interface{}, error) {
+		x := copyFromConfig
+		return &x, nil
+	}, []ComponentReloader{
+		NewAutoComponent(func(buildingConfig interface{}) error {
+			opened++
+			buildingConfig.(*omniConfig).dbComp = fmt.Sprintf(`running-db-%s`, buildingConfig.(*omniConfig).dbConfig)
+			return nil
+		}, nil, SameBy(func(c interface{}) string {
+			return c.(*omniConfig).dbConfig
+		}), func(dst interface{}, src interface{}) {
+			dst.(*omniConfig).dbComp = src.(*omniConfig).dbComp
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unchanged value, should copy
+	copyFromConfig.serverConfig = "upd"
+	_ = d.ReLoad()
+	if opened != 1 {
+		t.Error(`expected component to be copied, but it was opened `, opened, ` times`)
+	}
+
+	// changed value, should rebuild
+	copyFromConfig.dbConfig = "upd"
+	_ = d.ReLoad()
+	if opened != 2 {
+		t.Error(`expected component to be rebuilt, but it was opened `, opened, ` times`)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig.(*omniConfig).dbComp != `running-db-upd` {
+			t.Error(`expected dbComp to be rebuilt`)
+		}
+	})
+	d.StopAndJoin()
+}
+
+func TestNewDrainWithComponents_MidBuildFailure(t *testing.T) {
+	failAt := -1
+	opened := make([]int, 0)
+	closed := make([]int, 0)
+	newComponent := func(index int) ComponentReloader {
+		return NewAutoComponent(func(buildingConfig interface{}) error {
+			if index == failAt {
+				return errors.New(`open failed`)
+			}
+			opened = append(opened, index)
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, index)
+		}, nil, nil)
+	}
+
+	// fail the first build
+	failAt = 1
+	_, err := NewDrainWithComponents(func() (interface{}, error) {
+		return &omniConfig{}, nil
+	}, []ComponentReloader{newComponent(0), newComponent(1), newComponent(2)})
+	if err == nil {
+		t.Fatal(`expected the build to fail`)
+	}
+	if fmt.Sprint(opened) != `[0]` {
+		t.Error(`expected only index 0 to be opened, but got: `, opened)
+	}
+	if fmt.Sprint(closed) != `[0]` {
+		t.Error(`expected only index 0 to be closed, but got: `, closed)
+	}
+}
+
+func TestValidateAllComponents(t *testing.T) {
+	opened := make([]int, 0)
+	closed := make([]int, 0)
+	newComponent := func(index int, openErr error) ComponentReloader {
+		return NewAutoComponent(func(buildingConfig interface{}) error {
+			opened = append(opened, index)
+			return openErr
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, index)
+		}, nil, nil)
+	}
+	errDB := errors.New(`db unreachable`)
+	errServer := errors.New(`port in use`)
+
+	errs := ValidateAllComponents(func() (interface{}, error) {
+		return &omniConfig{}, nil
+	}, []ComponentReloader{newComponent(0, errDB), newComponent(1, nil), newComponent(2, errServer)})
+	if len(errs) != 3 || errs[0] != errDB || errs[1] != nil || errs[2] != errServer {
+		t.Error(`expected both failures aligned to their components, but got: `, errs)
+	}
+	if fmt.Sprint(opened) != `[0 1 2]` {
+		t.Error(`expected every component to be opened, but got: `, opened)
+	}
+	if fmt.Sprint(closed) != `[1]` {
+		t.Error(`expected only the opened component to be closed, but got: `, closed)
+	}
+
+	// the configuration cannot be built
+	errBuild := errors.New(`bad file`)
+	errs = ValidateAllComponents(func() (interface{}, error) {
+		return nil, errBuild
+	}, []ComponentReloader{newComponent(0, nil), newComponent(1, nil)})
+	if fmt.Sprint(errs) != `[bad file bad file]` {
+		t.Error(`expected the build error for every component, but got: `, errs)
+	}
+}
+
+func TestNewDrainWithComponents_MidBuildFailureKeepsCopied(t *testing.T) {
+	failReload := false
+	closed := make([]string, 0)
+	d, err := NewDrainWithComponents(func() (interface{}, error) {
+		return &omniConfig{}, nil
+	}, []ComponentReloader{
+		// copied forward on reload
+		NewAutoComponent(func(buildingConfig interface{}) error {
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, `copied`)
+		}, func(buildingConfig interface{}, currentlyRunningConfig interface{}) bool {
+			return true
+		}, func(dst interface{}, src interface{}) {}),
+		// re-opened on reload
+		NewAutoComponent(func(buildingConfig interface{}) error {
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, `opened`)
+		}, nil, nil),
+		// fails on reload
+		NewAutoComponent(func(buildingConfig interface{}) error {
+			if failReload {
+				return errors.New(`open failed`)
+			}
+			return nil
+		}, func(buildingConfig interface{}) {
+			closed = append(closed, `failed`)
+		}, nil, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failReload = true
+	if err = d.ReLoad(); err == nil {
+		t.Error(`expected the reload to fail`)
+	}
+	if fmt.Sprint(closed) != `[opened]` {
+		t.Error(`expected only the component opened by the failed reload to be closed, but got: `, closed)
+	}
+	d.StopAndJoin()
+}
+
+func TestComponentDrain_ComponentStats(t *testing.T) {
+	copyFromConfig := omniConfig{
+		dbConfig:        "og",
+		invariantConfig: "og",
+	}
+
+	d, err := NewComponentDrain(func() (interface{}, error) {
+		x := copyFromConfig
+		return &x, nil
+	}, []ComponentReloader{
+		NewNamedAutoComponent(`db`, func(buildingConfig interface{}) error {
+			return nil
+		}, nil, SameBy(func(c interface{}) string {
+			return c.(*omniConfig).dbConfig
+		}), func(dst interface{}, src interface{}) {
+			dst.(*omniConfig).dbComp = src.(*omniConfig).dbComp
+		}),
+		NewNamedAutoComponent(`invariant`, func(buildingConfig interface{}) error {
+			return nil
+		}, nil, SameBy(func(c interface{}) string {
+			return c.(*omniConfig).invariantConfig
+		}), func(dst interface{}, src interface{}) {
+			dst.(*omniConfig).invariantComp = src.(*omniConfig).invariantComp
+		}),
+		NewAutoComponent(func(buildingConfig interface{}) error {
+			return nil
+		}, nil, nil, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copyFromConfig.dbConfig = "upd1"
+	_ = d.ReLoad()
+	copyFromConfig.dbConfig = "upd2"
+	_ = d.ReLoad()
+
+	stats := d.ComponentStats()
+	if stats[`db`] != (ComponentCounts{Rebuilt: 2}) {
+		t.Error(`expected db to be rebuilt twice, got `, stats[`db`])
+	}
+	if stats[`invariant`] != (ComponentCounts{Copied: 2}) {
+		t.Error(`expected invariant to be copied twice, got `, stats[`invariant`])
+	}
+	if stats[`2`] != (ComponentCounts{Rebuilt: 2}) {
+		t.Error(`expected unnamed component to be keyed by index and rebuilt twice, got `, stats[`2`])
+	}
+	d.StopAndJoin()
+}
+
+// strictComponent is a ComponentReloader that fails the test if ShouldCopy gets a nil configuration
+type strictComponent struct {
+	t      *testing.T
+	closed int
+}
+
+func (c *strictComponent) OpenAndTest(buildingConfig interface{}) error {
+	buildingConfig.(*omniConfig).dbComp = buildingConfig.(*omniConfig).dbConfig
+	return nil
+}
+
+func (c *strictComponent) Close(buildingConfig interface{}) {
+	c.closed++
+}
+
+func (c *strictComponent) ShouldCopy(buildingConfig interface{}, currentlyRunningConfig interface{}) bool {
+	if buildingConfig == nil || currentlyRunningConfig == nil {
+		c.t.Error(`expected ShouldCopy never to get a nil config`)
+		return false
+	}
+	return buildingConfig.(*omniConfig).dbConfig == currentlyRunningConfig.(*omniConfig).dbConfig
+}
+
+func (c *strictComponent) Copy(dst interface{}, src interface{}) {
+	dst.(*omniConfig).dbComp = src.(*omniConfig).dbComp
+}
+
+func TestNewDrainWithComponents_ShouldCopyNeverGetsNil(t *testing.T) {
+	dbConfig := `db1`
+	component := &strictComponent{t: t}
+	d, err := NewDrainWithComponents(func() (buildingConfig interface{}, err error) {
+		return &omniConfig{dbConfig: dbConfig}, nil
+	}, []ComponentReloader{component})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// copied forward
+	_ = d.ReLoad()
+	// rebuilt while the old version is claimed, so it's closed on release
+	held, _ := d.Claim()
+	dbConfig = `db2`
+	_ = d.ReLoad()
+	d.Release(&held)
+	// claimed through shutdown, so it's closed with no running config
+	held, _ = d.Claim()
+	d.Stop()
+	d.Release(&held)
+	d.StopAndJoin()
+	if component.closed != 2 {
+		t.Error(`expected the db1 and db2 components to be closed, but got: `, component.closed)
+	}
+}
@@ -0,0 +1,58 @@
+package go_drain
+
+// NewTypedComponent is NewNamedAutoComponent for configurations of type *T. The
+// casts from interface{} are done once here, rather than in every function of
+// every component in a build order
+// @param name identifies the component. It should be unique within a build order
+// @param open opens and tests the component in the building configuration
+// @param close closes the component in a configuration. nil to do nothing
+// @param same is true if the component in the building configuration would be
+//   the same as in the currently running configuration, in which case it is
+//   copied with copy rather than opened. nil to always open
+// @param copy copies the component from src to dst. nil to always open
+func NewTypedComponent[T any](
+	name string,
+	open func(cfg *T) error,
+	close func(cfg *T),
+	same func(new, cur *T) bool,
+	copy func(dst, src *T),
+) NamedComponent {
+	c := &baseComponent{
+		name: name,
+		openAndTestFunc: func(buildingConfig interface{}) error {
+			return open(buildingConfig.(*T))
+		},
+	}
+	if close != nil {
+		c.closeFunc = func(buildingConfig interface{}) {
+			close(buildingConfig.(*T))
+		}
+	}
+	if same != nil {
+		c.shouldCopyFunc = func(buildingConfig interface{}, currentlyRunningConfig interface{}) bool {
+			return same(buildingConfig.(*T), currentlyRunningConfig.(*T))
+		}
+	}
+	if copy != nil {
+		c.copyFunc = func(dst interface{}, src interface{}) {
+			copy(dst.(*T), src.(*T))
+		}
+	}
+	return c
+}
+
+// NewDrainWithTypedComponents is NewComponentDrain for configurations of type
+// *T, for use with components created by NewTypedComponent. Claims of the
+// resulting Drain hold a *T
+// @param configBuilder builds the base configuration that the components are added to
+// @return ComponentDrain object, ready for work or nil if error
+// @return error if there was an error building any of the components the first time, nil if no errors
+func NewDrainWithTypedComponents[T any](configBuilder func() (*T, error), buildOrder []ComponentReloader) (*ComponentDrain, error) {
+	return NewComponentDrain(func() (interface{}, error) {
+		cfg, err := configBuilder()
+		if err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}, buildOrder)
+}
@@ -0,0 +1,86 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewTypedComponent(t *testing.T) {
+	copyFromConfig := omniConfig{
+		dbConfig:        "og",
+		serverConfig:    "og",
+		invariantConfig: "og",
+	}
+
+	closeDidRun := 0
+
+	d, err := NewDrainWithTypedComponents(func() (*omniConfig, error) {
+		x := copyFromConfig
+		return &x, nil
+	}, []ComponentReloader{
+		// DATABASE
+		NewTypedComponent(`db`, func(cfg *omniConfig) error {
+			cfg.dbComp = fmt.Sprintf(`running-db-%s`, cfg.dbConfig)
+			return nil
+		}, func(cfg *omniConfig) {
+			closeDidRun++
+			cfg.dbComp = `closed`
+		}, func(new, cur *omniConfig) bool {
+			return new.dbConfig == cur.dbConfig
+		}, func(dst, src *omniConfig) {
+			dst.dbComp = src.dbComp
+		}),
+		// SERVER
+		NewTypedComponent(`server`, func(cfg *omniConfig) error {
+			cfg.serverComp = fmt.Sprintf(`running-server-%s`, cfg.serverConfig)
+			return nil
+		}, func(cfg *omniConfig) {
+			closeDidRun++
+			cfg.serverComp = `closed`
+		}, func(new, cur *omniConfig) bool {
+			return new.serverConfig == cur.serverConfig
+		}, func(dst, src *omniConfig) {
+			dst.serverComp = src.serverComp
+		}),
+		// SOMETHING ELSE THAT ALWAYS REBUILDS
+		NewTypedComponent(`invariant`, func(cfg *omniConfig) error {
+			cfg.invariantComp = fmt.Sprintf(`running-invariant-%s`, cfg.invariantConfig)
+			return nil
+		}, func(cfg *omniConfig) {
+			closeDidRun++
+			cfg.invariantComp = `closed`
+		}, nil, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copyFromConfig.serverConfig = "upd"
+	copyFromConfig.invariantConfig = "upd"
+	_ = d.ReLoad()
+	if closeDidRun != 2 {
+		t.Error(`expected close methods to be called `, 2, ` times but was `, closeDidRun)
+	}
+
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		cfg := currentlyRunningConfig.(*omniConfig)
+		if cfg.dbComp != `running-db-og` {
+			t.Error(`dbComp configuration was updated`)
+		}
+		if cfg.serverComp != `running-server-upd` {
+			t.Error(`serverComp configuration was not updated`)
+		}
+		if cfg.invariantComp != `running-invariant-upd` {
+			t.Error(`invariantComp configuration was not updated`)
+		}
+	})
+	stats := d.ComponentStats()
+	if stats[`db`].Copied != 1 || stats[`server`].Rebuilt != 1 || stats[`invariant`].Rebuilt != 1 {
+		t.Error(`expected the component names to be reported, but got: `, stats)
+	}
+
+	d.StopAndJoin()
+	if closeDidRun != 5 {
+		t.Error(`expected close methods to be called `, 5, ` times but was `, closeDidRun)
+	}
+}
@@ -0,0 +1,108 @@
+package go_drain
+
+import "sort"
+
+// CompositeDrainer manages hierarchical configuration: a root Drain whose
+// configuration contains sub-configurations that are each managed by a child
+// Drain. Reloading the composite reloads the root, then only the children whose
+// part of the root configuration changed
+type CompositeDrainer struct {
+	// root is the Drain of the root configuration
+	root *Drain
+
+	// children are the Drains of the sub-configurations by name
+	children map[string]*Drain
+
+	// names are the names of the children, sorted
+	names []string
+
+	// childChanged determines which children to reload after the root reloads
+	childChanged func(oldRoot, newRoot interface{}) []string
+}
+
+// NewCompositeDrainer creates a CompositeDrainer
+// @param root is the Drain of the root configuration
+// @param children are the Drains of the sub-configurations by name. The map is copied
+// @param childChanged is called after the root swaps in a new version with the
+//   old and new root configurations. It returns the names of the children to
+//   reload. Both configurations are claimed while it runs
+// @return the CompositeDrainer
+func NewCompositeDrainer(
+	root *Drain,
+	children map[string]*Drain,
+	childChanged func(oldRoot, newRoot interface{}) []string,
+) *CompositeDrainer {
+	c := &CompositeDrainer{
+		root:         root,
+		children:     make(map[string]*Drain, len(children)),
+		names:        make([]string, 0, len(children)),
+		childChanged: childChanged,
+	}
+	for name, child := range children {
+		c.children[name] = child
+		c.names = append(c.names, name)
+	}
+	sort.Strings(c.names)
+	return c
+}
+
+// Root gets the Drain of the root configuration
+func (c *CompositeDrainer) Root() *Drain {
+	return c.root
+}
+
+// Child gets the Drain of the named sub-configuration
+// @return the Drain and true, or nil and false if there is no child with that name
+func (c *CompositeDrainer) Child(name string) (*Drain, bool) {
+	child, ok := c.children[name]
+	return child, ok
+}
+
+// ReLoad reloads the root. If it swaps in a new version, the children that
+// childChanged names are reloaded in the order named. A child that fails to
+// reload does not stop the others from reloading
+// @return the error from reloading the root, in which case no child is
+//   reloaded, or else the first error from reloading a child, which is
+//   ErrUnknownDrain if childChanged named a child that does not exist
+func (c *CompositeDrainer) ReLoad() error {
+	oldRoot, err := c.root.Claim()
+	if err != nil {
+		return err
+	}
+	// keep the old root open until childChanged has compared it
+	defer c.root.Release(&oldRoot)
+	if err = c.root.ReLoad(); err != nil {
+		return err
+	}
+	newRoot, err := c.root.Claim()
+	if err != nil {
+		return err
+	}
+	defer c.root.Release(&newRoot)
+	if newRoot.Version() == oldRoot.Version() {
+		// nothing changed, so no child did either
+		return nil
+	}
+	var firstErr error
+	for _, name := range c.childChanged(oldRoot.Config(), newRoot.Config()) {
+		child, ok := c.children[name]
+		if ok {
+			err = child.ReLoad()
+		} else {
+			err = ErrUnknownDrain
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// StopAndJoin stops the children, one at a time by name, then the root, so
+// the root outlives everything that was configured from it
+func (c *CompositeDrainer) StopAndJoin() {
+	for _, name := range c.names {
+		c.children[name].StopAndJoin()
+	}
+	c.root.StopAndJoin()
+}
@@ -0,0 +1,76 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+)
+
+// rootConfig holds the settings of each child configuration
+type rootConfig struct {
+	children map[string]string
+}
+
+func TestCompositeDrainer(t *testing.T) {
+	settings := map[string]string{`db`: `a`, `cache`: `a`}
+	stopped := make([]string, 0)
+	root, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		cfg := &rootConfig{children: make(map[string]string)}
+		for name, s := range settings {
+			cfg.children[name] = s
+		}
+		return cfg, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig == nil {
+			stopped = append(stopped, `root`)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newChild := func(name string) *Drain {
+		child, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+			return &myConfig{name: name}, nil
+		}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+			if currentlyRunningConfig == nil {
+				stopped = append(stopped, name)
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return child
+	}
+	c := NewCompositeDrainer(root, map[string]*Drain{
+		`db`:    newChild(`db`),
+		`cache`: newChild(`cache`),
+	}, func(oldRoot, newRoot interface{}) []string {
+		changed := make([]string, 0)
+		for _, name := range []string{`db`, `cache`, `search`} {
+			if oldRoot.(*rootConfig).children[name] != newRoot.(*rootConfig).children[name] {
+				changed = append(changed, name)
+			}
+		}
+		return changed
+	})
+
+	settings[`db`] = `b`
+	if err = c.ReLoad(); err != nil {
+		t.Fatal(err)
+	}
+	db, _ := c.Child(`db`)
+	cache, _ := c.Child(`cache`)
+	if c.Root().CurrentVersion() != 2 || db.CurrentVersion() != 2 || cache.CurrentVersion() != 1 {
+		t.Error(`expected only the changed child to reload, but got: `, c.Root().CurrentVersion(), db.CurrentVersion(), cache.CurrentVersion())
+	}
+
+	// a child that does not exist
+	settings[`search`] = `a`
+	if err = c.ReLoad(); err != ErrUnknownDrain {
+		t.Error(`expected ErrUnknownDrain, but got: `, err)
+	}
+
+	c.StopAndJoin()
+	if fmt.Sprint(stopped) != `[cache db root]` {
+		t.Error(`expected the children to stop, then the root, but got: `, stopped)
+	}
+}
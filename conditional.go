@@ -0,0 +1,82 @@
+package go_drain
+
+import "errors"
+
+// ErrNotModified is returned by ReLoad on a Drain created with NewConditional
+// when the source's token has not changed since the latest version was built
+var ErrNotModified = errors.New(`source not modified`)
+
+// NewConditional is New, but ReLoad only builds a new configuration if the
+// source has changed, as HTTP conditional requests do. Before each reload,
+// fetchToken is asked for a token identifying the state of the source, such as
+// an ETag or a modification time. If it matches the token the latest version was
+// built from, ReLoad returns ErrNotModified without calling loadAndTest.
+// Otherwise, the reload proceeds and, if it succeeds or finds that the
+// configuration has not changed, see ErrNoChange, the token is recorded
+// @param fetchToken identifies the current state of the source. It's called
+//   before the initial load, and before each reload
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when fetching the token, ErrCallbackPanicked
+//   if fetchToken panicked, or loading or testing the config
+func NewConditional(
+	fetchToken func() (string, error),
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+) (c *Drain, err error) {
+	token, err := protectedFetchToken(fetchToken)
+	if err != nil {
+		return nil, err
+	}
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.fetchToken = fetchToken
+		d.lastToken = token
+	})
+}
+
+// protectedFetchToken calls fetchToken
+// @return the token, or ErrCallbackPanicked if fetchToken panicked
+func protectedFetchToken(fetchToken func() (string, error)) (token string, err error) {
+	if protect(CallbackSiteFetchToken, func() { token, err = fetchToken() }) {
+		return ``, ErrCallbackPanicked
+	}
+	return
+}
+
+// fetchSourceToken fetches the token of the source
+// @return the token, ErrCallbackPanicked if fetchToken panicked, or
+//   ErrNotModified if it's the token of the latest version
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) fetchSourceToken() (token string, err error) {
+	if token, err = protectedFetchToken(d.fetchToken); err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if token == d.lastToken {
+		return token, ErrNotModified
+	}
+	return
+}
+
+// recordSourceToken records the token of the source that the latest version
+// matches, so that the source is not reloaded until it changes again
+// @param token is the token fetched before the reload
+// @param opts are the options of the reload. Reloads that don't read the source
+//   don't record the token
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) recordSourceToken(token string, opts reloadOptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recordSourceTokenLocked(token, opts)
+}
+
+// recordSourceTokenLocked is recordSourceToken
+//
+// Assumes that the d.mu is locked
+func (d *Drain) recordSourceTokenLocked(token string, opts reloadOptions) {
+	if d.fetchToken != nil && opts.load == nil {
+		d.lastToken = token
+	}
+}
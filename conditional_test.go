@@ -0,0 +1,168 @@
+package go_drain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewConditional(t *testing.T) {
+	etag := `"a"`
+	loaded := 0
+	d, err := NewConditional(func() (string, error) {
+		return etag, nil
+	}, func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unchanged token
+	if err = d.ReLoad(); err != ErrNotModified {
+		t.Error(`expected ErrNotModified, but got: `, err)
+	}
+	if loaded != 1 {
+		t.Error(`expected no load for an unmodified source, but loaded `, loaded, ` times`)
+	}
+
+	// changed token
+	etag = `"b"`
+	if err = d.ReLoad(); err != nil {
+		t.Error(`expected the reload to succeed, but got: `, err)
+	}
+	if loaded != 2 || d.CurrentVersion() != 2 {
+		t.Error(`expected a new version, but got: `, loaded, d.CurrentVersion())
+	}
+	if err = d.ReLoad(); err != ErrNotModified {
+		t.Error(`expected the new token to be recorded, but got: `, err)
+	}
+	d.StopAndJoin()
+}
+
+func TestNewConditional_ReLoadExclusive(t *testing.T) {
+	etag := `"a"`
+	loaded := 0
+	d, err := NewConditional(func() (string, error) {
+		return etag, nil
+	}, func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.ReLoadExclusive(context.Background()); err != ErrNotModified {
+		t.Error(`expected ErrNotModified, but got: `, err)
+	}
+	etag = `"b"`
+	if err = d.ReLoadExclusive(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.ReLoad(); err != ErrNotModified {
+		t.Error(`expected the token of the exclusive reload to be recorded, but got: `, err)
+	}
+	if loaded != 2 {
+		t.Error(`expected one exclusive reload, but loaded `, loaded, ` times`)
+	}
+	d.StopAndJoin()
+}
+
+func TestNewConditional_UnchangedRecordsToken(t *testing.T) {
+	etag := `"a"`
+	loaded := 0
+	d, err := NewConditional(func() (string, error) {
+		return etag, nil
+	}, func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		if currentConfig == nil {
+			return &myConfig{name: `v`}, nil
+		}
+		// the source changed, but not in a way that changes the configuration
+		return currentConfig, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	etag = `"b"`
+	if changed, err := d.ReLoadDedupe(); changed || err != nil {
+		t.Error(`expected no change, but got: `, changed, err)
+	}
+	if err = d.ReLoad(); err != ErrNotModified {
+		t.Error(`expected the token of the unchanged config to be recorded, but got: `, err)
+	}
+	if loaded != 2 || d.CurrentVersion() != 1 {
+		t.Error(`expected one reload and no new version, but got: `, loaded, d.CurrentVersion())
+	}
+	d.StopAndJoin()
+}
+
+func TestNewConditional_TokenError(t *testing.T) {
+	errFetch := errors.New(`fetch failed`)
+	fail := false
+	etag := `"a"`
+	d, err := NewConditional(func() (string, error) {
+		if fail {
+			return ``, errFetch
+		}
+		return etag, nil
+	}, func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	if err = d.ReLoad(); err != errFetch {
+		t.Error(`expected the fetch error, but got: `, err)
+	}
+	d.StopAndJoin()
+
+	if _, err = NewConditional(func() (string, error) {
+		return ``, errFetch
+	}, nil, nil); err != errFetch {
+		t.Error(`expected the fetch error, but got: `, err)
+	}
+}
+
+func TestNewConditional_TokenPanic(t *testing.T) {
+	sites := make([]string, 0)
+	SetCallbackPanicHandler(func(recovered interface{}, site string) {
+		sites = append(sites, site)
+	})
+	defer SetCallbackPanicHandler(nil)
+
+	panicking := false
+	d, err := NewConditional(func() (string, error) {
+		if panicking {
+			panic(`fetch panicked`)
+		}
+		return `"a"`, nil
+	}, func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicking = true
+	if err = d.ReLoad(); err != ErrCallbackPanicked {
+		t.Error(`expected ErrCallbackPanicked, but got: `, err)
+	}
+	if d.CurrentVersion() != 1 {
+		t.Error(`expected the current version to keep serving, but got `, d.CurrentVersion())
+	}
+	d.StopAndJoin()
+
+	if _, err = NewConditional(func() (string, error) {
+		panic(`fetch panicked`)
+	}, nil, nil); err != ErrCallbackPanicked {
+		t.Error(`expected ErrCallbackPanicked, but got: `, err)
+	}
+	if fmt.Sprint(sites) != `[FetchToken FetchToken]` {
+		t.Error(`expected both panics to be reported, but got: `, sites)
+	}
+}
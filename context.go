@@ -0,0 +1,30 @@
+package go_drain
+
+import "context"
+
+// Context gets a context that is canceled when the Drain is stopped by Stop or
+// StopAndJoin. Pass it to background workers tied to the Drain's lifecycle so
+// they all share a single cancellation signal. After Reset, a new context is
+// created for the next lifecycle
+// @return the context, which is the same on every call until the Drain is Reset.
+//   It's already canceled if the Drain is stopped
+func (d *Drain) Context() context.Context {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ctx == nil {
+		d.ctx, d.cancelCtx = context.WithCancel(context.Background())
+		if d.isStopped {
+			d.cancelCtx()
+		}
+	}
+	return d.ctx
+}
+
+// cancelContext cancels the context returned by Context, if it was requested
+//
+// Assumes that the d.mu is locked
+func (d *Drain) cancelContext() {
+	if d.cancelCtx != nil {
+		d.cancelCtx()
+	}
+}
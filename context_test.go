@@ -0,0 +1,56 @@
+package go_drain
+
+import (
+	"testing"
+)
+
+func TestDrain_Context(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := d.Context()
+	if ctx != d.Context() {
+		t.Error(`expected the same context on every call`)
+	}
+	if ctx.Err() != nil {
+		t.Error(`expected the context to be live while the drain runs, but got: `, ctx.Err())
+	}
+	_ = d.ReLoad()
+	if ctx.Err() != nil {
+		t.Error(`expected reloads to leave the context live, but got: `, ctx.Err())
+	}
+	d.Stop()
+	select {
+	case <-ctx.Done():
+	default:
+		t.Error(`expected the context to be canceled after Stop`)
+	}
+	if d.Context().Err() == nil {
+		t.Error(`expected the context requested after Stop to be canceled`)
+	}
+}
+
+func TestDrain_ContextAfterReset(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := d.Context()
+	d.StopAndJoin()
+	if err = d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Context().Err() != nil {
+		t.Error(`expected a live context after Reset, but got: `, d.Context().Err())
+	}
+	if old.Err() == nil {
+		t.Error(`expected the context of the previous lifecycle to stay canceled`)
+	}
+	d.StopAndJoin()
+}
@@ -0,0 +1,57 @@
+package go_drain
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrNoChange is returned by ReLoad on a Drain created with NewWithDedupe when
+// the newly built configuration is equal to the current one
+var ErrNoChange = errors.New(`configuration did not change`)
+
+// NewWithDedupe is New, but reloads that build a configuration equal to the
+// current one are declined. The candidate is closed, no new version is
+// created, and ReLoad returns ErrNoChange. This avoids version churn when
+// several triggers fire for the same underlying configuration, such as a file
+// being touched without its content changing
+// @param equal compares the candidate configuration, a, with the current
+//   configuration, b. Return true if they are equivalent
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithDedupe(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	equal func(a, b interface{}) bool,
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.equal = equal
+	})
+}
+
+// NewWithDeepEqualDedupe is NewWithDedupe, comparing configurations with
+// reflect.DeepEqual. This is convenient for plain configuration structs, but
+// reflect.DeepEqual walks the entire value on every reload, so it can be slow
+// for large configurations, and it treats configurations holding funcs,
+// channels or live connections as different even when they are not
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithDeepEqualDedupe(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+) (c *Drain, err error) {
+	return NewWithDedupe(loadAndTest, closer, reflect.DeepEqual)
+}
+
+// ReLoadDedupe is ReLoad, but reports whether a new version was created rather
+// than returning ErrNoChange
+// @return changed is true if a new version was swapped in, false if the built
+//   configuration was equal to the current one, loadAndTester returned the
+//   current configuration, or there was an error
+// @return err the error encountered during loader and tester
+func (d *Drain) ReLoadDedupe() (changed bool, err error) {
+	err = d.reLoad(reloadOptions{reportUnchanged: true})
+	if err == ErrNoChange {
+		return false, nil
+	}
+	return err == nil, err
+}
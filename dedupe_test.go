@@ -0,0 +1,108 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewWithDedupe(t *testing.T) {
+	next := `a`
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := NewWithDedupe(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`%s%d`, next, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	}, func(a, b interface{}) bool {
+		return a.(*myConfig).name[0] == b.(*myConfig).name[0]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// identical content
+	if err = d.ReLoad(); err != ErrNoChange {
+		t.Error(`expected ErrNoChange, but got: `, err)
+	}
+	if fmt.Sprint(closed) != `[a2]` {
+		t.Error(`expected the duplicate candidate to be closed, but got: `, closed)
+	}
+	if d.CurrentVersion() != 1 {
+		t.Error(`expected no new version, but got: `, d.CurrentVersion())
+	}
+	changed, err := d.ReLoadDedupe()
+	if changed || err != nil {
+		t.Error(`expected no change and no error, but got: `, changed, err)
+	}
+
+	// differing content
+	next = `b`
+	changed, err = d.ReLoadDedupe()
+	if !changed || err != nil {
+		t.Error(`expected a change and no error, but got: `, changed, err)
+	}
+	if d.CurrentVersion() != 2 {
+		t.Error(`expected a new version, but got: `, d.CurrentVersion())
+	}
+	if fmt.Sprint(closed) != `[a2 a3 a1]` {
+		t.Error(`expected the duplicate candidates and the replaced config to be closed, but got: `, closed)
+	}
+	d.StopAndJoin()
+}
+
+func TestNewWithDeepEqualDedupe(t *testing.T) {
+	next := `a`
+	closed := 0
+	d, err := NewWithDeepEqualDedupe(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: next}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deeply equal, but a distinct pointer
+	if err = d.ReLoad(); err != ErrNoChange {
+		t.Error(`expected ErrNoChange, but got: `, err)
+	}
+	if d.CurrentVersion() != 1 {
+		t.Error(`expected no new version, but got: `, d.CurrentVersion())
+	}
+	if closed != 1 {
+		t.Error(`expected the duplicate candidate to be closed, but got: `, closed)
+	}
+
+	// differing content
+	next = `b`
+	if err = d.ReLoad(); err != nil {
+		t.Error(`expected no error, but got: `, err)
+	}
+	if d.CurrentVersion() != 2 {
+		t.Error(`expected a new version, but got: `, d.CurrentVersion())
+	}
+	claim, _ := d.Claim()
+	if claim.Config().(*myConfig).name != `b` {
+		t.Error(`expected the new config to be current, but got: `, claim.Config())
+	}
+	d.Release(&claim)
+	d.StopAndJoin()
+}
+
+func TestDrain_ReLoadDedupe_Unchanged(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		if currentConfig != nil {
+			return currentConfig, nil
+		}
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := d.ReLoadDedupe()
+	if changed || err != nil {
+		t.Error(`expected no change and no error, but got: `, changed, err)
+	}
+	d.StopAndJoin()
+}
@@ -2,8 +2,13 @@ package go_drain
 
 import (
 	"container/list"
+	"context"
 	"errors"
+	"reflect"
+	"runtime/trace"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Drain is a way to create configurations and rotate them out whenever needed.
@@ -31,6 +36,9 @@ import (
 //   the ability to compare the current configuration with the new configuration
 //   so if a socket hasn't changed, you don't need to create a new http listener.
 //   Just be sure you don't close that listener on yourself ;)
+//   If nothing has changed, return currentlyRunningConfig itself with no error.
+//   The Drain will treat this as a no-op reload: no new version is created and
+//   nothing is closed.
 // @return config your configuration object. This will be returned to callers of "Claim"
 // @return err is any error encountered when loading the configuration
 type LoadAndTesterFunc func(currentlyRunningConfig interface{}) (newConfig interface{}, err error)
@@ -48,6 +56,20 @@ type LoadAndTesterFunc func(currentlyRunningConfig interface{}) (newConfig inter
 //   up all resources.
 type CloserFunc func(configToClose interface{}, currentlyRunningConfig interface{})
 
+// CloseReason is why a configuration is being closed
+type CloseReason int
+
+const (
+	// CloseReasonReplaced is used when a configuration was superseded by a
+	// reload and has been fully released, or when a configuration built by a
+	// reload is discarded
+	CloseReasonReplaced CloseReason = iota
+
+	// CloseReasonShutdown is used when the configuration that was current when
+	// Stop or StopAndJoin was called is closed
+	CloseReasonShutdown
+)
+
 // ConfigClaim holds the configuration claim
 // The version is used to determine which version
 // of the config to clean up
@@ -57,6 +79,36 @@ type ConfigClaim struct {
 
 	// config is an interface to allow users to submit any configuration
 	config interface{}
+
+	// meta is the metadata attached to the claimed version by ReLoadWithMeta
+	meta interface{}
+
+	// traceID associates the claim with a trace, see ClaimWithTrace
+	traceID string
+
+	// drainName is the name of the Drainer in a MultiDrainer that issued this claim
+	drainName string
+
+	// holdsSlot is true if this claim holds a slot in the claim limit
+	holdsSlot bool
+
+	// drainID identifies the Drain that issued this claim
+	drainID uint64
+
+	// id identifies this claim among outstanding claims when the Drain tracks
+	// individual claims. 0 if not tracked
+	id uint64
+
+	// shard is one more than the index of the shard this claim is counted in,
+	// see NewWithShardedClaims. 0 if counted in the version's count
+	shard int
+
+	// claimedAt is when the claim was made, see NewWithClaimSampling and
+	// SetStaleClaimWindow. Zero if neither is on
+	claimedAt time.Time
+
+	// region is the execution trace region of the claim, see SetRuntimeTracing. nil if not tracing
+	region *trace.Region
 }
 
 // Version gets the version of the configuration
@@ -70,10 +122,36 @@ func (c ConfigClaim) Config() interface{} {
 	return c.config
 }
 
+// ConfigOK is Config, but also reports whether the claim holds a configuration,
+// so that callers may branch instead of casting a nil configuration
+// @return the configuration or nil if the claim is not valid
+// @return true if the claim is valid, see IsValid
+func (c ConfigClaim) ConfigOK() (interface{}, bool) {
+	return c.config, c.IsValid()
+}
+
+// IsValid is true if the claim holds a configuration. A zero claim, such as one
+// returned with an error by Claim, or one that was Released or Invalidated, is
+// not valid
+func (c ConfigClaim) IsValid() bool {
+	return c.version != 0 && c.config != nil
+}
+
+// Meta gets the metadata attached to the claimed version by ReLoadWithMeta
+// @return the metadata or nil if none was attached
+func (c ConfigClaim) Meta() interface{} {
+	return c.meta
+}
+
+// TraceID gets the trace id the claim was made with by ClaimWithTrace
+// @return the trace id or empty if there is none
+func (c ConfigClaim) TraceID() string {
+	return c.traceID
+}
+
 // Invalidate resets the claim to prevent misuse
 func (c *ConfigClaim) Invalidate() {
-	c.version = 0
-	c.config = nil
+	*c = ConfigClaim{}
 }
 
 // Drainer is an interface that defines methods
@@ -85,7 +163,8 @@ type Drainer interface {
 	// make a call to Claim, you MUST call Release to ensure
 	// data is cleaned up
 	// @return ConfigClaim representing the claim with the configuration
-	// @return error if Stop has been called on the Drain
+	// @return error if Stop has been called on the Drain, or ErrNoConfigYet
+	//   if no configuration has been loaded yet
 	Claim() (ConfigClaim, error)
 
 	// Release indicates that the go routine is finished with
@@ -138,20 +217,103 @@ type configVersion struct {
 	// copy of the configuration
 	count uint64
 
+	// totalClaims counts every claim ever made on this version, see
+	// SetOnVersionDrainComplete. Accessed atomically, kept near the top to be
+	// 64-bit aligned
+	totalClaims uint64
+
 	// version is which configuration this represents
 	version uint64
 
 	// config is the actual configuration data
 	config interface{}
+
+	// meta is the metadata attached by ReLoadWithMeta, if any
+	meta interface{}
+
+	// lingering closes this version once it has lingered unclaimed, see NewWithLinger. nil if not lingering
+	lingering *lingering
+
+	// shards count the claims taken on the fast path, see NewWithShardedClaims. nil if not sharded
+	shards []claimShard
+
+	// supersededAt is when a reload replaced this version as the latest. Zero while it's the latest
+	supersededAt time.Time
+
+	// reportedStuck is true once this version was reported as stuck, see SetStuckVersionHandler
+	reportedStuck bool
+
+	// retainedBytes is the size of config estimated by the retention sizer, see SetRetentionSizer
+	retainedBytes int64
+
+	// retentionSized is true once retainedBytes has been estimated
+	retentionSized bool
+
+	// retireHooks are called once this version is retired, see ClaimWithRetireHook
+	retireHooks []func()
 }
 
 // ErrDrainAlreadyStopped is returned when Claim is called on a closed Drain
 var ErrDrainAlreadyStopped = errors.New(`drain already stopped`)
 
+// ErrNoConfigYet is returned when Claim is called before any configuration has been loaded
+var ErrNoConfigYet = errors.New(`no configuration loaded yet`)
+
+// ErrVersionNotAvailable is returned when ClaimVersion is called with a version that has been retired
+var ErrVersionNotAvailable = errors.New(`version not available`)
+
+// lastDrainID is the id of the last Drain created. Accessed atomically
+var lastDrainID uint64
+
 // Drain contains the life-cycle state
+//
+// A configuration is never handed out once its closer may be running. Each
+// version is removed from the Drain, while locked, before the lock is released
+// to call its closer, and claims are only ever served from versions that are
+// still tracked. Configurations that are never published, such as those that
+// fail to load, are never handed out at all
+//
+// User callbacks, such as the loadAndTester, the closer, and the hooks set by
+// SetOnActivated, SetOnSuperseded, SetOnVersionDrainComplete and the like, are
+// always called with no lock held on the Drain. They may call back into the
+// Drain, for instance to read CurrentVersion or Stats, to Claim, or even to
+// ReLoad. The one exception is the inherit function of ReLoadInheriting, which
+// must not call the Drain. Hooks are called on the go routine that triggered
+// them, so a hook that reloads is called again, recursively, for the reload it
+// triggers and must guard against recursing forever. A callback must also not
+// wait on the operation that called it, such as calling StopAndJoin from a
+// closer run in the background by a Drain created with NewAsyncClose, as
+// StopAndJoin waits for that very close
 type Drain struct {
-	// mu is used to ensure that data is synchronized between routines
-	mu sync.Mutex
+	// rejectedClaims counts calls to Claim that returned ErrDrainAlreadyStopped.
+	// Accessed atomically, kept first to be 64-bit aligned
+	rejectedClaims uint64
+
+	// pendingCloseCount mirrors the count of closeWg, which cannot be read.
+	// Accessed atomically, kept near the top to be 64-bit aligned
+	pendingCloseCount int64
+
+	// waitingClaims counts the go routines parked in a blocking claim.
+	// Accessed atomically, kept near the top to be 64-bit aligned
+	waitingClaims int64
+
+	// staleClaims counts claims of versions superseded soon after the claim, see SetStaleClaimWindow.
+	// Accessed atomically, kept near the top to be 64-bit aligned
+	staleClaims uint64
+
+	// staleClaimWindow is how soon after a claim a reload makes the claim stale, see SetStaleClaimWindow.
+	// 0 to not count stale claims. Accessed atomically, kept near the top to be 64-bit aligned
+	staleClaimWindow int64
+
+	// runtimeTracing is 1 if claims start execution trace regions, see SetRuntimeTracing. Accessed atomically
+	runtimeTracing int32
+
+	// id identifies this Drain among all Drains, so that claims from other Drains can be recognized
+	id uint64
+
+	// mu is used to ensure that data is synchronized between routines. It's
+	// only read locked by the fast path of a Drain with sharded claims
+	mu sync.RWMutex
 
 	// closeWg counts how many copies of all configurations are outstanding
 	// once all of those configurations are released, StopAndJoinError will
@@ -169,8 +331,201 @@ type Drain struct {
 	// closer is the method that is called to shutdown or close resources used by the configuration
 	closer CloserFunc
 
+	// warmup, if set, is called on new configurations after they load and test, see NewWithWarmup
+	warmup WarmupFunc
+
+	// equal, if set, declines reloads that build a configuration equal to the current one, see NewWithDedupe
+	equal func(a, b interface{}) bool
+
+	// reloadGate, if set, may abort reloads before anything is built, see SetReloadGate
+	reloadGate func() error
+
+	// validate, if set, checks every configuration that is built, including by
+	// ReLoadTransform. See NewSelfValidating, NewWithValidators, and Builder.Validator
+	validate func(config interface{}) error
+
+	// fetchToken, if set, identifies the state of the source so that unmodified sources are not reloaded, see NewConditional
+	fetchToken func() (string, error)
+
+	// lastToken is the token of the source that the latest version was built from
+	lastToken string
+
+	// mirror holds the latest configuration for lock-free reads, see AtomicConfig. nil until requested
+	mirror *atomic.Value
+
+	// shutdownCloser, if set, is called instead of closer for CloseReasonShutdown
+	shutdownCloser CloserFunc
+
+	// closeExecutor, if set, runs every call to the closer, see NewWithCloseExecutor
+	closeExecutor func(func())
+
+	// closeTimeout bounds how long a closer may run, see NewWithCloseTimeout. 0 to wait forever
+	closeTimeout time.Duration
+
+	// linger is how long drained versions are kept open before closing, see NewWithLinger. 0 to close immediately
+	linger time.Duration
+
+	// retentionSizer estimates the bytes held by a lingering version, see SetRetentionSizer. nil if unbounded
+	retentionSizer func(config interface{}) int64
+
+	// maxRetainedBytes is the most bytes lingering versions may hold, see SetRetentionSizer
+	maxRetainedBytes int64
+
 	// isStopped tracks if the Drain is stopped
 	isStopped bool
+
+	// tracer holds a tracerHolder with the Tracer set by SetTracer
+	tracer atomic.Value
+
+	// clockSource holds a clockHolder with the Clock set by SetClock
+	clockSource atomic.Value
+
+	// stopped is closed when the Drain is stopped to wake up anything waiting on the Drain
+	stopped chan struct{}
+
+	// maxVersionResources caps the number of live versions, see SetMaxConcurrentVersionResources. 0 if unlimited
+	maxVersionResources int
+
+	// onExceedVersionResources produces the error ReLoad fails with when maxVersionResources would be exceeded
+	onExceedVersionResources func() error
+
+	// claimShards is how many shards each version counts fast path claims in, see NewWithShardedClaims. 0 if not sharded
+	claimShards int
+
+	// generation counts the successful reloads, see Generation
+	generation uint64
+
+	// generationChanged is closed and replaced when generation advances to wake up WaitForGeneration. nil until waited on
+	generationChanged chan struct{}
+
+	// ctx is canceled by cancelCtx when the Drain is stopped, see Context. nil until requested
+	ctx       context.Context
+	cancelCtx context.CancelFunc
+
+	// claimSlots limits the number of outstanding claims, see NewWithClaimLimit. nil if unlimited
+	claimSlots chan struct{}
+
+	// claimSamples records released claims, see NewWithClaimSampling. nil if not sampling
+	claimSamples *claimSampleRing
+
+	// asyncClose moves closes triggered by Release to a background worker, see NewAsyncClose
+	asyncClose bool
+
+	// freezeGate blocks reloads and stops while the Drain is frozen, see Freeze
+	freezeGate freezeGate
+
+	// closeWorkers is the most background workers that perform queued closes at a time,
+	// see NewWithCloseWorkers. 0 for one worker
+	closeWorkers int
+
+	// closeQueueMu guards closeQueue and closeWorkersRunning
+	closeQueueMu sync.Mutex
+
+	// closeQueue are the closes waiting for a background worker
+	closeQueue []queuedClose
+
+	// closeWorkersRunning are how many background workers are processing closeQueue
+	closeWorkersRunning int
+
+	// pendingCloses counts closes triggered by Release that have not completed,
+	// whether they are queued or performed on the releasing go routine
+	pendingCloses sync.WaitGroup
+
+	// smoothingRate is how many claims per second may move to a new version right after a reload,
+	// see NewWithClaimSmoothing. 0 to disable
+	smoothingRate int
+
+	// smoothingFrom is the version that was current before the latest reload,
+	// which claims fall back to while smoothing. nil if not smoothing
+	smoothingFrom *configVersion
+
+	// smoothingUntil is when claim smoothing for the latest reload ends
+	smoothingUntil time.Time
+
+	// smoothingTokens is the number of claims that may currently move to the new version
+	smoothingTokens float64
+
+	// smoothingRefilled is when smoothingTokens was last refilled
+	smoothingRefilled time.Time
+
+	// hooks are the user callbacks notified of changes to the Drain
+	hooks hooks
+
+	// trackGoroutines enables recording of the caller of each claim, see NewWithGoroutineTracking
+	trackGoroutines bool
+
+	// lastClaimID is the id of the last tracked claim
+	lastClaimID uint64
+
+	// claimCallers are the callers of outstanding tracked claims by claim id
+	claimCallers map[uint64]CallerInfo
+
+	// cancelers are the callbacks of outstanding claims made by ClaimCancelable by claim id
+	cancelers map[uint64]func()
+
+	// canceledOutstanding is true once CancelOutstanding has called the cancelers
+	canceledOutstanding bool
+
+	// retireSignals are the signals of outstanding claims made by ClaimWithRetireSignal by claim id
+	retireSignals map[uint64]retireSignal
+
+	// holdThreshold is how long a claim may be held before onLongHold is called, see NewWithHoldWatchdog. 0 to disable
+	holdThreshold time.Duration
+
+	// onLongHold is notified of claims held longer than holdThreshold
+	onLongHold func(version uint64, age time.Duration, stack []byte)
+
+	// holdWatches are the watchdog timers of outstanding claims by claim id
+	holdWatches map[uint64]Timer
+
+	// exclusiveGate is non-nil while ReLoadExclusive waits for the current version to drain.
+	// Calls to Claim block until it is closed
+	exclusiveGate chan struct{}
+
+	// exclusiveWaitOn is the version element ReLoadExclusive is waiting to be fully released
+	exclusiveWaitOn *list.Element
+
+	// exclusiveDrained is closed by Release when exclusiveWaitOn has no more claims
+	exclusiveDrained chan struct{}
+
+	// activeWeights are the claim weights by version, see SetActiveWeights. nil if only the latest version is served
+	activeWeights map[uint64]int
+
+	// activeCurrent are the smooth weighted round-robin counters by version
+	activeCurrent map[uint64]int
+
+	// reloadTimes is a ring buffer of when the most recent successful reloads happened
+	reloadTimes [reloadHistorySize]time.Time
+
+	// reloadNext is the index in reloadTimes that the next reload is recorded at
+	reloadNext int
+
+	// stormThreshold is the reload rate above which onReloadStorm fires, see SetOnReloadStorm
+	stormThreshold int
+
+	// stormWindow is the window stormThreshold is measured over
+	stormWindow time.Duration
+
+	// stuckThreshold is how long a superseded version may take to drain before onStuckVersion fires, see SetStuckVersionHandler
+	stuckThreshold time.Duration
+
+	// stuckHalt is closed to halt the stuck version monitor. nil if it's not running
+	stuckHalt chan struct{}
+
+	// lastHealth is the result of the most recent health check, see NewWithHealthMonitor
+	lastHealth error
+
+	// healthInterval is the time between health checks, 0 if health is not monitored, see NewWithHealthMonitor
+	healthInterval time.Duration
+
+	// healthFailureThreshold is how many consecutive failed health checks trigger a reload
+	healthFailureThreshold int
+
+	// periodicInterval is the time between reloads, 0 if not reloading periodically, see NewPeriodic
+	periodicInterval time.Duration
+
+	// haltPeriodic halts the periodic reloads started most recently. nil if never started
+	haltPeriodic func()
 }
 
 // NewDrain creates a Drain object
@@ -184,20 +539,57 @@ type Drain struct {
 // @param closer is the function that shuts down and releases resources in the
 //   configuration. In the event loadAndTester returns an error, the returned
 //   configuration, if any, will be returned to this method upon failure to
-//   allow you a single place to clean up the configuration.
+//   allow you a single place to clean up the configuration. nil if configurations
+//   hold nothing that needs closing
 // @return c the Drain object or nil, if there was an error
 // @return err any errors encountered when loading or testing the config
 func New(
 	loadAndTest LoadAndTesterFunc,
 	closer CloserFunc,
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer)
+}
+
+// NewWithClosers is New, but with different closers for configurations that are
+// replaced by a reload and for the configuration that is current at shutdown
+// @param onReplace is called for configurations that are superseded by ReLoad
+//   once they are released, and for configurations built by ReLoad that fail
+//   to load or test
+// @param onShutdown is called for the configuration that is current when Stop
+//   or StopAndJoin is called, once it is released
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithClosers(
+	loadAndTest LoadAndTesterFunc,
+	onReplace CloserFunc,
+	onShutdown CloserFunc,
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, onReplace, func(d *Drain) {
+		d.shutdownCloser = onShutdown
+	})
+}
+
+// drainOption configures optional behavior on a Drain before its initial load
+type drainOption func(d *Drain)
+
+// newDrain creates a Drain, applies the options, and performs the initial load
+func newDrain(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	opts ...drainOption,
 ) (c *Drain, err error) {
 	c = &Drain{
+		id:              atomic.AddUint64(&lastDrainID, 1),
 		versionTracking: list.New(),
 		loadAndTester:   loadAndTest,
 		closer:          closer,
+		stopped:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 	// perform the initial load
-	cv, err := c.doLoadAndTest()
+	cv, _, err := c.doLoadAndTest(c.loadAndTester)
 	if err != nil {
 		return nil, err
 	}
@@ -216,27 +608,151 @@ func New(
 // Claim is a routine-safe way of obtaining the configuration
 // @return cc the configuration with version number embedded for
 //  future release or an invalidated claim if Drain is already closed
-// @return err ErrDrainAlreadyStopped if StopAndJoin has been called, nil otherwise
+// @return err ErrDrainAlreadyStopped if StopAndJoin has been called, ErrNoConfigYet
+//   if no configuration has been loaded yet, nil otherwise
 func (d *Drain) Claim() (cc ConfigClaim, err error) {
+	err = d.ClaimInto(&cc)
+	return
+}
+
+// ClaimInto is Claim, but populates a caller-owned ConfigClaim in place. This
+// allows hot paths to keep the claim on the stack or re-use it between claims.
+// Release it with Release, as with Claim
+// @param cc is overwritten with the claim, or zeroed if there was an error
+// @return err ErrDrainAlreadyStopped if StopAndJoin has been called, ErrNoConfigYet
+//   if no configuration has been loaded yet, nil otherwise
+func (d *Drain) ClaimInto(cc *ConfigClaim) (err error) {
+	if d.claimSlots != nil {
+		return d.claimLimited(context.Background(), cc)
+	}
+	if err = d.claimInto(cc); err == nil && cc.version == 0 {
+		return ErrNoConfigYet
+	}
+	return
+}
+
+// claimInto claims the current version without regard to the claim limit.
+// This is used directly by the Drain for internal claims, such as passing the
+// current configuration to loadAndTester. If there is no configuration yet, cc
+// is left zeroed and no error is returned
+func (d *Drain) claimInto(cc *ConfigClaim) (err error) {
+	if end := d.startSpan(SpanClaim); end != nil {
+		defer func() { end(err) }()
+	}
+	if d.claimShards > 0 && d.claimFast(cc) {
+		return nil
+	}
+	// capture the stack before locking, it's slow
+	caller := d.captureClaimCaller()
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	// an exclusive reload is waiting for the current version to drain, do not
+	// hand out any more claims until it has swapped or given up
+	for d.exclusiveGate != nil && !d.isStopped {
+		gate := d.exclusiveGate
+		d.mu.Unlock()
+		atomic.AddInt64(&d.waitingClaims, 1)
+		<-gate
+		atomic.AddInt64(&d.waitingClaims, -1)
+		d.mu.Lock()
+	}
+	*cc = ConfigClaim{}
 	if d.isStopped {
-		return ConfigClaim{}, ErrDrainAlreadyStopped
+		atomic.AddUint64(&d.rejectedClaims, 1)
+		return ErrDrainAlreadyStopped
 	}
-	cc = ConfigClaim{}
 	e := d.versionTracking.Back()
 	if e == nil {
 		// No versions configured, return a nil version
-		return cc, nil
+		return nil
+	}
+	if d.activeWeights != nil {
+		e = d.pickWeighted()
+	} else if d.smoothingRate > 0 {
+		e = d.smoothClaim(e)
 	}
 	// Don't track this as outstanding until a real version is established
+	d.claimElement(e, cc, caller)
+	return nil
+}
+
+// ClaimWithTrace is Claim, but associates the claim with a trace or span id so
+// that work done with the configuration can be correlated with the request that
+// claimed it. The id is available from the claim's TraceID and is included in
+// the CallerInfo reported by ActiveClaimCallers. It does not change how the
+// claim is served
+// @param traceID identifies the trace
+// @return cc the claim with the trace id or an invalidated claim if there was an error
+// @return err the error returned by Claim
+func (d *Drain) ClaimWithTrace(traceID string) (cc ConfigClaim, err error) {
+	if cc, err = d.Claim(); err != nil {
+		return
+	}
+	cc.traceID = traceID
+	if cc.id != 0 {
+		d.mu.Lock()
+		if caller, ok := d.claimCallers[cc.id]; ok {
+			caller.TraceID = traceID
+			d.claimCallers[cc.id] = caller
+		}
+		d.mu.Unlock()
+	}
+	return
+}
+
+// ClaimVersion claims a specific version of the configuration, rather than the
+// latest, as long as it has not been retired. This allows tooling to pin and
+// inspect a prior configuration that is still draining. The claim keeps that
+// version from being retired until it is Released
+// @param version is the version to claim
+// @return cc the claim or an invalidated claim if there was an error
+// @return err ErrVersionNotAvailable if the version was retired or never existed,
+//   ErrDrainAlreadyStopped if the Drain is stopped, nil otherwise
+func (d *Drain) ClaimVersion(version uint64) (cc ConfigClaim, err error) {
+	caller := d.captureClaimCaller()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.isStopped {
+		atomic.AddUint64(&d.rejectedClaims, 1)
+		return cc, ErrDrainAlreadyStopped
+	}
+	e := d.findElementWithVersion(version)
+	if e == nil {
+		return cc, ErrVersionNotAvailable
+	}
+	d.claimElement(e, &cc, caller)
+	return cc, nil
+}
+
+// claimElement counts a claim on the version in e and fills in cc
+//
+// Assumes that the d.mu is locked
+func (d *Drain) claimElement(e *list.Element, cc *ConfigClaim, caller CallerInfo) {
 	ccv := e.Value.(*configVersion)
 	ccv.count++
-	d.closeWg.Add(1)
+	atomic.AddUint64(&ccv.totalClaims, 1)
+	d.addPendingClose()
+	ccv.stopLinger()
 
 	cc.version = ccv.version
+	cc.drainID = d.id
 	cc.config = ccv.config
-	return cc, nil
+	cc.meta = ccv.meta
+	if d.timesClaims() {
+		cc.claimedAt = d.now()
+	}
+	cc.region = d.startClaimRegion(ccv.version)
+	if d.trackGoroutines || d.holdThreshold > 0 {
+		d.lastClaimID++
+		cc.id = d.lastClaimID
+		caller.Version = ccv.version
+	}
+	if d.trackGoroutines {
+		d.claimCallers[cc.id] = caller
+	}
+	if d.holdThreshold > 0 {
+		d.watchHold(cc.id, caller)
+	}
 }
 
 // Release counts the ConfigClaim when performing draining.
@@ -249,10 +765,46 @@ func (d *Drain) Claim() (cc ConfigClaim, err error) {
 //   the ConfigClaim after calling Release on it, otherwise, those resources
 //   that it references may be closed or shutdown
 func (d *Drain) Release(cc *ConfigClaim) {
+	d.release(cc, false)
+}
+
+// ReleaseAndWait is Release, but if this Release triggers the configuration to
+// be closed, it does not return until the closer has returned, even if the
+// Drain closes asynchronously. This is useful when the caller must know that
+// resources are truly freed before proceeding, such as before re-binding a port
+// @param cc is the configuration claim provided by calling "Claim"
+func (d *Drain) ReleaseAndWait(cc *ConfigClaim) {
+	d.release(cc, true)
+}
+
+// release performs Release
+// @param wait is true to wait for any close triggered by this release to complete
+func (d *Drain) release(cc *ConfigClaim, wait bool) {
 	if cc == nil || cc.version == 0 {
 		// no version, just discard
 		return
 	}
+	if cc.drainID != d.id {
+		// claimed from another Drain, its version is meaningless here
+		return
+	}
+	if cc.holdsSlot {
+		// free up a slot for the next claim
+		<-d.claimSlots
+	}
+	if d.claimSamples != nil && !cc.claimedAt.IsZero() {
+		d.claimSamples.record(cc.version, d.since(cc.claimedAt))
+	}
+	if cc.region != nil {
+		cc.region.End()
+	}
+	if !cc.claimedAt.IsZero() && atomic.LoadInt64(&d.staleClaimWindow) > 0 {
+		d.countStaleClaim(cc)
+	}
+	if cc.shard != 0 && d.releaseFast(cc) {
+		cc.Invalidate()
+		return
+	}
 	d.mu.Lock()
 
 	// call Invalidate before returning to prevent using old configuration data
@@ -267,23 +819,65 @@ func (d *Drain) Release(cc *ConfigClaim) {
 		return
 	}
 	ccv := e.Value.(*configVersion)
-	ccv.count--
-	d.closeWg.Done()
+	// the claim is done in closeWg once any close it triggers is counted in
+	// pendingCloses, so that StopAndJoin cannot slip in between
+	var claimDone bool
+	if cc.shard != 0 {
+		atomic.AddInt64(&ccv.shards[cc.shard-1].count, -1)
+		// claims on the fast path were only counted in closeWg once the Drain stopped
+		claimDone = d.isStopped
+	} else {
+		ccv.count--
+		claimDone = true
+	}
+	if cc.id != 0 {
+		delete(d.claimCallers, cc.id)
+		delete(d.cancelers, cc.id)
+		if signal, ok := d.retireSignals[cc.id]; ok && signal.cancel != nil {
+			// the context of ClaimUntilSuperseded ends with the claim
+			signal.cancel()
+		}
+		delete(d.retireSignals, cc.id)
+		d.unwatchHold(cc.id)
+	}
+	// wake up ReLoadExclusive if it was waiting on this version
+	if ccv.claims() == 0 && e == d.exclusiveWaitOn {
+		close(d.exclusiveDrained)
+		d.exclusiveWaitOn = nil
+	}
 	// only drain if not the current count and the outstanding count is zero
 	// we do not want to clean up if we have no active threads as a new one may appear
-	if d.shouldCleanup(*ccv) {
+	if d.shouldCleanup(*ccv) && !d.startLinger(e) {
+		// the last version is the one that was current when the Drain stopped
+		reason := CloseReasonReplaced
+		if d.isStopped && e == d.versionTracking.Back() {
+			reason = CloseReasonShutdown
+		}
 		// cleanup this config
 		d.versionTracking.Remove(e)
 		latestVersion := d.latestVersion()
+		d.pendingCloses.Add(1)
+		if claimDone {
+			d.donePendingClose()
+		}
 
 		// unlock before allowing config to get cleaned up, as that could be along time
 		d.mu.Unlock()
+		d.drainCompleted(ccv)
 
-		// perform cleanup
-		d.closer(cc.config, latestVersion)
+		// perform cleanup, possibly in the background
+		d.releaseCloseConfig(cc.version, cc.config, latestVersion, reason, wait)
 	} else {
+		if claimDone {
+			d.donePendingClose()
+		}
+		// a version that just started lingering may put retention over budget
+		retained := ccv.lingering != nil && d.retentionSizer != nil
 		// be sure to unlock before returning
 		d.mu.Unlock()
+		if retained {
+			d.enforceRetentionBudget()
+		}
 	}
 	return
 }
@@ -302,9 +896,29 @@ func (d *Drain) ClaimRelease(closure func(currentlyRunningConfig interface{})) e
 	}
 }
 
+// ClaimIf is Claim, but only if pred is true for the claimed configuration. If pred
+// is false, the claim is Released before returning, so the bail-out path cannot
+// forget to Release
+// @param pred is called with the claimed configuration. Return true to keep the claim
+// @param unavailable is returned if pred returns false
+// @return cc the claim, which must be Released, or an invalidated claim on error
+// @return err ErrDrainAlreadyStopped if stopped, unavailable if pred returned false, nil otherwise
+func (d *Drain) ClaimIf(pred func(config interface{}) bool, unavailable error) (cc ConfigClaim, err error) {
+	cc, err = d.Claim()
+	if err != nil {
+		return
+	}
+	ok := false
+	if protect(CallbackSiteClaimIf, func() { ok = pred(cc.Config()) }) || !ok {
+		d.Release(&cc)
+		return cc, unavailable
+	}
+	return
+}
+
 // shouldCleanup is true if this configuration should be closed/cleaned up
 // This occurs when all go routines have released their claims for a version
-// UNLESS it's the latest version. If the StopAndJoinError has been called,
+// UNLESS it's the latest version or weighted by SetActiveWeights. If the StopAndJoinError has been called,
 // all configurations will be closed, even if the configuration is the
 // latest version. This way, if the system is still running, the last
 // configuration will not be closed, but if stopped, it will be cleaned up
@@ -312,8 +926,8 @@ func (d *Drain) ClaimRelease(closure func(currentlyRunningConfig interface{})) e
 // @param cv is the configuration version to check
 // @return true if cleanup should happen, false if not
 func (d *Drain) shouldCleanup(cv configVersion) bool {
-	return cv.count == 0 &&
-		(d.isStopped || d.versionTracking.Back().Value.(*configVersion).version != cv.version)
+	return cv.claims() == 0 &&
+		(d.isStopped || (d.versionTracking.Back().Value.(*configVersion).version != cv.version && !d.isWeighted(cv.version)))
 }
 
 // findElementWithVersion takes the version and returns the element with that version
@@ -333,72 +947,457 @@ func (d *Drain) findElementWithVersion(version uint64) (e *list.Element) {
 //
 // Assumes that the d.mu is not locked
 //
+// @param load is the loadAndTester to call, usually d.loadAndTester
 // @return cv is the configVersion with the configuration. It does NOT have the version field populated.
+// @return unchanged is true if loadAndTester returned the configuration it was given,
+//   indicating that there is nothing to swap
 // @return err the error returned by loader and tester, or nil if any
-func (d *Drain) doLoadAndTest() (cv configVersion, err error) {
+func (d *Drain) doLoadAndTest(load LoadAndTesterFunc) (cv configVersion, unchanged bool, err error) {
 	// perform the initial load
-	if cfg, claimErr := d.Claim(); claimErr != nil {
-		return configVersion{}, claimErr
-	} else {
-		// Perform the load
-		cv.config, err = d.loadAndTester(cfg.config)
+	var cfg ConfigClaim
+	claimErr := d.claimInto(&cfg)
+	if claimErr != nil {
+		return configVersion{}, false, claimErr
+	}
+	// the reload's own claim is not worth sampling, nor is it ever stale
+	cfg.claimedAt = time.Time{}
+	// Perform the load
+	if protect(CallbackSiteLoadAndTester, func() { cv.config, err = load(cfg.config) }) {
+		cv.config, err = nil, ErrCallbackPanicked
+	}
+	if err == nil && d.validate != nil {
+		if protect(CallbackSiteValidator, func() { err = d.validate(cv.config) }) {
+			err = ErrCallbackPanicked
+		}
+	}
+	unchanged = cfg.config != nil && sameConfig(cv.config, cfg.config)
+
+	// an equal configuration is not worth a new version, discard it
+	if err == nil && !unchanged && d.equal != nil && cfg.config != nil {
+		equal := false
+		if protect(CallbackSiteEqual, func() { equal = d.equal(cv.config, cfg.config) }) {
+			err = ErrCallbackPanicked
+		} else if equal {
+			err = ErrNoChange
+		}
+	}
+
+	// Ensure that the configuration is released
+	d.Release(&cfg)
+
+	// warm up the new configuration before it can be published
+	if err == nil && !unchanged && d.warmup != nil {
+		if protect(CallbackSiteWarmup, func() { err = d.warmup(cv.config) }) {
+			err = ErrCallbackPanicked
+		}
+	}
 
-		// Ensure that the configuration is released
-		d.Release(&cfg)
+	if err == nil && !unchanged {
+		cv.shards = d.newClaimShards()
 	}
 
 	// LoadAndTester threw an error, close down the broken/partially working configuration
 	if err != nil {
-		// if the configuration is nil, there is nothing to close
-		if cv.config != nil {
-			d.closer(cv.config, d.latestVersion())
+		// if the configuration is nil, there is nothing to close. If it's the
+		// running configuration, it's not ours to close
+		if cv.config != nil && !unchanged {
+			// read the current configuration under the lock, nil during construction
+			d.mu.Lock()
+			latestVersion := d.latestVersion()
+			d.mu.Unlock()
+			d.closeConfig(cv.version, cv.config, latestVersion, CloseReasonReplaced)
 		}
+		unchanged = false
 	}
 	return
 }
 
+// sameConfig is true if a and b are the same configuration object. Configurations
+// with types that cannot be compared, such as maps, are never the same
+func sameConfig(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return reflect.TypeOf(a).Comparable() && a == b
+}
+
 // ReLoad triggers the loader and tester to fire (without a lock). If there
 // are no errors, that configuration will be atomically appended to the Drain
 // as the latest version and will be returned in future calls to Claim. Once
 // all calls to Release are made, that version of the configuration will be
 // closed using the closer function.
-// @return err the error encountered during loader and tester
+// @return err the error encountered during loader and tester, or
+//   ErrDrainAlreadyStopped if the Drain is stopped
 func (d *Drain) ReLoad() (err error) {
+	return d.reLoad(reloadOptions{})
+}
+
+// ReLoadInheriting is like ReLoad, but allows the new configuration to inherit
+// runtime state, such as a rate-limiter's counters, from the configuration
+// that is currently serving. inherit is called at the moment of the swap, after
+// the new configuration is built and tested, but before any Claim can receive
+// it, so no Claim observes a partially inherited state.
+//
+// inherit is called while the Drain is locked. It must be fast and must not
+// call any methods on the Drain.
+// @param inherit copies state from old, the currently running configuration,
+//   into new, the configuration about to be swapped in
+// @return err the error encountered during loader and tester
+func (d *Drain) ReLoadInheriting(inherit func(old, new interface{})) (err error) {
+	return d.reLoad(reloadOptions{inherit: inherit})
+}
+
+// ReLoadWithMeta is ReLoad, but attaches meta to the resulting version, such as
+// the source file path, git SHA, or operator who triggered the reload. Claims of
+// that version return it from Meta and it can be looked up with MetaForVersion.
+// This records the provenance of a configuration without encoding it in the
+// configuration itself
+// @param meta is the metadata to attach
+// @return err the error encountered during loader and tester
+func (d *Drain) ReLoadWithMeta(meta interface{}) (err error) {
+	return d.reLoad(reloadOptions{meta: meta})
+}
+
+// ReLoadTransform is ReLoad, but the new configuration is built by transforming
+// the current one rather than by loadAndTester, for incremental changes such as
+// raising a worker count. The current version is claimed while transform runs.
+// The result is validated, warmed up, deduplicated, and published like any other
+// reload, but loadAndTester is not called, so transform must do any other
+// testing it needs. The source is not read, so a Drain created with
+// NewConditional runs transform even if its source has not changed.
+//
+// transform must not mutate current, which may still be in use by other claims.
+// It must return a new configuration. Returning current itself is a no-op
+// reload
+// @param transform builds the new configuration from current
+// @return err the error returned by transform or encountered while publishing
+func (d *Drain) ReLoadTransform(transform func(current interface{}) (interface{}, error)) (err error) {
+	return d.reLoad(reloadOptions{load: transform})
+}
+
+// MetaForVersion gets the metadata attached to a version by ReLoadWithMeta
+// @param version is the version to look up
+// @return the metadata, which is nil if none was attached
+// @return true if the version is still live, false if it was retired
+func (d *Drain) MetaForVersion(version uint64) (interface{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e := d.findElementWithVersion(version); e != nil {
+		return e.Value.(*configVersion).meta, true
+	}
+	return nil, false
+}
+
+// reloadOptions alter how reLoad swaps in the new version
+type reloadOptions struct {
+	// inherit, if non-nil, is called under the lock just before swapping in the new version
+	inherit func(old, new interface{})
+
+	// meta is attached to the new version
+	meta interface{}
+
+	// reportUnchanged returns ErrNoChange if loadAndTester returned the current configuration
+	reportUnchanged bool
+
+	// load, if non-nil, is called instead of loadAndTester to build the new
+	// configuration. It does not read the source, so the source token of
+	// NewConditional is neither checked nor updated
+	load LoadAndTesterFunc
+
+	// ready, if non-nil, must confirm the new configuration before it's published, see ReLoadGated
+	ready func(newConfig interface{}) error
+
+	// exclusive, if non-nil, waits for the current version to drain before
+	// swapping in the new version, bounded by it, and closes the current version
+	// at once rather than letting it linger, see ReLoadExclusive
+	exclusive context.Context
+}
+
+// reLoad performs ReLoad with the options once the Drain is not frozen
+func (d *Drain) reLoad(opts reloadOptions) (err error) {
+	_ = d.freezeGate.enter(context.Background())
+	defer d.freezeGate.exit()
+	return d.reLoadUnfrozen(opts)
+}
+
+// reLoadUnfrozen performs ReLoad with the options
+//
+// Assumes that the freezeGate is entered
+func (d *Drain) reLoadUnfrozen(opts reloadOptions) (err error) {
+	if end := d.startSpan(SpanReload); end != nil {
+		defer func() { end(err) }()
+	}
+	defer func() {
+		if err != nil && err != ErrNoChange && err != ErrNotModified {
+			d.reloadFailed(err)
+		}
+	}()
+	// do not build anything for a Drain that will never use it
+	d.mu.Lock()
+	stopped := d.isStopped
+	d.mu.Unlock()
+	if stopped {
+		return ErrDrainAlreadyStopped
+	}
+	// do not build anything if the policy forbids it
+	if err = d.checkReloadGate(); err != nil {
+		return
+	}
+	// do not build anything if the source has not changed
+	var token string
+	if d.fetchToken != nil && opts.load == nil {
+		if token, err = d.fetchSourceToken(); err != nil {
+			return
+		}
+	}
 	// perform the initial load
 	var cv configVersion
-	cv, err = d.doLoadAndTest()
-	if err != nil {
-		// if there is an error, do NOT change the state of the Drain
+	var unchanged bool
+	load := d.loadAndTester
+	if opts.load != nil {
+		load = opts.load
+	}
+	cv, unchanged, err = d.doLoadAndTest(load)
+	if unchanged || err == ErrNoChange {
+		// the latest version is what the source holds, don't build it again
+		d.recordSourceToken(token, opts)
+	}
+	if err != nil || unchanged {
+		// if there is an error or nothing changed, do NOT change the state of the Drain
+		if unchanged && opts.reportUnchanged {
+			err = ErrNoChange
+		}
 		return
 	}
+	// do not publish until an external system confirms the new configuration
+	if opts.ready != nil {
+		if err = d.confirmReady(cv, opts.ready); err != nil {
+			return
+		}
+	}
 
 	// Set the config
 	d.mu.Lock()
-	// append the new version to the back of the list, making it the latest version
+	open := func() {}
+	if opts.exclusive != nil {
+		if open, err = d.awaitExclusive(opts.exclusive); err != nil {
+			latestVersion := d.latestVersion()
+			d.mu.Unlock()
+			d.closeConfig(cv.version, cv.config, latestVersion, CloseReasonReplaced)
+			return
+		}
+	}
+	if err = d.checkPublish(); err != nil {
+		open()
+		return d.rejectPublish(cv, err)
+	}
+	finish := d.publish(&cv, token, opts)
+	open()
+	d.mu.Unlock()
+	finish()
+	return
+}
+
+// checkPublish checks whether a new version may be published
+// @return ErrDrainAlreadyStopped if stopped while building, nobody should get
+//   the new configuration, ErrTooManyVersions if too many versions are still
+//   holding resources, nil otherwise
+//
+// Assumes that the d.mu is locked
+func (d *Drain) checkPublish() error {
+	if d.isStopped {
+		return ErrDrainAlreadyStopped
+	}
+	if d.exceedsVersionResources() {
+		return ErrTooManyVersions
+	}
+	return nil
+}
+
+// rejectPublish closes a built configuration that checkPublish declined
+// @param err is the error from checkPublish
+// @return the error the reload fails with
+//
+// Assumes that the d.mu is locked, unlocks it
+func (d *Drain) rejectPublish(cv configVersion, err error) error {
+	if err == ErrDrainAlreadyStopped {
+		d.mu.Unlock()
+		d.closeConfig(cv.version, cv.config, nil, CloseReasonShutdown)
+		return err
+	}
+	// apply backpressure
+	onExceed := d.onExceedVersionResources
+	latestVersion := d.latestVersion()
+	d.mu.Unlock()
+	d.closeConfig(cv.version, cv.config, latestVersion, CloseReasonReplaced)
+	return versionResourcesError(onExceed)
+}
+
+// publish appends cv to the back of the list, making it the latest version
+// @param token is the source token cv was built from, see NewConditional
+// @return finish notifies the hooks and closes the old version if it's idle.
+//   Call it after unlocking
+//
+// Assumes that the d.mu is locked
+func (d *Drain) publish(cv *configVersion, token string, opts reloadOptions) (finish func()) {
 	// there will always be at least 1 version
 	oldCurrentVersion := d.versionTracking.Back()
 	ccv := oldCurrentVersion.Value.(*configVersion)
 	cv.version = ccv.version + 1
-	d.versionTracking.PushBack(&cv)
+	cv.meta = opts.meta
+	if opts.inherit != nil {
+		protect(CallbackSiteInherit, func() { opts.inherit(ccv.config, cv.config) })
+	}
+	d.versionTracking.PushBack(cv)
+	ccv.supersededAt = d.now()
+	d.signalRetired(ccv.version)
+	d.updateMirror(cv.config)
+	d.advanceGeneration()
+	d.recordSourceTokenLocked(token, opts)
+	if d.smoothingRate > 0 {
+		d.startSmoothing(ccv)
+	}
 
 	// if nothing is using the config on reload, ensure it's removed
 	// do this outside of the lock as the internal structure is already set
-	if d.shouldCleanup(*oldCurrentVersion.Value.(*configVersion)) {
+	closeOld := d.shouldCleanup(*ccv) && (opts.exclusive != nil || !d.startLinger(oldCurrentVersion))
+	if closeOld {
 		d.versionTracking.Remove(oldCurrentVersion)
+	}
+	retained := ccv.lingering != nil && d.retentionSizer != nil
+	h := d.hooks
+	stormRate := d.recordReload()
+
+	return func() {
+		// notify before closing so that hooks may still use the old configuration
+		h.reloaded(ccv, cv)
+		h.reloadStorm(stormRate)
+		if closeOld {
+			d.drainCompleted(ccv)
+			d.closeConfig(ccv.version, ccv.config, cv.config, CloseReasonReplaced)
+		}
+		if retained {
+			d.enforceRetentionBudget()
+		}
+	}
+}
+
+// ReLoadExclusive is like ReLoad, but guarantees that the old and new configurations
+// are never in use at the same time. This is useful when configurations cannot
+// coexist, such as when each holds an exclusive file lock.
+//
+// The new configuration is built and tested first. Then, calls to Claim block until
+// every claim on the current version has been Released, at which point the old
+// version is closed and the new version is swapped in. Blocked calls to Claim
+// receive the new version. If ctx is done before the current version drains, the
+// new configuration is closed, the old version continues to serve, and blocked
+// calls to Claim receive the old version.
+//
+// Calling ReLoadExclusive from a go routine holding a Claim will deadlock until ctx is done.
+// @param ctx bounds how long to wait for the current version to drain, and for
+//   the Drain to be unfrozen, see Freeze
+// @return err the error encountered during loader and tester, ctx.Err() if the
+//   current version did not drain or the Drain was not unfrozen in time, or
+//   ErrDrainAlreadyStopped
+func (d *Drain) ReLoadExclusive(ctx context.Context) (err error) {
+	if err = d.freezeGate.enter(ctx); err != nil {
+		return
+	}
+	defer d.freezeGate.exit()
+	return d.reLoadUnfrozen(reloadOptions{exclusive: ctx})
+}
+
+// awaitExclusive blocks calls to Claim until the current version has no claims,
+// so that the new version may be swapped in without the two coexisting
+// @param ctx bounds how long to wait for the current version to drain
+// @return open unblocks calls to Claim. Call it with the d.mu locked, after the
+//   new version is published or rejected
+// @return err ctx.Err() if the current version did not drain in time, in which
+//   case calls to Claim are already unblocked
+//
+// Assumes that the d.mu is locked, it's locked again on return
+func (d *Drain) awaitExclusive(ctx context.Context) (open func(), err error) {
+	// only one exclusive reload may wait at a time
+	for d.exclusiveGate != nil && !d.isStopped {
+		gate := d.exclusiveGate
 		d.mu.Unlock()
-		d.closer(ccv.config, cv.config)
+		<-gate
+		d.mu.Lock()
+	}
+	if d.isStopped {
+		// the publish is rejected, there is nothing to block
+		return func() {}, nil
+	}
+	gate := make(chan struct{})
+	drained := make(chan struct{})
+	d.exclusiveGate = gate
+	if d.versionTracking.Back().Value.(*configVersion).claims() == 0 {
+		close(drained)
 	} else {
-		d.mu.Unlock()
+		d.exclusiveWaitOn = d.versionTracking.Back()
+		d.exclusiveDrained = drained
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	d.mu.Lock()
+	open = func() {
+		d.exclusiveGate = nil
+		d.exclusiveWaitOn = nil
+		d.exclusiveDrained = nil
+		close(gate)
+	}
+	if err != nil {
+		open()
 	}
 	return
 }
 
+// Compact retires every version that is not the latest and has no outstanding
+// claims. Such versions are normally closed as soon as their last claim is
+// Released, so this is a manual sweep to guarantee that no drained versions are
+// retained. Versions that still have claims, or are weighted by
+// SetActiveWeights, are left alone
+func (d *Drain) Compact() {
+	d.mu.Lock()
+	d.retireIdleVersions()
+}
+
 // Stop prevents Claim calls from returning actual values
 // It's possible to call Stop and no Claims are outstanding
 // in this case, we'll clean up the last version
 func (d *Drain) Stop() {
+	_ = d.freezeGate.enter(context.Background())
+	defer d.freezeGate.exit()
+	d.stop()
+}
+
+// stop performs Stop
+//
+// Assumes that the freezeGate is entered
+func (d *Drain) stop() {
 	d.mu.Lock()
+	if !d.isStopped && d.stopped != nil {
+		close(d.stopped)
+	}
+	if !d.isStopped {
+		d.countShardedClaims()
+	}
 	d.isStopped = true
+	d.cancelContext()
+	d.signalRetired(0)
+	// weighted and lingering versions are no longer served, retire the ones that are not claimed
+	if d.activeWeights != nil || d.linger > 0 {
+		d.activeWeights = nil
+		d.activeCurrent = nil
+		d.retireIdleVersions()
+		d.mu.Lock()
+	}
 	// it's possible that all threads were done but were not
 	// cleaned up as the StopAndJoin method was called after all routines
 	// have ceased requesting Claims, in this case, we need to clean up
@@ -407,8 +1406,9 @@ func (d *Drain) Stop() {
 		// nothing using it
 		d.versionTracking.Remove(e)
 		d.mu.Unlock()
+		d.drainCompleted(e.Value.(*configVersion))
 		// unlock while calling closer, could be long
-		d.closer(e.Value.(*configVersion).config, nil)
+		d.closeConfig(e.Value.(*configVersion).version, e.Value.(*configVersion).config, nil, CloseReasonShutdown)
 	} else {
 		d.mu.Unlock()
 	}
@@ -417,6 +1417,9 @@ func (d *Drain) Stop() {
 // StopAndJoin prevents new calls to Claim from returning valid results
 // StopAndJoin will wait for outstanding routines that have Claims to call Release on those claims
 func (d *Drain) StopAndJoin() {
+	if end := d.startSpan(SpanStopAndJoin); end != nil {
+		defer end(nil)
+	}
 	// set the state, need to lock to do this
 	// unlock to allow claims to be released
 	d.Stop()
@@ -424,6 +1427,9 @@ func (d *Drain) StopAndJoin() {
 	// wait for everything to be released
 	d.closeWg.Wait()
 
+	// wait for closes triggered by the final releases
+	d.pendingCloses.Wait()
+
 	// No threads should be operating at this point
 	d.mu.Lock()
 	// it's possible that all threads were done but were not
@@ -433,13 +1439,70 @@ func (d *Drain) StopAndJoin() {
 	if e != nil && d.shouldCleanup(*e.Value.(*configVersion)) {
 		d.versionTracking.Remove(e)
 		d.mu.Unlock()
+		d.drainCompleted(e.Value.(*configVersion))
 		// unlock while calling closer, could be long
-		d.closer(e.Value.(*configVersion).config, nil)
+		d.closeConfig(e.Value.(*configVersion).version, e.Value.(*configVersion).config, nil, CloseReasonShutdown)
 	} else {
 		d.mu.Unlock()
 	}
 }
 
+// addPendingClose counts a claim in closeWg
+func (d *Drain) addPendingClose() {
+	atomic.AddInt64(&d.pendingCloseCount, 1)
+	d.closeWg.Add(1)
+}
+
+// donePendingClose counts a released claim in closeWg
+func (d *Drain) donePendingClose() {
+	atomic.AddInt64(&d.pendingCloseCount, -1)
+	d.closeWg.Done()
+}
+
+// PendingCloses is how many claims StopAndJoin is still waiting to be Released.
+// This should match TotalOutstandingClaims, a difference indicates a bug in the
+// Drain, except on a running Drain created with NewWithShardedClaims, where
+// claims counted in shards are only included once the Drain is stopped
+func (d *Drain) PendingCloses() int {
+	return int(atomic.LoadInt64(&d.pendingCloseCount))
+}
+
+// TotalOutstandingClaims counts the claims that have not been Released across all versions
+func (d *Drain) TotalOutstandingClaims() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	total := uint64(0)
+	for e := d.versionTracking.Front(); e != nil; e = e.Next() {
+		total += e.Value.(*configVersion).claims()
+	}
+	return total
+}
+
+// closeConfig calls the closer that handles the reason the configuration is being closed.
+//
+// Assumes that the d.mu is not locked
+// @param version is the version of configToClose, 0 if it was never published
+func (d *Drain) closeConfig(version uint64, configToClose interface{}, currentlyRunningConfig interface{}, reason CloseReason) {
+	closer := d.closer
+	if reason == CloseReasonShutdown && d.shutdownCloser != nil {
+		closer = d.shutdownCloser
+	}
+	if closer == nil {
+		// nothing to clean up
+		return
+	}
+	if d.closeExecutor != nil {
+		closer = d.executeCloser(closer)
+	}
+	start := d.now()
+	if d.closeTimeout > 0 {
+		d.closeWithTimeout(closer, configToClose, currentlyRunningConfig, reason)
+	} else {
+		protect(CallbackSiteCloser, func() { closer(configToClose, currentlyRunningConfig) })
+	}
+	d.closed(version, reason, d.since(start))
+}
+
 // latestVersion returns the latest version or nil, if no version exists
 // assumes that the structure is locked before calling
 // @return the configuration created by loadAndTester or nil, if no version
@@ -1,7 +1,14 @@
 package go_drain
 
 import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type myConfig struct {
@@ -115,3 +122,941 @@ func TestInterfaceImplementation(t *testing.T) {
 	drainer = &Drain{}
 	_ = drainer
 }
+
+func TestDrain_ReLoadExclusive(t *testing.T) {
+	var mu sync.Mutex
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, _ := d.Claim()
+	reloaded := make(chan error)
+	go func() {
+		reloaded <- d.ReLoadExclusive(context.Background())
+	}()
+
+	// new claims block while the old version drains
+	time.Sleep(20 * time.Millisecond)
+	claimed := make(chan ConfigClaim)
+	go func() {
+		cc, _ := d.Claim()
+		claimed <- cc
+	}()
+	select {
+	case <-reloaded:
+		t.Fatal(`expected ReLoadExclusive to wait for the outstanding claim`)
+	case <-claimed:
+		t.Fatal(`expected Claim to block while ReLoadExclusive waits`)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d.Release(&held)
+	if err = <-reloaded; err != nil {
+		t.Fatal(err)
+	}
+	cc := <-claimed
+	if cc.Config().(*myConfig).name != `v2` {
+		t.Error(`expected blocked claim to get the new version, but got: `, cc.Config().(*myConfig).name)
+	}
+	d.Release(&cc)
+
+	mu.Lock()
+	if len(closed) != 1 || closed[0] != `v1` {
+		t.Error(`expected old version to be closed before the swap, but got: `, closed)
+	}
+	mu.Unlock()
+	d.StopAndJoin()
+}
+
+func TestDrain_ReLoadExclusive_ContextDone(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reloadErr error
+	d.SetOnReloadError(func(err error) {
+		reloadErr = err
+	})
+
+	held, _ := d.Claim()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err = d.ReLoadExclusive(ctx); err != context.DeadlineExceeded {
+		t.Error(`expected deadline exceeded, but got: `, err)
+	}
+	if reloadErr != context.DeadlineExceeded {
+		t.Error(`expected the failed reload to be reported, but got: `, reloadErr)
+	}
+	if len(closed) != 1 || closed[0] != `v2` {
+		t.Error(`expected the new version to be discarded, but got: `, closed)
+	}
+	d.Release(&held)
+
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig.(*myConfig).name != `v1` {
+			t.Error(`expected old version to keep serving, but got: `, currentlyRunningConfig.(*myConfig).name)
+		}
+	})
+	d.StopAndJoin()
+}
+
+func TestDrain_ReLoadExclusive_NoLinger(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := NewWithLinger(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old version can't coexist with the new one, so it must not linger
+	if err = d.ReLoadExclusive(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(closed) != `[v1]` {
+		t.Error(`expected v1 to be closed by the swap, but got: `, closed)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_ReLoadExclusive_Stopped(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := make(map[uint64]CloseReason)
+	var mu sync.Mutex
+	d.SetCloserTracer(func(version uint64, reason CloseReason, duration time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		reasons[version] = reason
+	})
+
+	held, _ := d.Claim()
+	reloaded := make(chan error)
+	go func() {
+		reloaded <- d.ReLoadExclusive(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	d.Stop()
+	d.Release(&held)
+	if err = <-reloaded; err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+	d.StopAndJoin()
+	mu.Lock()
+	defer mu.Unlock()
+	if reasons[0] != CloseReasonShutdown {
+		t.Error(`expected the config built for a stopped drain to close for shutdown, but got: `, reasons)
+	}
+}
+
+func TestDrain_ReLoadUnchanged(t *testing.T) {
+	closeCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		if currentConfig != nil {
+			// nothing changed
+			return currentConfig, nil
+		}
+		return &myConfig{name: "chris"}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closeCalled++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, _ := d.Claim()
+	if err = d.ReLoad(); err != nil {
+		t.Fatal(err)
+	}
+	if d.versionTracking.Len() != 1 {
+		t.Error(`expected no new version, but got `, d.versionTracking.Len(), ` versions`)
+	}
+	d.Release(&held)
+	if closeCalled != 0 {
+		t.Error(`expected no close on an unchanged reload, but got `, closeCalled)
+	}
+
+	cc, _ := d.Claim()
+	if cc.Version() != 1 {
+		t.Error(`expected version to stay at 1, but got `, cc.Version())
+	}
+	d.Release(&cc)
+
+	d.StopAndJoin()
+	if closeCalled != 1 {
+		t.Error(`expected exactly one close on shutdown, but got `, closeCalled)
+	}
+}
+
+func TestDrain_ReLoadInheriting(t *testing.T) {
+	type limiterConfig struct {
+		name    string
+		counter int
+	}
+	loadCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &limiterConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		currentlyRunningConfig.(*limiterConfig).counter = 42
+	})
+
+	err = d.ReLoadInheriting(func(old, new interface{}) {
+		new.(*limiterConfig).counter = old.(*limiterConfig).counter
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		cfg := currentlyRunningConfig.(*limiterConfig)
+		if cfg.name != `v2` {
+			t.Error(`expected the new version, but got: `, cfg.name)
+		}
+		if cfg.counter != 42 {
+			t.Error(`expected the counter to be inherited, but got: `, cfg.counter)
+		}
+	})
+	d.StopAndJoin()
+}
+
+func TestDrain_ClaimIf(t *testing.T) {
+	errUnavailable := errors.New(`unavailable`)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: "chris"}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc, err := d.ClaimIf(func(config interface{}) bool {
+		return config.(*myConfig).name == "wojno"
+	}, errUnavailable)
+	if err != errUnavailable {
+		t.Error(`expected the unavailable error, but got: `, err)
+	}
+	if cc.Config() != nil {
+		t.Error(`expected the failed claim to be invalidated`)
+	}
+	if count := d.versionTracking.Back().Value.(*configVersion).count; count != 0 {
+		t.Error(`expected the failed claim to be released, but count is `, count)
+	}
+
+	cc, err = d.ClaimIf(func(config interface{}) bool {
+		return config.(*myConfig).name == "chris"
+	}, errUnavailable)
+	if err != nil {
+		t.Error(`expected the claim to succeed, but got: `, err)
+	}
+	if count := d.versionTracking.Back().Value.(*configVersion).count; count != 1 {
+		t.Error(`expected the claim to be held, but count is `, count)
+	}
+	d.Release(&cc)
+
+	// would hang if accounting is unbalanced
+	d.StopAndJoin()
+}
+
+func TestNewWithClosers(t *testing.T) {
+	loadCalled := 0
+	failLoad := false
+	replaced := make([]string, 0)
+	shutdown := make([]string, 0)
+	d, err := NewWithClosers(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		cfg := &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}
+		if failLoad {
+			return cfg, errors.New(`load failed`)
+		}
+		return cfg, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		replaced = append(replaced, configToClose.(*myConfig).name)
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		shutdown = append(shutdown, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replaced on reload
+	_ = d.ReLoad()
+	// replaced after the claim on the superseded version is released
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+	d.Release(&held)
+	// failed reload attempt
+	failLoad = true
+	_ = d.ReLoad()
+
+	if fmt.Sprint(replaced) != `[v1 v2 v4]` {
+		t.Error(`expected onReplace for v1, v2, and v4, but got: `, replaced)
+	}
+	if len(shutdown) != 0 {
+		t.Error(`expected no shutdown closes while running, but got: `, shutdown)
+	}
+
+	// shutdown while the current version is still claimed
+	held, _ = d.Claim()
+	d.Stop()
+	if len(shutdown) != 0 {
+		t.Error(`expected no shutdown close while claimed, but got: `, shutdown)
+	}
+	d.Release(&held)
+	d.StopAndJoin()
+	if fmt.Sprint(shutdown) != `[v3]` {
+		t.Error(`expected onShutdown for v3, but got: `, shutdown)
+	}
+	if len(replaced) != 3 {
+		t.Error(`expected no more onReplace calls, but got: `, replaced)
+	}
+}
+
+func TestDrain_ClaimInto(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: "chris"}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cc ConfigClaim
+	if err = d.ClaimInto(&cc); err != nil {
+		t.Fatal(err)
+	}
+	if cc.Version() != 1 || cc.Config().(*myConfig).name != "chris" {
+		t.Error(`expected ClaimInto to populate the claim`)
+	}
+	d.Release(&cc)
+
+	d.StopAndJoin()
+
+	// reuse a populated claim to ensure it is zeroed
+	cc = ConfigClaim{version: 7, config: &myConfig{}}
+	if err = d.ClaimInto(&cc); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+	if cc != (ConfigClaim{}) {
+		t.Error(`expected the claim to be zeroed, but got: `, cc)
+	}
+}
+
+func BenchmarkDrain_Claim(b *testing.B) {
+	d, _ := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cc, _ := d.Claim()
+		d.Release(&cc)
+	}
+}
+
+func BenchmarkDrain_ClaimInto(b *testing.B) {
+	d, _ := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	var cc ConfigClaim
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = d.ClaimInto(&cc)
+		d.Release(&cc)
+	}
+}
+
+func TestDrain_ClaimVersion(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+
+	// pin the draining version
+	pinned, err := d.ClaimVersion(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned.Version() != 1 || pinned.Config().(*myConfig).name != `v1` {
+		t.Error(`expected to claim v1, but got: `, pinned.Config())
+	}
+	d.Release(&held)
+	if len(closed) != 0 {
+		t.Error(`expected the pinned version not to be retired, but got: `, closed)
+	}
+	d.Release(&pinned)
+	if fmt.Sprint(closed) != `[v1]` {
+		t.Error(`expected v1 to be retired once released, but got: `, closed)
+	}
+
+	if _, err = d.ClaimVersion(1); err != ErrVersionNotAvailable {
+		t.Error(`expected ErrVersionNotAvailable, but got: `, err)
+	}
+	if _, err = d.ClaimVersion(3); err != ErrVersionNotAvailable {
+		t.Error(`expected ErrVersionNotAvailable, but got: `, err)
+	}
+
+	d.StopAndJoin()
+	if _, err = d.ClaimVersion(2); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+}
+
+func TestDrain_Compact(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	// lingering keeps drained versions open until Compact retires them
+	d, err := NewWithLinger(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig.(*myConfig).name != `v3` {
+			t.Error(`expected to compare against the current config, but got: `, currentlyRunningConfig.(*myConfig).name)
+		}
+		closed = append(closed, configToClose.(*myConfig).name)
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drained, _ := d.Claim()
+	_ = d.ReLoad()
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+
+	// no-op while every superseded version is claimed
+	d.Compact()
+	if len(closed) != 0 {
+		t.Error(`expected claimed versions to be kept, but got: `, closed)
+	}
+
+	// v1 is drained, but retained as it lingers
+	d.Release(&drained)
+	if len(closed) != 0 {
+		t.Error(`expected the drained v1 to linger, but got: `, closed)
+	}
+	if _, live := d.MetaForVersion(1); !live {
+		t.Error(`expected v1 to be retained while lingering`)
+	}
+
+	d.Compact()
+	if fmt.Sprint(closed) != `[v1]` {
+		t.Error(`expected the lingering v1 to be closed, but got: `, closed)
+	}
+	for version, expected := range map[uint64]bool{1: false, 2: true, 3: true} {
+		if _, live := d.MetaForVersion(version); live != expected {
+			t.Error(`expected v`, version, ` to be live: `, expected, `, but got: `, live)
+		}
+	}
+
+	d.Release(&held)
+	d.Compact()
+	if fmt.Sprint(closed) != `[v1 v2]` {
+		t.Error(`expected v2 to close once drained and compacted, but got: `, closed)
+	}
+	if _, live := d.MetaForVersion(2); live {
+		t.Error(`expected only the current version to remain`)
+	}
+	assertBalanced(t, d)
+}
+
+func TestDrain_ReleaseCurrentVersion(t *testing.T) {
+	closeCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closeCalled++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := d.Claim()
+	second, _ := d.Claim()
+	d.Release(&first)
+	d.Release(&second)
+
+	// the current version is never closed while running
+	if closeCalled != 0 {
+		t.Error(`expected releasing the current version not to close it, but closed `, closeCalled, ` times`)
+	}
+	if count := d.versionTracking.Back().Value.(*configVersion).count; count != 0 {
+		t.Error(`expected no outstanding claims, but got `, count)
+	}
+
+	// closeWg is balanced, so StopAndJoin will not block
+	joined := make(chan struct{})
+	go func() {
+		d.StopAndJoin()
+		close(joined)
+	}()
+	select {
+	case <-joined:
+	case <-time.After(time.Second):
+		t.Fatal(`expected StopAndJoin to return, claims are unbalanced`)
+	}
+	if closeCalled != 1 {
+		t.Error(`expected exactly one close on shutdown, but got `, closeCalled)
+	}
+}
+
+func TestDrain_ClaimBeforeFirstLoad(t *testing.T) {
+	// a Drain that has not performed its first load
+	d := &Drain{
+		versionTracking: list.New(),
+	}
+	cc, err := d.Claim()
+	if err != ErrNoConfigYet {
+		t.Error(`expected ErrNoConfigYet, but got: `, err)
+	}
+	if cc.Config() != nil || cc.Version() != 0 {
+		t.Error(`expected an invalid claim, but got: `, cc)
+	}
+	if err = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		t.Error(`expected the closure not to be called without a config`)
+	}); err != ErrNoConfigYet {
+		t.Error(`expected ErrNoConfigYet, but got: `, err)
+	}
+	assertBalanced(t, d)
+}
+
+func TestDrain_ReLoadWithMeta(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta, ok := d.MetaForVersion(1); !ok || meta != nil {
+		t.Error(`expected no metadata on the initial version, but got: `, meta, ok)
+	}
+
+	if err = d.ReLoadWithMeta(`git:abc123`); err != nil {
+		t.Fatal(err)
+	}
+	cc, _ := d.Claim()
+	if cc.Meta() != `git:abc123` {
+		t.Error(`expected the claim to carry the metadata, but got: `, cc.Meta())
+	}
+	if meta, ok := d.MetaForVersion(cc.Version()); !ok || meta != `git:abc123` {
+		t.Error(`expected to look up the metadata, but got: `, meta, ok)
+	}
+	d.Release(&cc)
+
+	if _, ok := d.MetaForVersion(1); ok {
+		t.Error(`expected the retired version to have no metadata`)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_ReLoadTransform(t *testing.T) {
+	type workerConfig struct {
+		workers int
+	}
+	loadCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &workerConfig{workers: 5}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, _ := d.Claim()
+
+	err = d.ReLoadTransform(func(current interface{}) (interface{}, error) {
+		return &workerConfig{workers: current.(*workerConfig).workers + 10}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadCalled != 1 {
+		t.Error(`expected loadAndTester not to be called, but it was called `, loadCalled, ` times`)
+	}
+	cc, _ := d.Claim()
+	if cc.Version() != 2 || cc.Config().(*workerConfig).workers != 15 {
+		t.Error(`expected the transformed config in a new version, but got: `, cc.Version(), cc.Config())
+	}
+	if held.Config().(*workerConfig).workers != 5 {
+		t.Error(`expected the old config to be untouched, but got: `, held.Config())
+	}
+	d.Release(&cc)
+
+	// a failed transform keeps the current version
+	failed := errors.New(`transform failed`)
+	err = d.ReLoadTransform(func(current interface{}) (interface{}, error) {
+		return nil, failed
+	})
+	if err != failed || d.CurrentVersion() != 2 {
+		t.Error(`expected the transform error and no new version, but got: `, err, d.CurrentVersion())
+	}
+	d.Release(&held)
+	d.StopAndJoin()
+}
+
+func TestDrain_ReLoadTransformConditional(t *testing.T) {
+	token := `etag1`
+	loadCalled := 0
+	d, err := NewConditional(func() (string, error) {
+		return token, nil
+	}, func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: `loaded`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the source has not changed, but the transform does not read it
+	if err = d.ReLoadTransform(func(current interface{}) (interface{}, error) {
+		return &myConfig{name: `transformed`}, nil
+	}); err != nil {
+		t.Error(`expected the transform to run on an unchanged source, but got: `, err)
+	}
+	if d.CurrentVersion() != 2 {
+		t.Error(`expected the transformed config to be published, but got version `, d.CurrentVersion())
+	}
+
+	// the transform did not record the changed source as loaded
+	token = `etag2`
+	if err = d.ReLoadTransform(func(current interface{}) (interface{}, error) {
+		return &myConfig{name: `transformed again`}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.ReLoad(); err != nil || loadCalled != 2 {
+		t.Error(`expected the changed source to be loaded, but got: `, err, loadCalled)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_ReLoadTransformValidated(t *testing.T) {
+	errTooMany := errors.New(`too many workers`)
+	d, err := NewWithValidators(func(currentConfig interface{}) (config interface{}, err error) {
+		return 5, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, []func(config interface{}) error{
+		func(config interface{}) error {
+			if config.(int) > 10 {
+				return errTooMany
+			}
+			return nil
+		},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.ReLoadTransform(func(current interface{}) (interface{}, error) {
+		return current.(int) + 10, nil
+	})
+	var qErr *QuorumError
+	if !errors.As(err, &qErr) || qErr.Failures[0] != errTooMany {
+		t.Error(`expected the transformed config to fail validation, but got: `, err)
+	}
+	if d.CurrentVersion() != 1 {
+		t.Error(`expected the current version to keep serving, but got version `, d.CurrentVersion())
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_ReLoadAfterStop(t *testing.T) {
+	loaded := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.StopAndJoin()
+	if err = d.ReLoad(); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+	if loaded != 1 {
+		t.Error(`expected nothing to be built after stop, but loaded `, loaded, ` times`)
+	}
+}
+
+func TestDrain_ReleaseForeignClaim(t *testing.T) {
+	closed := make([]string, 0)
+	newNamed := func(name string) *Drain {
+		d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+			return &myConfig{name: name}, nil
+		}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+			closed = append(closed, configToClose.(*myConfig).name)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	a := newNamed(`a`)
+	b := newNamed(`b`)
+
+	held, _ := b.Claim()
+	foreign, _ := a.Claim()
+	// both are version 1, but b must not count a's claim
+	b.Release(&foreign)
+	if b.TotalOutstandingClaims() != 1 || b.PendingCloses() != 1 {
+		t.Error(`expected b's claim to still be outstanding, but got: `, b.TotalOutstandingClaims(), b.PendingCloses())
+	}
+	a.Release(&foreign)
+	b.Release(&held)
+
+	a.StopAndJoin()
+	b.StopAndJoin()
+	if fmt.Sprint(closed) != `[a b]` {
+		t.Error(`expected each config to be closed once, but got: `, closed)
+	}
+	assertBalanced(t, a)
+	assertBalanced(t, b)
+}
+
+func TestDrain_FailedReLoadDuringClaims(t *testing.T) {
+	errLoad := errors.New(`load failed`)
+	loaded := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		if loaded > 1 && loaded%2 == 0 {
+			// a partially built config that must be closed
+			return &myConfig{name: `partial`}, errLoad
+		}
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {})
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		_ = d.ReLoad()
+	}
+	wg.Wait()
+	d.StopAndJoin()
+	assertBalanced(t, d)
+}
+
+func TestDrain_NilCloser(t *testing.T) {
+	fail := false
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		if fail {
+			return &myConfig{name: `partial`}, errors.New(`load failed`)
+		}
+		return &myConfig{name: `v`}, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = d.ReLoad()
+	fail = true
+	if err = d.ReLoad(); err == nil {
+		t.Error(`expected the load error`)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_StopDuringReLoad(t *testing.T) {
+	building := make(chan struct{})
+	finish := make(chan struct{})
+	loaded := 0
+	replaced := make([]string, 0)
+	shutdown := make([]string, 0)
+	d, err := NewWithClosers(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		if loaded == 2 {
+			close(building)
+			<-finish
+		}
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		replaced = append(replaced, configToClose.(*myConfig).name)
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		shutdown = append(shutdown, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan error)
+	go func() {
+		reloaded <- d.ReLoad()
+	}()
+	<-building
+	d.Stop()
+	close(finish)
+	if err = <-reloaded; err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+	d.StopAndJoin()
+	if len(replaced) != 0 || fmt.Sprint(shutdown) != `[v1 v2]` {
+		t.Error(`expected both configs to be shut down, but got: `, replaced, shutdown)
+	}
+	if d.CurrentVersion() != 0 {
+		t.Error(`expected nothing to be published, but got: `, d.CurrentVersion())
+	}
+	assertBalanced(t, d)
+}
+
+type closableConfig struct {
+	version int
+	closed  int32
+}
+
+func TestDrain_NoClaimOfClosingConfig(t *testing.T) {
+	loaded := 0
+	d, err := NewWithClaimSmoothing(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &closableConfig{version: loaded}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		if !atomic.CompareAndSwapInt32(&configToClose.(*closableConfig).closed, 0, 1) {
+			t.Error(`expected each config to be closed once`)
+		}
+		// widen the window between unlocking and closing
+		time.Sleep(time.Millisecond)
+	}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(cc ConfigClaim) {
+		if atomic.LoadInt32(&cc.Config().(*closableConfig).closed) != 0 {
+			t.Error(`expected a claimed config not to be closed, but version `, cc.Version(), ` was`)
+		}
+	}
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				cc, err := d.Claim()
+				if err != nil {
+					return
+				}
+				check(cc)
+				if i%2 == 0 {
+					// pin an older version, if it's still around
+					if older, err := d.ClaimVersion(cc.Version() - 1); err == nil {
+						check(older)
+						d.Release(&older)
+					}
+				}
+				check(cc)
+				d.Release(&cc)
+			}
+		}(i)
+	}
+	for i := 0; i < 200; i++ {
+		_ = d.ReLoad()
+		if i%50 == 0 {
+			d.Compact()
+		}
+	}
+	close(stop)
+	wg.Wait()
+	d.StopAndJoin()
+	assertBalanced(t, d)
+}
+
+func TestConfigClaim_ConfigOK(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc, _ := d.Claim()
+	if !cc.IsValid() {
+		t.Error(`expected a claim on a running Drain to be valid`)
+	}
+	if cfg, ok := cc.ConfigOK(); !ok || cfg.(*myConfig).name != `v1` {
+		t.Error(`expected the configuration of a valid claim, got `, cfg, ok)
+	}
+
+	var zero ConfigClaim
+	if zero.IsValid() {
+		t.Error(`expected a zero claim to be invalid`)
+	}
+	if cfg, ok := zero.ConfigOK(); ok || cfg != nil {
+		t.Error(`expected no configuration from a zero claim, got `, cfg, ok)
+	}
+
+	invalidated := cc
+	invalidated.Invalidate()
+	if invalidated.IsValid() {
+		t.Error(`expected an invalidated claim to be invalid`)
+	}
+	if cfg, ok := invalidated.ConfigOK(); ok || cfg != nil {
+		t.Error(`expected no configuration from an invalidated claim, got `, cfg, ok)
+	}
+
+	// released claims are invalidated
+	d.Release(&cc)
+	if cc.IsValid() {
+		t.Error(`expected a released claim to be invalid`)
+	}
+
+	d.StopAndJoin()
+	stopped, _ := d.Claim()
+	if _, ok := stopped.ConfigOK(); ok {
+		t.Error(`expected the claim of a stopped Drain to be invalid`)
+	}
+}
@@ -0,0 +1,22 @@
+// Package draintest provides helpers for testing code that uses a go_drain.Drain
+package draintest
+
+import (
+	"testing"
+
+	"github.com/wojnosystems/go_drain"
+)
+
+// AssertBalanced fails the test if the Drain has any claims that have not been
+// Released. Call it at the end of tests that use a Drain to catch claim leaks
+// @param t is the test to fail
+// @param d is the Drain to check
+func AssertBalanced(t testing.TB, d *go_drain.Drain) {
+	t.Helper()
+	if outstanding := d.TotalOutstandingClaims(); outstanding != 0 {
+		t.Errorf(`drain has %d outstanding claims, expected all claims to be released`, outstanding)
+	}
+	if pending := d.PendingCloses(); pending != 0 {
+		t.Errorf(`drain has %d pending closes, expected all claims to be released`, pending)
+	}
+}
@@ -0,0 +1,49 @@
+package draintest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/wojnosystems/go_drain"
+)
+
+// recordingTB captures failures instead of failing the test
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertBalanced(t *testing.T) {
+	d, err := go_drain.New(func(currentConfig interface{}) (config interface{}, err error) {
+		return struct{}{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc, _ := d.Claim()
+	d.Release(&cc)
+	AssertBalanced(t, d)
+
+	// leak a claim
+	leaked, _ := d.Claim()
+	_ = d.ReLoad()
+	rec := &recordingTB{TB: t}
+	AssertBalanced(rec, d)
+	if len(rec.errors) != 2 {
+		t.Error(`expected the leaked claim to be reported twice, but got: `, rec.errors)
+	}
+	if d.TotalOutstandingClaims() != 1 || d.PendingCloses() != 1 {
+		t.Error(`expected one outstanding claim, but got `, d.TotalOutstandingClaims(), ` and `, d.PendingCloses())
+	}
+
+	d.Release(&leaked)
+	AssertBalanced(t, d)
+	d.StopAndJoin()
+}
@@ -0,0 +1,29 @@
+package go_drain
+
+// NewWithEmbeddedCloser is New, but configurations carry their own teardown,
+// such as a struct with a CloseFn field populated by loadAndTest, rather than
+// being closed by a separate CloserFunc. Whenever the Drain would call the
+// closer, it calls the close function extracted from the configuration instead
+// @param extract gets the configuration's own close function. It's never called
+//   with a nil configuration. Return nil if there is nothing to close, such as
+//   for a configuration that failed to load before its close function was set
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithEmbeddedCloser(
+	loadAndTest LoadAndTesterFunc,
+	extract func(config interface{}) func(),
+) (c *Drain, err error) {
+	return New(loadAndTest, embeddedCloser(extract))
+}
+
+// embeddedCloser makes a CloserFunc that calls the close function extracted from each configuration
+func embeddedCloser(extract func(config interface{}) func()) CloserFunc {
+	return func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		if configToClose == nil {
+			return
+		}
+		if closeFn := extract(configToClose); closeFn != nil {
+			closeFn()
+		}
+	}
+}
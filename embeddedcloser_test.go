@@ -0,0 +1,53 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// selfClosingConfig carries its own close function
+type selfClosingConfig struct {
+	CloseFn func()
+}
+
+func TestNewWithEmbeddedCloser(t *testing.T) {
+	loaded := 0
+	closed := make([]string, 0)
+	d, err := NewWithEmbeddedCloser(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		name := fmt.Sprintf(`v%d`, loaded)
+		switch loaded {
+		case 3:
+			// failed before the close function was set
+			return &selfClosingConfig{}, errors.New(`load failed`)
+		case 4:
+			return nil, errors.New(`load failed`)
+		}
+		return &selfClosingConfig{CloseFn: func() {
+			closed = append(closed, name)
+		}}, nil
+	}, func(config interface{}) func() {
+		return config.(*selfClosingConfig).CloseFn
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.ReLoad(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(closed) != `[v1]` {
+		t.Error(`expected the replaced config to close itself, but got: `, closed)
+	}
+	if err = d.ReLoad(); err == nil {
+		t.Error(`expected the reload to fail`)
+	}
+	if err = d.ReLoad(); err == nil {
+		t.Error(`expected the reload to fail`)
+	}
+	d.StopAndJoin()
+	if fmt.Sprint(closed) != `[v1 v2]` {
+		t.Error(`expected the current config to close itself on shutdown, but got: `, closed)
+	}
+}
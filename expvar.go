@@ -0,0 +1,32 @@
+package go_drain
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+)
+
+// ErrExpvarExists is returned by PublishExpvar when a variable with the name is already published
+var ErrExpvarExists = errors.New(`expvar already published`)
+
+// publishExpvarMu serializes PublishExpvar so that checking for and publishing a name is atomic
+var publishExpvarMu sync.Mutex
+
+// PublishExpvar publishes the Drain's Stats as JSON under name with the
+// expvar package, so they're served by the standard /debug/vars endpoint
+// without any other dependencies. The Stats are read each time the variable is
+// served. expvar variables cannot be removed, so publish each Drain once
+// @param name is the name of the variable
+// @param d is the Drain to publish
+// @return ErrExpvarExists if a variable is already published under name, nil otherwise
+func PublishExpvar(name string, d *Drain) error {
+	publishExpvarMu.Lock()
+	defer publishExpvarMu.Unlock()
+	if expvar.Get(name) != nil {
+		return ErrExpvarExists
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return d.Stats()
+	}))
+	return nil
+}
@@ -0,0 +1,46 @@
+package go_drain
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// expvarNames counts the names published by tests, as expvar can't unpublish
+// them and go test -count reruns tests in the same process
+var expvarNames uint64
+
+// uniqueExpvarName gets a name for t that isn't published yet
+func uniqueExpvarName(t *testing.T) string {
+	return fmt.Sprintf(`%s#%d`, t.Name(), atomic.AddUint64(&expvarNames, 1))
+}
+
+func TestPublishExpvar(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := uniqueExpvarName(t)
+	if err = PublishExpvar(name, d); err != nil {
+		t.Fatal(err)
+	}
+	if err = PublishExpvar(name, d); err != ErrExpvarExists {
+		t.Error(`expected the name collision to be reported, but got: `, err)
+	}
+
+	_ = d.ReLoad()
+	held, _ := d.Claim()
+	var stats Stats
+	if err = json.Unmarshal([]byte(expvar.Get(name).String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.CurrentVersion != 2 || stats.OutstandingClaims != 1 {
+		t.Error(`expected the published stats to reflect the drain, but got: `, stats)
+	}
+	d.Release(&held)
+	d.StopAndJoin()
+}
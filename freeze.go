@@ -0,0 +1,127 @@
+package go_drain
+
+import (
+	"context"
+	"sync"
+)
+
+// freezeGate keeps reloads and stops from running while a Drain is frozen. It
+// works like a sync.RWMutex: reloads and stops share the read side, Freeze
+// takes the write side. Unlike sync.RWMutex, a pending Freeze does not block
+// new readers, so a hook that reloads from within a reload cannot deadlock
+// with it
+type freezeGate struct {
+	// freezers serializes Freeze calls, it's held from Freeze until Unfreeze
+	freezers sync.Mutex
+
+	// mu guards the fields below
+	mu sync.Mutex
+
+	// active counts the reloads and stops in progress
+	active int
+
+	// idle is closed when active drops to 0 to wake up a pending Freeze. nil if none is pending
+	idle chan struct{}
+
+	// thawed is closed by Unfreeze to wake up blocked reloads and stops. nil if not frozen
+	thawed chan struct{}
+}
+
+// enter waits until the Drain is not frozen, then counts a reload or stop in
+// progress. Call exit once it's done
+// @return ctx.Err() if ctx was done while frozen, nil if entered
+func (g *freezeGate) enter(ctx context.Context) error {
+	for {
+		g.mu.Lock()
+		if g.thawed == nil {
+			g.active++
+			g.mu.Unlock()
+			return nil
+		}
+		thawed := g.thawed
+		g.mu.Unlock()
+		select {
+		case <-thawed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// exit counts a reload or stop as done
+func (g *freezeGate) exit() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active--
+	if g.active == 0 && g.idle != nil {
+		close(g.idle)
+		g.idle = nil
+	}
+}
+
+// Freeze keeps the current version from being superseded or shut down, for
+// administrative operations that need a stable configuration for an extended
+// period. This is stronger than a claim, which does not prevent reloads. While
+// frozen, ReLoad and its variants, ReLoadExclusive, ReLoadTogether, Stop, and
+// StopAndJoin block until Unfreeze is called. Use ReLoadContext or StopContext
+// to bound how long they wait.
+//
+// Freeze waits for reloads and stops that are in progress to return. Only one
+// caller may hold the Drain frozen at a time, others block in Freeze until it's
+// unfrozen
+func (d *Drain) Freeze() {
+	g := &d.freezeGate
+	g.freezers.Lock()
+	for {
+		g.mu.Lock()
+		if g.active == 0 {
+			g.thawed = make(chan struct{})
+			g.mu.Unlock()
+			return
+		}
+		if g.idle == nil {
+			g.idle = make(chan struct{})
+		}
+		idle := g.idle
+		g.mu.Unlock()
+		<-idle
+	}
+}
+
+// Unfreeze undoes Freeze, letting blocked reloads and stops proceed. As with
+// sync.RWMutex, it's a run-time error to call Unfreeze on a Drain that is not frozen
+func (d *Drain) Unfreeze() {
+	g := &d.freezeGate
+	g.mu.Lock()
+	if g.thawed != nil {
+		close(g.thawed)
+		g.thawed = nil
+	}
+	g.mu.Unlock()
+	g.freezers.Unlock()
+}
+
+// ReLoadContext is ReLoad, but gives up waiting for the Drain to be unfrozen
+// when ctx is done, see Freeze
+// @return err ctx.Err() if ctx was done while the Drain was frozen, or the
+//   error from ReLoad
+func (d *Drain) ReLoadContext(ctx context.Context) (err error) {
+	if err = d.freezeGate.enter(ctx); err != nil {
+		return
+	}
+	defer d.freezeGate.exit()
+	return d.reLoadUnfrozen(reloadOptions{})
+}
+
+// StopContext is Stop, but gives up waiting for the Drain to be unfrozen when
+// ctx is done, see Freeze
+// @return ctx.Err() if ctx was done while the Drain was frozen and it was not
+//   stopped, nil if it was stopped
+func (d *Drain) StopContext(ctx context.Context) error {
+	if err := d.freezeGate.enter(ctx); err != nil {
+		return err
+	}
+	defer d.freezeGate.exit()
+	d.stop()
+	return nil
+}
@@ -0,0 +1,88 @@
+package go_drain
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDrain_Freeze(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Freeze()
+	reloaded := make(chan error, 1)
+	go func() {
+		reloaded <- d.ReLoad()
+	}()
+	select {
+	case err = <-reloaded:
+		t.Error(`expected the reload to block while frozen, but got: `, err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if d.CurrentVersion() != 1 {
+		t.Error(`expected no new version while frozen, but got: `, d.CurrentVersion())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	if err = d.StopContext(ctx); err != context.DeadlineExceeded {
+		t.Error(`expected the stop to give up while frozen, but got: `, err)
+	}
+	cancel()
+
+	d.Unfreeze()
+	select {
+	case err = <-reloaded:
+		if err != nil {
+			t.Error(`expected the reload to succeed once unfrozen, but got: `, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal(`expected the reload to proceed once unfrozen`)
+	}
+	if d.CurrentVersion() != 2 {
+		t.Error(`expected a new version once unfrozen, but got: `, d.CurrentVersion())
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_Freeze_WaitsForReloads(t *testing.T) {
+	building := make(chan struct{})
+	proceed := make(chan struct{})
+	loadCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		if loadCalled == 2 {
+			close(building)
+			<-proceed
+		}
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = d.ReLoad()
+	}()
+	<-building
+	frozen := make(chan struct{})
+	go func() {
+		d.Freeze()
+		close(frozen)
+	}()
+	select {
+	case <-frozen:
+		t.Error(`expected Freeze to wait for the reload in progress`)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(proceed)
+	<-frozen
+	if d.CurrentVersion() != 2 {
+		t.Error(`expected the reload to have finished, but got: `, d.CurrentVersion())
+	}
+	d.Unfreeze()
+	d.StopAndJoin()
+}
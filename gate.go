@@ -0,0 +1,64 @@
+package go_drain
+
+// SetReloadGate sets a policy that is checked at the start of every reload,
+// before anything is built. If the gate returns an error, the reload is aborted
+// with that error and the current version keeps serving. This keeps new
+// versions from piling on top of a congested Drain, for example by rejecting
+// reloads while TotalOutstandingClaims is above a limit. It applies to ReLoad
+// and its variants, ReLoadExclusive, and ReLoadTogether
+// @param gate is called without the lock. Return nil to allow the reload. Pass
+//   nil to allow every reload
+func (d *Drain) SetReloadGate(gate func() error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reloadGate = gate
+}
+
+// checkReloadGate asks the gate set by SetReloadGate whether a reload may proceed
+// @return the error returned by the gate, ErrCallbackPanicked if it panicked,
+//   or nil if the reload may proceed
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) checkReloadGate() (err error) {
+	d.mu.Lock()
+	gate := d.reloadGate
+	d.mu.Unlock()
+	if gate == nil {
+		return nil
+	}
+	if protect(CallbackSiteReloadGate, func() { err = gate() }) {
+		err = ErrCallbackPanicked
+	}
+	return
+}
+
+// ReLoadGated is ReLoad, but once the new configuration is built and tested, it
+// is not published until ready confirms it. This inserts an external gate
+// between building and serving, such as waiting for a service mesh to register
+// the new endpoint. If ready returns an error, the new configuration is closed,
+// the current version keeps serving, and the reload fails with that error.
+// ready is not called if the new configuration is unchanged
+// @param ready is called without the lock with the new configuration. Return nil to publish it
+// @return err the error returned by ready, ErrCallbackPanicked if it panicked,
+//   or any error from reloading
+func (d *Drain) ReLoadGated(ready func(newConfig interface{}) error) (err error) {
+	return d.reLoad(reloadOptions{ready: ready})
+}
+
+// confirmReady asks ready whether cv may be published, closing cv if not
+// @return the error returned by ready, ErrCallbackPanicked if it panicked, or
+//   nil if cv may be published
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) confirmReady(cv configVersion, ready func(newConfig interface{}) error) (err error) {
+	if protect(CallbackSiteReady, func() { err = ready(cv.config) }) {
+		err = ErrCallbackPanicked
+	}
+	if err != nil {
+		d.mu.Lock()
+		latestVersion := d.latestVersion()
+		d.mu.Unlock()
+		d.closeConfig(cv.version, cv.config, latestVersion, CloseReasonReplaced)
+	}
+	return
+}
@@ -0,0 +1,97 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDrain_SetReloadGate(t *testing.T) {
+	loadCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCongested := errors.New(`congested`)
+	d.SetReloadGate(func() error {
+		if d.TotalOutstandingClaims() > 1 {
+			return errCongested
+		}
+		return nil
+	})
+
+	claims := make([]ConfigClaim, 2)
+	for i := range claims {
+		claims[i], _ = d.Claim()
+	}
+	if err = d.ReLoad(); err != errCongested {
+		t.Error(`expected the gate to reject the reload, but got: `, err)
+	}
+	if loadCalled != 1 || d.CurrentVersion() != 1 {
+		t.Error(`expected nothing to be built, but got: `, loadCalled, d.CurrentVersion())
+	}
+	if err = ReLoadTogether(d); err != errCongested {
+		t.Error(`expected the gate to reject the reload together, but got: `, err)
+	}
+
+	// claims drop below the limit
+	d.Release(&claims[0])
+	if err = d.ReLoad(); err != nil {
+		t.Error(`expected the gate to allow the reload, but got: `, err)
+	}
+	if loadCalled != 2 || d.CurrentVersion() != 2 {
+		t.Error(`expected a new version, but got: `, loadCalled, d.CurrentVersion())
+	}
+	d.Release(&claims[1])
+	d.StopAndJoin()
+}
+
+func TestDrain_ReLoadGated(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errNotRegistered := errors.New(`not registered`)
+	var confirming interface{}
+	err = d.ReLoadGated(func(newConfig interface{}) error {
+		confirming = newConfig
+		return errNotRegistered
+	})
+	if err != errNotRegistered {
+		t.Error(`expected the reload to fail with the error of ready, but got: `, err)
+	}
+	if confirming == nil || confirming.(*myConfig).name != `v2` {
+		t.Error(`expected ready to confirm the new configuration, but got: `, confirming)
+	}
+	if fmt.Sprint(closed) != `[v2]` {
+		t.Error(`expected the unconfirmed configuration to be closed, but closed: `, closed)
+	}
+	cc, _ := d.Claim()
+	if cc.Version() != 1 || cc.Config().(*myConfig).name != `v1` {
+		t.Error(`expected the old version to keep serving, but got: `, cc.Version())
+	}
+	d.Release(&cc)
+
+	if err = d.ReLoadGated(func(newConfig interface{}) error {
+		return nil
+	}); err != nil {
+		t.Error(`expected the confirmed reload to succeed, but got: `, err)
+	}
+	cc, _ = d.Claim()
+	if cc.Config().(*myConfig).name != `v3` {
+		t.Error(`expected the confirmed configuration to be published, but got: `, cc.Config())
+	}
+	d.Release(&cc)
+	d.StopAndJoin()
+}
@@ -0,0 +1,57 @@
+package go_drain
+
+import "context"
+
+// Generation gets the number of successful reloads. Unlike versions, the
+// generation is a simple monotonic count: it starts at 0 after New, advances by
+// one each time a reload publishes a new version, and is not reset by Reset
+// @return the number of successful reloads
+func (d *Drain) Generation() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.generation
+}
+
+// WaitForGeneration blocks until at least gen reloads have succeeded, such as
+// to wait until the reload you triggered elsewhere has been published
+// @param ctx bounds how long to wait
+// @param gen is the generation to wait for
+// @return nil once Generation reaches gen, ErrDrainAlreadyStopped if the Drain
+//   is or becomes stopped first, or ctx.Err() if ctx is done first
+func (d *Drain) WaitForGeneration(ctx context.Context, gen uint64) error {
+	for {
+		d.mu.Lock()
+		if d.generation >= gen {
+			d.mu.Unlock()
+			return nil
+		}
+		if d.isStopped {
+			d.mu.Unlock()
+			return ErrDrainAlreadyStopped
+		}
+		if d.generationChanged == nil {
+			d.generationChanged = make(chan struct{})
+		}
+		changed := d.generationChanged
+		stopped := d.stopped
+		d.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// advanceGeneration counts a successful reload and wakes up WaitForGeneration
+//
+// Assumes that the d.mu is locked
+func (d *Drain) advanceGeneration() {
+	d.generation++
+	if d.generationChanged != nil {
+		close(d.generationChanged)
+		d.generationChanged = nil
+	}
+}
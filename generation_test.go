@@ -0,0 +1,63 @@
+package go_drain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDrain_WaitForGeneration(t *testing.T) {
+	fail := false
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		if fail {
+			return nil, errors.New(`load failed`)
+		}
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Generation() != 0 {
+		t.Error(`expected generation 0 after New, but got: `, d.Generation())
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- d.WaitForGeneration(context.Background(), 2)
+	}()
+	_ = d.ReLoad()
+	select {
+	case <-done:
+		t.Fatal(`expected the wait to continue until generation 2`)
+	case <-time.After(10 * time.Millisecond):
+	}
+	_ = d.ReLoad()
+	if err = <-done; err != nil {
+		t.Error(`expected the wait to succeed, but got: `, err)
+	}
+
+	fail = true
+	_ = d.ReLoad()
+	if d.Generation() != 2 {
+		t.Error(`expected failed reloads not to advance the generation, but got: `, d.Generation())
+	}
+	if err = d.WaitForGeneration(context.Background(), 1); err != nil {
+		t.Error(`expected reached generations to return at once, but got: `, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = d.WaitForGeneration(ctx, 3); err != context.DeadlineExceeded {
+		t.Error(`expected the wait to time out, but got: `, err)
+	}
+
+	go func() {
+		done <- d.WaitForGeneration(context.Background(), 3)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	d.StopAndJoin()
+	if err = <-done; err != ErrDrainAlreadyStopped {
+		t.Error(`expected stopping to end the wait, but got: `, err)
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/wojnosystems/go_drain
 
-go 1.12
+go 1.18
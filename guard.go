@@ -0,0 +1,102 @@
+package go_drain
+
+import (
+	"log"
+	"runtime"
+	"sync/atomic"
+)
+
+// Guard is a claim that is Released even if the caller forgets to, as a safety
+// net against leaked claims in code that is hard to audit. If a Guard is
+// garbage collected without being Released, its claim is Released by a
+// finalizer and the leak is reported. Finalizers run at the garbage collector's
+// discretion, possibly long after the Guard was dropped, so always call Release
+type Guard struct {
+	// d is the Drain the claim was made on
+	d *Drain
+
+	// claim is the guarded claim
+	claim ConfigClaim
+
+	// released is 1 once the claim was Released. Accessed atomically
+	released int32
+}
+
+// GuardedClaim is Claim, but the claim is wrapped in a Guard
+// @return g the guard, which must be Released, or nil if there was an error
+// @return err the error returned by Claim
+func GuardedClaim(d *Drain) (g *Guard, err error) {
+	cc, err := d.Claim()
+	if err != nil {
+		return nil, err
+	}
+	g = &Guard{d: d, claim: cc}
+	runtime.SetFinalizer(g, (*Guard).leaked)
+	return g, nil
+}
+
+// Config gets the claimed configuration
+// @return the configuration, or nil once the Guard is Released
+func (g *Guard) Config() interface{} {
+	if atomic.LoadInt32(&g.released) != 0 {
+		return nil
+	}
+	return g.claim.Config()
+}
+
+// Version gets the claimed version
+// @return the version, or 0 once the Guard is Released
+func (g *Guard) Version() uint64 {
+	if atomic.LoadInt32(&g.released) != 0 {
+		return 0
+	}
+	return g.claim.Version()
+}
+
+// Release releases the claim and cancels the finalizer. It's safe to call
+// more than once, calls after the first do nothing
+func (g *Guard) Release() {
+	if g.release() {
+		runtime.SetFinalizer(g, nil)
+	}
+}
+
+// release releases the claim if it has not been already
+// @return true if this call released it
+func (g *Guard) release() bool {
+	if !atomic.CompareAndSwapInt32(&g.released, 0, 1) {
+		return false
+	}
+	g.d.Release(&g.claim)
+	return true
+}
+
+// leaked releases the claim of a Guard that was garbage collected without
+// being Released and reports it to the callback set by SetOnGuardLeaked, or
+// logs a warning if there is none
+func (g *Guard) leaked() {
+	version := g.claim.Version()
+	if !g.release() {
+		return
+	}
+	g.d.mu.Lock()
+	onGuardLeaked := g.d.hooks.onGuardLeaked
+	g.d.mu.Unlock()
+	if onGuardLeaked == nil {
+		log.Printf(`go_drain: a Guard of version %d was garbage collected without being Released`, version)
+		return
+	}
+	protect(CallbackSiteOnGuardLeaked, func() { onGuardLeaked(version) })
+}
+
+// SetOnGuardLeaked sets the callback that is notified when a Guard made by
+// GuardedClaim is garbage collected without being Released, after its claim is
+// Released on its behalf. Without a callback, a warning is logged with the
+// standard logger
+// @param onGuardLeaked receives the version the Guard claimed. It's called from
+//   the finalizer go routine. Pass nil to log instead
+func (d *Drain) SetOnGuardLeaked(onGuardLeaked func(version uint64)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.onGuardLeaked = onGuardLeaked
+}
@@ -0,0 +1,78 @@
+package go_drain
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGuardedClaim(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaked := make(chan uint64, 1)
+	d.SetOnGuardLeaked(func(version uint64) {
+		leaked <- version
+	})
+
+	g, err := GuardedClaim(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Config().(*myConfig).name != `v1` || g.Version() != 1 {
+		t.Error(`expected the guard to hold the config, but got: `, g.Config(), g.Version())
+	}
+	g.Release()
+	g.Release()
+	if g.Config() != nil || d.TotalOutstandingClaims() != 0 {
+		t.Error(`expected the guard to be released once, but got: `, g.Config(), d.TotalOutstandingClaims())
+	}
+	runtime.GC()
+	select {
+	case version := <-leaked:
+		t.Error(`expected a released guard not to be reported, but got: `, version)
+	case <-time.After(10 * time.Millisecond):
+	}
+	d.StopAndJoin()
+}
+
+func TestGuardedClaim_Leaked(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaked := make(chan uint64, 1)
+	d.SetOnGuardLeaked(func(version uint64) {
+		leaked <- version
+	})
+
+	if _, err = GuardedClaim(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.TotalOutstandingClaims() != 1 {
+		t.Fatal(`expected the dropped guard to hold a claim, but got: `, d.TotalOutstandingClaims())
+	}
+	deadline := time.After(time.Second)
+	for reclaimed := false; !reclaimed; {
+		runtime.GC()
+		select {
+		case version := <-leaked:
+			if version != 1 {
+				t.Error(`expected the leaked version, but got: `, version)
+			}
+			reclaimed = true
+		case <-deadline:
+			t.Fatal(`expected the dropped guard to be reclaimed`)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if d.TotalOutstandingClaims() != 0 {
+		t.Error(`expected the claim to be released, but got: `, d.TotalOutstandingClaims())
+	}
+	d.StopAndJoin()
+}
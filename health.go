@@ -0,0 +1,116 @@
+package go_drain
+
+import "time"
+
+// Healthier is implemented by configurations that can report whether they are
+// still healthy, such as a configuration holding a database connection that
+// can be pinged
+type Healthier interface {
+	// Healthy checks the configuration
+	// @return nil if the configuration is healthy, the reason it is not if unhealthy
+	Healthy() error
+}
+
+// NewWithHealthMonitor is New, but the health of the current configuration is
+// monitored by a background go routine. Every interval, if the current
+// configuration implements Healthier, Healthy is called on it. After
+// failureThreshold consecutive failures, ReLoad is called to try to recover,
+// and the count starts over. Reload errors are reported to the callback set by
+// SetOnReloadError. Configurations that do not implement Healthier are always
+// healthy. The background go routine exits when the Drain is stopped and is
+// started again by Reset
+// @param interval is the time between health checks, must be positive
+// @param failureThreshold is how many consecutive failed checks trigger a
+//   reload. Less than 1 is treated as 1
+// @return c the Drain object or nil, if there was an error
+// @return err ErrInvalidInterval if interval is not positive, or any errors
+//   encountered when loading or testing the config
+func NewWithHealthMonitor(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	interval time.Duration,
+	failureThreshold int,
+) (c *Drain, err error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+	c, err = New(loadAndTest, closer)
+	if err != nil {
+		return nil, err
+	}
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.healthInterval = interval
+	c.healthFailureThreshold = failureThreshold
+	c.startHealthMonitor()
+	return c, nil
+}
+
+// LastHealth gets the result of the most recent health check made by the
+// monitor of a Drain created with NewWithHealthMonitor
+// @return the error returned by Healthy, or nil if the configuration was
+//   healthy, does not implement Healthier, or has not been checked yet
+func (d *Drain) LastHealth() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastHealth
+}
+
+// startHealthMonitor checks the health of the current configuration every
+// healthInterval until the Drain is stopped, reloading after
+// healthFailureThreshold consecutive failures. It does nothing if health is not
+// monitored or the Drain is stopped, and is started again by Reset
+//
+// Assumes that the d.mu is locked
+func (d *Drain) startHealthMonitor() {
+	if d.healthInterval <= 0 || d.isStopped {
+		return
+	}
+	stopped := d.stopped
+	failureThreshold := d.healthFailureThreshold
+	d.lastHealth = nil
+	ticks, stopTicks := d.newTicker(d.healthInterval)
+	go func() {
+		defer stopTicks()
+		failures := 0
+		for {
+			select {
+			case <-ticks:
+				if d.checkHealth() == nil {
+					failures = 0
+					continue
+				}
+				if failures++; failures >= failureThreshold {
+					failures = 0
+					// errors are reported by the reload error hook
+					_ = d.ReLoad()
+				}
+			case <-stopped:
+				return
+			}
+		}
+	}()
+}
+
+// checkHealth calls Healthy on the current configuration and records the result for LastHealth
+// @return the error returned by Healthy, ErrCallbackPanicked if it panicked, or
+//   nil if the configuration is healthy, does not implement Healthier, or the
+//   Drain is stopped
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) checkHealth() (err error) {
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if h, ok := currentlyRunningConfig.(Healthier); ok {
+			if protect(CallbackSiteHealthy, func() { err = h.Healthy() }) {
+				err = ErrCallbackPanicked
+			}
+		}
+		d.mu.Lock()
+		d.lastHealth = err
+		d.mu.Unlock()
+	})
+	return
+}
@@ -0,0 +1,103 @@
+package go_drain
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// healthConfig is a configuration that can be made unhealthy
+type healthConfig struct {
+	unhealthy int32
+}
+
+var errUnhealthy = errors.New(`unhealthy`)
+
+func (c *healthConfig) Healthy() error {
+	if atomic.LoadInt32(&c.unhealthy) != 0 {
+		return errUnhealthy
+	}
+	return nil
+}
+
+func TestNewWithHealthMonitor(t *testing.T) {
+	first := &healthConfig{}
+	var loadCalled int32
+	d, err := NewWithHealthMonitor(func(currentConfig interface{}) (config interface{}, err error) {
+		if atomic.AddInt32(&loadCalled, 1) == 1 {
+			return first, nil
+		}
+		return &healthConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, 5*time.Millisecond, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if d.LastHealth() != nil || d.CurrentVersion() != 1 {
+		t.Error(`expected a healthy config to be left alone, but got: `, d.LastHealth(), d.CurrentVersion())
+	}
+
+	atomic.StoreInt32(&first.unhealthy, 1)
+	deadline := time.Now().Add(time.Second)
+	for d.CurrentVersion() == 1 && time.Now().Before(deadline) {
+		if err = d.LastHealth(); err != nil && err != errUnhealthy {
+			t.Error(`expected the health error, but got: `, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if d.CurrentVersion() != 2 {
+		t.Fatal(`expected the unhealthy config to be reloaded, but the current version is `, d.CurrentVersion())
+	}
+	if atomic.LoadInt32(&loadCalled) != 2 {
+		t.Error(`expected one recovery reload, but loaded `, atomic.LoadInt32(&loadCalled), ` times`)
+	}
+
+	// the new config is healthy
+	time.Sleep(20 * time.Millisecond)
+	if d.LastHealth() != nil {
+		t.Error(`expected the new config to be healthy, but got: `, d.LastHealth())
+	}
+	d.StopAndJoin()
+}
+
+func TestNewWithHealthMonitor_Reset(t *testing.T) {
+	var loadCalled int32
+	d, err := NewWithHealthMonitor(func(currentConfig interface{}) (config interface{}, err error) {
+		atomic.AddInt32(&loadCalled, 1)
+		// every config is unhealthy, so each check reloads
+		return &healthConfig{unhealthy: 1}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, time.Millisecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.StopAndJoin()
+	if err = d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := atomic.LoadInt32(&loadCalled)
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&loadCalled) == loaded && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&loadCalled) == loaded {
+		t.Error(`expected the health monitor to reload the reset drain`)
+	}
+	d.StopAndJoin()
+}
+
+func TestNewWithHealthMonitor_InvalidInterval(t *testing.T) {
+	loaded := false
+	d, err := NewWithHealthMonitor(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded = true
+		return &myConfig{}, nil
+	}, nil, -time.Second, 1)
+	if err != ErrInvalidInterval || d != nil {
+		t.Error(`expected ErrInvalidInterval, but got: `, err)
+	}
+	if loaded {
+		t.Error(`expected no config to be loaded`)
+	}
+}
@@ -0,0 +1,219 @@
+package go_drain
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// hooks are the user callbacks notified of changes to the Drain. They are
+// copied out from under the Drain's lock and always called without it held
+type hooks struct {
+	// diff describes the differences between two configurations, see SetDiffFunc
+	diff func(old, new interface{}) string
+
+	// onDiff receives the result of diff after each reload, see SetOnDiff
+	onDiff func(diff string)
+
+	// onSuperseded receives the version that stopped being the latest after each reload, see SetOnSuperseded
+	onSuperseded func(version uint64)
+
+	// onCloseTimeout receives configurations whose closer timed out, see SetOnCloseTimeout
+	onCloseTimeout func(configToClose interface{}, reason CloseReason)
+
+	// onReloadStorm receives the reload rate when it exceeds the threshold, see SetOnReloadStorm
+	onReloadStorm func(rate int)
+
+	// onReloadError receives the errors of failed reloads, see SetOnReloadError
+	onReloadError func(err error)
+
+	// closerTracer receives each call to the closer, see SetCloserTracer
+	closerTracer func(version uint64, reason CloseReason, duration time.Duration)
+
+	// onActivated receives each version that starts serving after a reload, see SetOnActivated
+	onActivated func(config interface{}, version uint64)
+
+	// onReloadConfigs receives the old and new configurations after each reload, see SetOnReloadConfigs
+	onReloadConfigs func(oldConfig, newConfig interface{}, oldVersion, newVersion uint64)
+
+	// onVersionDrainComplete receives each superseded version once it's retired, see SetOnVersionDrainComplete
+	onVersionDrainComplete func(version uint64, totalClaims uint64, drainDuration time.Duration)
+
+	// onStuckVersion receives superseded versions that have not drained in time, see SetStuckVersionHandler
+	onStuckVersion func(version uint64, age time.Duration)
+
+	// onGuardLeaked receives the versions of Guards that were garbage collected without being Released, see SetOnGuardLeaked
+	onGuardLeaked func(version uint64)
+}
+
+// SetDiffFunc sets the function used to describe what changed between the
+// outgoing and incoming configuration on each successful reload, such as
+// "field X changed from A to B". The result is passed to the callback set by
+// SetOnDiff. This gives a single place to emit change-audit logs
+// @param diff is called with the previous and new configuration after the swap,
+//   before the previous configuration is closed. Pass nil to disable
+func (d *Drain) SetDiffFunc(diff func(old, new interface{}) string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.diff = diff
+}
+
+// SetOnDiff sets the callback that receives the description of what changed
+// on each successful reload, as produced by the function set with SetDiffFunc
+// @param onDiff receives the diff. Pass nil to disable
+func (d *Drain) SetOnDiff(onDiff func(diff string)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.onDiff = onDiff
+}
+
+// SetOnSuperseded sets the callback that is notified the moment a version
+// stops being the one new claims get because a reload appended a newer version.
+// This happens before the superseded version is drained and closed, so
+// consumers can begin winding down work tied to it right away
+// @param onSuperseded receives the superseded version. Pass nil to disable
+func (d *Drain) SetOnSuperseded(onSuperseded func(version uint64)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.onSuperseded = onSuperseded
+}
+
+// SetOnReloadError sets the callback that is notified each time a reload
+// fails, such as when loadAndTester returns an error. This is where errors from
+// reloads that have no caller to return to, such as those made by NewPeriodic,
+// are reported. Reloads declined with ErrNoChange are not failures
+// @param onReloadError receives the error. Pass nil to disable
+func (d *Drain) SetOnReloadError(onReloadError func(err error)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.onReloadError = onReloadError
+}
+
+// SetOnActivated sets the callback that is notified after each reload once the
+// new version is published and is the one new claims get. It's the canonical
+// "this configuration is now serving" hook, for resources that need a final
+// nudge once live, such as announcing a new leader or registering with a
+// service mesh
+// @param onActivated receives the new configuration and its version. Pass nil to disable
+func (d *Drain) SetOnActivated(onActivated func(config interface{}, version uint64)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.onActivated = onActivated
+}
+
+// SetOnReloadConfigs sets the callback that is notified after each successful
+// reload with both the outgoing and incoming configuration objects and their
+// versions. This is for audit trails and diff logging that need the real
+// configurations rather than just versions.
+//
+// The old configuration has not been closed when the callback is called, but
+// it may be closed as soon as the callback returns. Do not retain it
+// @param onReloadConfigs receives the configurations and versions. Pass nil to disable
+func (d *Drain) SetOnReloadConfigs(onReloadConfigs func(oldConfig, newConfig interface{}, oldVersion, newVersion uint64)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.onReloadConfigs = onReloadConfigs
+}
+
+// SetCloserTracer sets the callback that is notified after every call to the
+// closer with which version was closed, why, and how long the closer took. This
+// is a lightweight trace focused on cleanup, where bugs such as double-closes
+// and never-closed versions hide
+// @param closerTracer receives each close. The version is 0 for configurations
+//   that were built but never published, such as those that failed to load or
+//   test. Pass nil to disable
+func (d *Drain) SetCloserTracer(closerTracer func(version uint64, reason CloseReason, duration time.Duration)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.closerTracer = closerTracer
+}
+
+// SetOnVersionDrainComplete sets the callback that is notified when a
+// superseded version has no outstanding claims and is retired, just before it's
+// closed. It reports how many claims the version served over its lifetime,
+// including the claim each reload takes to pass it to loadAndTester, and how
+// long it took to drain after a reload superseded it, for per-version drain
+// analytics. The version that is current when the Drain stops is not superseded
+// and is not reported
+// @param onVersionDrainComplete receives the version, the number of claims it
+//   served, and the time from being superseded to being retired. Pass nil to disable
+func (d *Drain) SetOnVersionDrainComplete(onVersionDrainComplete func(version uint64, totalClaims uint64, drainDuration time.Duration)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks.onVersionDrainComplete = onVersionDrainComplete
+}
+
+// reloaded notifies the hooks that newVersion has replaced oldVersion as the
+// latest version. The old configuration has not been closed yet
+func (h hooks) reloaded(oldVersion, newVersion *configVersion) {
+	if h.diff != nil && h.onDiff != nil {
+		var diff string
+		if !protect(CallbackSiteDiff, func() { diff = h.diff(oldVersion.config, newVersion.config) }) {
+			protect(CallbackSiteOnDiff, func() { h.onDiff(diff) })
+		}
+	}
+	if h.onSuperseded != nil {
+		protect(CallbackSiteOnSuperseded, func() { h.onSuperseded(oldVersion.version) })
+	}
+	if h.onReloadConfigs != nil {
+		protect(CallbackSiteOnReloadConfigs, func() {
+			h.onReloadConfigs(oldVersion.config, newVersion.config, oldVersion.version, newVersion.version)
+		})
+	}
+	if h.onActivated != nil {
+		protect(CallbackSiteOnActivated, func() { h.onActivated(newVersion.config, newVersion.version) })
+	}
+}
+
+// reloadStorm notifies the hooks of the reload rate if a reload storm is underway
+// @param rate is the reload rate or 0 if there is no storm
+func (h hooks) reloadStorm(rate int) {
+	if rate > 0 && h.onReloadStorm != nil {
+		protect(CallbackSiteOnReloadStorm, func() { h.onReloadStorm(rate) })
+	}
+}
+
+// reloadFailed notifies the hooks that a reload failed
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) reloadFailed(err error) {
+	d.mu.Lock()
+	onReloadError := d.hooks.onReloadError
+	d.mu.Unlock()
+	if onReloadError != nil {
+		protect(CallbackSiteOnReloadError, func() { onReloadError(err) })
+	}
+}
+
+// closed notifies the hooks that the closer was called
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) closed(version uint64, reason CloseReason, duration time.Duration) {
+	d.mu.Lock()
+	closerTracer := d.hooks.closerTracer
+	d.mu.Unlock()
+	if closerTracer != nil {
+		protect(CallbackSiteCloserTracer, func() { closerTracer(version, reason, duration) })
+	}
+}
+
+// drainCompleted notifies the hooks that cv was retired. OnVersionDrainComplete
+// is only notified if cv was superseded
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) drainCompleted(cv *configVersion) {
+	d.mu.Lock()
+	onVersionDrainComplete := d.hooks.onVersionDrainComplete
+	retireHooks := cv.retireHooks
+	cv.retireHooks = nil
+	d.mu.Unlock()
+	for _, hook := range retireHooks {
+		protect(CallbackSiteOnRetire, hook)
+	}
+	if onVersionDrainComplete != nil && !cv.supersededAt.IsZero() {
+		totalClaims := atomic.LoadUint64(&cv.totalClaims)
+		drainDuration := d.since(cv.supersededAt)
+		protect(CallbackSiteOnVersionDrainComplete, func() {
+			onVersionDrainComplete(cv.version, totalClaims, drainDuration)
+		})
+	}
+}
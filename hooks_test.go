@@ -0,0 +1,283 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDrain_SetDiffFunc(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := make([]string, 0)
+	d.SetDiffFunc(func(old, new interface{}) string {
+		if len(closed) != 0 {
+			t.Error(`expected the old config to still be open while diffing`)
+		}
+		return fmt.Sprintf(`name changed from %s to %s`, old.(*myConfig).name, new.(*myConfig).name)
+	})
+	d.SetOnDiff(func(diff string) {
+		diffs = append(diffs, diff)
+	})
+
+	_ = d.ReLoad()
+	if len(diffs) != 1 || diffs[0] != `name changed from v1 to v2` {
+		t.Error(`expected the diff of v1 and v2, but got: `, diffs)
+	}
+	if fmt.Sprint(closed) != `[v1]` {
+		t.Error(`expected v1 to be closed after diffing, but got: `, closed)
+	}
+
+	d.SetDiffFunc(nil)
+	_ = d.ReLoad()
+	if len(diffs) != 1 {
+		t.Error(`expected no diff without a diff function, but got: `, diffs)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_SetOnSuperseded(t *testing.T) {
+	loadCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	superseded := make([]uint64, 0)
+	d.SetOnSuperseded(func(version uint64) {
+		superseded = append(superseded, version)
+	})
+
+	// a held claim keeps v1 from being retired, it's still superseded
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+	_ = d.ReLoad()
+	d.Release(&held)
+	if fmt.Sprint(superseded) != `[1 2]` {
+		t.Error(`expected v1 and v2 to be superseded once each, but got: `, superseded)
+	}
+	d.StopAndJoin()
+	if fmt.Sprint(superseded) != `[1 2]` {
+		t.Error(`expected stopping not to supersede, but got: `, superseded)
+	}
+}
+
+func TestDrain_SetCloserTracer(t *testing.T) {
+	loadCalled := 0
+	d, err := NewWithClosers(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		if loadCalled == 3 {
+			return &myConfig{name: `partial`}, errors.New(`load failed`)
+		}
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		time.Sleep(10 * time.Millisecond)
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traced := make([]string, 0)
+	d.SetCloserTracer(func(version uint64, reason CloseReason, duration time.Duration) {
+		traced = append(traced, fmt.Sprintf(`%d:%d`, version, reason))
+		if reason == CloseReasonReplaced && duration < 10*time.Millisecond {
+			t.Error(`expected the duration of the slow closer, but got: `, duration)
+		}
+	})
+
+	_ = d.ReLoad()
+	_ = d.ReLoad()
+	d.StopAndJoin()
+	expected := fmt.Sprintf(`[1:%d 0:%d 2:%d]`, CloseReasonReplaced, CloseReasonReplaced, CloseReasonShutdown)
+	if fmt.Sprint(traced) != expected {
+		t.Error(`expected one trace per close, `, expected, `, but got: `, traced)
+	}
+}
+
+func TestDrain_SetOnActivated(t *testing.T) {
+	loadCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		if loadCalled == 3 {
+			return nil, errors.New(`load failed`)
+		}
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activated := make([]string, 0)
+	d.SetOnActivated(func(config interface{}, version uint64) {
+		if d.CurrentVersion() != version {
+			t.Error(`expected the version to be serving, but the current version is `, d.CurrentVersion())
+		}
+		activated = append(activated, fmt.Sprintf(`%d:%s`, version, config.(*myConfig).name))
+	})
+	_ = d.ReLoad()
+	_ = d.ReLoad()
+	_ = d.ReLoad()
+	if fmt.Sprint(activated) != `[2:v2 3:v4]` {
+		t.Error(`expected one activation per successful reload, but got: `, activated)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_SetOnReloadConfigs(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloads := make([]string, 0)
+	d.SetOnReloadConfigs(func(oldConfig, newConfig interface{}, oldVersion, newVersion uint64) {
+		if len(closed) != 0 {
+			t.Error(`expected the old config to be open during the callback, but closed: `, closed)
+		}
+		reloads = append(reloads, fmt.Sprintf(`%s@%d->%s@%d`, oldConfig.(*myConfig).name, oldVersion, newConfig.(*myConfig).name, newVersion))
+	})
+	_ = d.ReLoad()
+	if fmt.Sprint(reloads) != `[v1@1->v2@2]` {
+		t.Error(`expected the old and new configs, but got: `, reloads)
+	}
+	if fmt.Sprint(closed) != `[v1]` {
+		t.Error(`expected the old config to be closed after the callback, but got: `, closed)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_SetOnVersionDrainComplete(t *testing.T) {
+	loadCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drained := make([]string, 0)
+	var drainDuration time.Duration
+	d.SetOnVersionDrainComplete(func(version uint64, totalClaims uint64, duration time.Duration) {
+		drained = append(drained, fmt.Sprintf(`%d:%d`, version, totalClaims))
+		drainDuration = duration
+	})
+
+	// one claim is released before the reload, the rest drain after it
+	claims := make([]ConfigClaim, 3)
+	for i := range claims {
+		claims[i], _ = d.Claim()
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {})
+	_ = d.ReLoad()
+	for i := range claims {
+		if len(drained) != 0 {
+			t.Error(`expected no report while claims are outstanding, but got: `, drained)
+		}
+		time.Sleep(10 * time.Millisecond)
+		d.Release(&claims[i])
+	}
+	if fmt.Sprint(drained) != `[1:5]` {
+		t.Error(`expected version 1 to report its 4 claims and the reload's, but got: `, drained)
+	}
+	if drainDuration < 30*time.Millisecond {
+		t.Error(`expected the time since the reload, but got: `, drainDuration)
+	}
+
+	// a version claimed only by the reload drains as soon as it's superseded
+	_ = d.ReLoad()
+	if fmt.Sprint(drained) != `[1:5 2:1]` {
+		t.Error(`expected version 2 to report only the reload's claim, but got: `, drained)
+	}
+
+	// the current version is not superseded by stopping
+	d.StopAndJoin()
+	if fmt.Sprint(drained) != `[1:5 2:1]` {
+		t.Error(`expected no report on shutdown, but got: `, drained)
+	}
+}
+
+func TestDrain_HooksMayQueryTheDrain(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	observed := make([]string, 0)
+	d.SetOnActivated(func(config interface{}, version uint64) {
+		stats := d.Stats()
+		observed = append(observed, fmt.Sprintf(`%d:%d:%d`, d.CurrentVersion(), stats.CurrentVersion, stats.OutstandingClaims))
+	})
+
+	reloaded := make(chan error, 1)
+	go func() {
+		reloaded <- d.ReLoad()
+	}()
+	select {
+	case err = <-reloaded:
+		if err != nil {
+			t.Error(`expected the reload to succeed, but got: `, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal(`expected the hook not to deadlock`)
+	}
+	if fmt.Sprint(observed) != `[2:2:0]` {
+		t.Error(`expected the hook to see the new version, but got: `, observed)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_HooksMayReLoad(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nestedErrs := make([]error, 0)
+	d.SetOnActivated(func(config interface{}, version uint64) {
+		// the nested reload calls this hook again, only reload once
+		if version == 2 {
+			nestedErrs = append(nestedErrs, d.ReLoad())
+		}
+	})
+
+	reloaded := make(chan error, 1)
+	go func() {
+		reloaded <- d.ReLoad()
+	}()
+	select {
+	case err = <-reloaded:
+		if err != nil {
+			t.Error(`expected the reload to succeed, but got: `, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal(`expected the hook not to deadlock`)
+	}
+	if fmt.Sprint(nestedErrs) != `[<nil>]` || d.CurrentVersion() != 3 {
+		t.Error(`expected the hook to reload once, but got: `, nestedErrs, d.CurrentVersion())
+	}
+	d.StopAndJoin()
+}
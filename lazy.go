@@ -0,0 +1,58 @@
+package go_drain
+
+import "sync"
+
+// Lazy holds a value that is expensive to build, such as a template cache, inside
+// a configuration. It is built on first use by Get and can be carried forward into
+// the next configuration with CopyFrom when the inputs it was built from have not
+// changed, typically from a ComponentCopyFunc. The zero value is ready to use.
+// Lazy must not be copied after first use, embed it by value and pass pointers
+type Lazy[T any] struct {
+	// mu guards value and built
+	mu sync.Mutex
+
+	// value is the built value
+	value T
+
+	// built is true once value has been built successfully
+	built bool
+}
+
+// Get returns the value, building it first if it has not been built yet. If
+// build returns an error, nothing is stored and the next call to Get tries again.
+// Concurrent calls to Get wait for a single build
+// @param build creates the value
+// @return the built value or the zero value of T if build failed
+// @return the error returned by build, nil if the value was already built
+func (l *Lazy[T]) Get(build func() (T, error)) (T, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.built {
+		return l.value, nil
+	}
+	value, err := build()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	l.value = value
+	l.built = true
+	return l.value, nil
+}
+
+// CopyFrom carries the value built by prev into this Lazy so that it is not
+// rebuilt. If prev has not been built, this Lazy is left un-built
+// @param prev is the Lazy in the currently running configuration
+func (l *Lazy[T]) CopyFrom(prev *Lazy[T]) {
+	if prev == l {
+		return
+	}
+	prev.mu.Lock()
+	value, built := prev.value, prev.built
+	prev.mu.Unlock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.value = value
+	l.built = built
+}
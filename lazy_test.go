@@ -0,0 +1,84 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestLazy_Get(t *testing.T) {
+	var l Lazy[string]
+	builds := 0
+	buildErr := errors.New(`build failed`)
+
+	if _, err := l.Get(func() (string, error) {
+		builds++
+		return ``, buildErr
+	}); err != buildErr {
+		t.Error(`expected the build error, but got: `, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		v, err := l.Get(func() (string, error) {
+			builds++
+			return fmt.Sprintf(`built-%d`, builds), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != `built-2` {
+			t.Error(`expected the value to be built once, but got: `, v)
+		}
+	}
+	if builds != 2 {
+		t.Error(`expected a failed build and a single successful build, but got `, builds)
+	}
+}
+
+type lazyConfig struct {
+	templateDir string
+	templates   Lazy[string]
+}
+
+func TestLazy_CopyFrom(t *testing.T) {
+	templateDir := `a`
+	builds := 0
+	d, err := NewDrainWithComponents(func() (interface{}, error) {
+		return &lazyConfig{templateDir: templateDir}, nil
+	}, []ComponentReloader{
+		NewAutoComponent(func(buildingConfig interface{}) error {
+			return nil
+		}, nil, SameBy(func(c interface{}) string {
+			return c.(*lazyConfig).templateDir
+		}), func(dst interface{}, src interface{}) {
+			dst.(*lazyConfig).templates.CopyFrom(&src.(*lazyConfig).templates)
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getTemplates := func() (v string) {
+		_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+			cfg := currentlyRunningConfig.(*lazyConfig)
+			v, _ = cfg.templates.Get(func() (string, error) {
+				builds++
+				return fmt.Sprintf(`%s-%d`, cfg.templateDir, builds), nil
+			})
+		})
+		return
+	}
+
+	if v := getTemplates(); v != `a-1` {
+		t.Error(`expected a-1, but got: `, v)
+	}
+	_ = d.ReLoad()
+	if v := getTemplates(); v != `a-1` {
+		t.Error(`expected the built value to be copied forward, but got: `, v)
+	}
+	templateDir = `b`
+	_ = d.ReLoad()
+	if v := getTemplates(); v != `b-2` {
+		t.Error(`expected the value to be rebuilt when its input changed, but got: `, v)
+	}
+	d.StopAndJoin()
+}
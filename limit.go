@@ -0,0 +1,79 @@
+package go_drain
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrInvalidClaimLimit is returned by NewWithClaimLimit when limit is less than 1
+var ErrInvalidClaimLimit = errors.New(`claim limit must be at least 1`)
+
+// NewWithClaimLimit is New, but with at most limit claims outstanding at a time.
+// Once the limit is reached, Claim and ClaimInto block until a claim is Released
+// or the Drain is stopped. Use ClaimContextLimited to bound how long to wait.
+// Claims made internally by the Drain, such as to pass the current configuration
+// to loadAndTest, do not count against the limit
+// @param limit is the maximum number of outstanding claims, must be at least 1
+// @return c the Drain object or nil, if there was an error
+// @return err ErrInvalidClaimLimit if limit is less than 1, or any errors
+//   encountered when loading or testing the config
+func NewWithClaimLimit(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	limit int,
+) (c *Drain, err error) {
+	if limit < 1 {
+		return nil, ErrInvalidClaimLimit
+	}
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.claimSlots = make(chan struct{}, limit)
+	})
+}
+
+// ClaimContextLimited is Claim, but when the claim limit has been reached, it
+// waits for a claim to be Released only until ctx is done. If the Drain has no
+// claim limit, it is the same as Claim
+// @param ctx bounds how long to wait for a claim slot
+// @return cc the claim or an invalidated claim if there was an error
+// @return err ctx.Err() if ctx was done before a slot was available,
+//   ErrDrainAlreadyStopped if the Drain is or becomes stopped while waiting, nil otherwise
+func (d *Drain) ClaimContextLimited(ctx context.Context) (cc ConfigClaim, err error) {
+	if d.claimSlots == nil {
+		err = d.ClaimInto(&cc)
+		return
+	}
+	err = d.claimLimited(ctx, &cc)
+	return
+}
+
+// claimLimited waits for a claim slot, then claims
+func (d *Drain) claimLimited(ctx context.Context, cc *ConfigClaim) error {
+	select {
+	case d.claimSlots <- struct{}{}:
+	default:
+		// no slot free, park until one is
+		atomic.AddInt64(&d.waitingClaims, 1)
+		select {
+		case d.claimSlots <- struct{}{}:
+			atomic.AddInt64(&d.waitingClaims, -1)
+		case <-d.stopped:
+			atomic.AddInt64(&d.waitingClaims, -1)
+			// fails with ErrDrainAlreadyStopped, recording the rejection
+			return d.claimInto(cc)
+		case <-ctx.Done():
+			atomic.AddInt64(&d.waitingClaims, -1)
+			*cc = ConfigClaim{}
+			return ctx.Err()
+		}
+	}
+	if err := d.claimInto(cc); err != nil || cc.version == 0 {
+		<-d.claimSlots
+		if err == nil {
+			err = ErrNoConfigYet
+		}
+		return err
+	}
+	cc.holdsSlot = true
+	return nil
+}
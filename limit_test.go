@@ -0,0 +1,106 @@
+package go_drain
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newLimitedDrain(t *testing.T, limit int) *Drain {
+	d, err := NewWithClaimLimit(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestNewWithClaimLimit_InvalidLimit(t *testing.T) {
+	load := func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}
+	for _, limit := range []int{0, -1} {
+		if _, err := NewWithClaimLimit(load, nil, limit); err != ErrInvalidClaimLimit {
+			t.Error(`expected ErrInvalidClaimLimit for `, limit, `, but got: `, err)
+		}
+		if _, err := NewBuilder(load).ClaimLimit(limit).Build(); err != ErrInvalidClaimLimit {
+			t.Error(`expected the builder to return ErrInvalidClaimLimit for `, limit, `, but got: `, err)
+		}
+	}
+}
+
+func TestDrain_ClaimContextLimited_Available(t *testing.T) {
+	d := newLimitedDrain(t, 1)
+	cc, err := d.ClaimContextLimited(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// internal claims are not limited, so reloading while at the limit works
+	if err = d.ReLoad(); err != nil {
+		t.Error(`expected reload at the claim limit to succeed, but got: `, err)
+	}
+	d.Release(&cc)
+	d.StopAndJoin()
+}
+
+func TestDrain_ClaimContextLimited_FreedBeforeDeadline(t *testing.T) {
+	d := newLimitedDrain(t, 1)
+	held, _ := d.Claim()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		d.Release(&held)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cc, err := d.ClaimContextLimited(ctx)
+	if err != nil {
+		t.Fatal(`expected the slot to free up, but got: `, err)
+	}
+	d.Release(&cc)
+	d.StopAndJoin()
+}
+
+func TestDrain_ClaimContextLimited_DeadlineExceeded(t *testing.T) {
+	d := newLimitedDrain(t, 1)
+	held, _ := d.Claim()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	cc, err := d.ClaimContextLimited(ctx)
+	if err != context.DeadlineExceeded {
+		t.Error(`expected deadline exceeded, but got: `, err)
+	}
+	if cc.Version() != 0 {
+		t.Error(`expected an invalid claim`)
+	}
+	d.Release(&held)
+
+	// the slot is usable again
+	if cc, err = d.ClaimContextLimited(context.Background()); err != nil {
+		t.Error(`expected the slot to be free, but got: `, err)
+	}
+	d.Release(&cc)
+	d.StopAndJoin()
+}
+
+func TestDrain_ClaimContextLimited_StopDuringWait(t *testing.T) {
+	d := newLimitedDrain(t, 1)
+	held, _ := d.Claim()
+	result := make(chan error)
+	go func() {
+		_, err := d.ClaimContextLimited(context.Background())
+		result <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	d.Stop()
+	select {
+	case err := <-result:
+		if err != ErrDrainAlreadyStopped {
+			t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal(`expected Stop to wake up the waiting claim`)
+	}
+	d.Release(&held)
+	d.StopAndJoin()
+}
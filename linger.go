@@ -0,0 +1,82 @@
+package go_drain
+
+import (
+	"container/list"
+	"time"
+)
+
+// NewWithLinger is New, but a superseded version is kept open for linger after
+// its last claim is Released rather than closed immediately. This keeps
+// resources that benefit from re-use, such as connection pools, warm in case of
+// a rollback or a quick re-claim. Claiming the version with ClaimVersion, or
+// weighting it with SetActiveWeights or PromoteSingle, while it lingers cancels
+// the close. Lingering versions are closed at once by Compact and Stop
+// @param linger is how long a drained version stays open without claims
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithLinger(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	linger time.Duration,
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.linger = linger
+	})
+}
+
+// startLinger schedules the version in e to be closed after linger, if the
+// Drain lingers and is running
+// @return true if the version is lingering and must not be closed yet, false to close it now
+//
+// Assumes that the d.mu is locked
+func (d *Drain) startLinger(e *list.Element) bool {
+	if d.linger <= 0 || d.isStopped {
+		return false
+	}
+	ccv := e.Value.(*configVersion)
+	ccv.stopLinger()
+	l := &lingering{}
+	l.timer = d.clock().AfterFunc(d.linger, func() {
+		d.lingerExpired(e, l)
+	})
+	ccv.lingering = l
+	return true
+}
+
+// lingering is a scheduled close of a lingering version
+type lingering struct {
+	// timer fires the close
+	timer Timer
+}
+
+// stopLinger cancels the scheduled close of the version, if any
+//
+// Assumes that the d.mu is locked
+func (cv *configVersion) stopLinger() {
+	if cv.lingering != nil {
+		cv.lingering.timer.Stop()
+		cv.lingering = nil
+	}
+}
+
+// lingerExpired closes the version in e if it's still lingering on l and
+// nothing has claimed or weighted it since
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) lingerExpired(e *list.Element, l *lingering) {
+	d.mu.Lock()
+	ccv := e.Value.(*configVersion)
+	if ccv.lingering != l || d.findElementWithVersion(ccv.version) != e || !d.shouldCleanup(*ccv) {
+		// cancelled, rescheduled, or already retired
+		d.mu.Unlock()
+		return
+	}
+	ccv.lingering = nil
+	d.versionTracking.Remove(e)
+	latestVersion := d.latestVersion()
+	d.mu.Unlock()
+
+	// unlock while calling closer, could be long
+	d.drainCompleted(ccv)
+	d.closeConfig(ccv.version, ccv.config, latestVersion, CloseReasonReplaced)
+}
@@ -0,0 +1,80 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newLingerTestDrain(t *testing.T, linger time.Duration) (d *Drain, closed func() []string) {
+	mu := sync.Mutex{}
+	loaded := 0
+	closedNames := make([]string, 0)
+	d, err := NewWithLinger(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		closedNames = append(closedNames, configToClose.(*myConfig).name)
+	}, linger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, closedNames...)
+	}
+}
+
+func TestNewWithLinger_Closed(t *testing.T) {
+	d, closed := newLingerTestDrain(t, 50*time.Millisecond)
+
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+	d.Release(&held)
+	if len(closed()) != 0 {
+		t.Error(`expected v1 to linger, but closed: `, closed())
+	}
+	time.Sleep(150 * time.Millisecond)
+	if fmt.Sprint(closed()) != `[v1]` {
+		t.Error(`expected v1 to be closed after lingering, but closed: `, closed())
+	}
+	if _, err := d.ClaimVersion(1); err != ErrVersionNotAvailable {
+		t.Error(`expected v1 to be retired, but got: `, err)
+	}
+	d.StopAndJoin()
+}
+
+func TestNewWithLinger_Reclaimed(t *testing.T) {
+	d, closed := newLingerTestDrain(t, 50*time.Millisecond)
+
+	// superseded without any claims, lingers right away
+	_ = d.ReLoad()
+	reclaimed, err := d.ClaimVersion(1)
+	if err != nil {
+		t.Fatal(`expected the lingering version to be claimable, but got: `, err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if len(closed()) != 0 {
+		t.Error(`expected the re-claim to cancel the close, but closed: `, closed())
+	}
+
+	// lingers again once released
+	d.Release(&reclaimed)
+	if len(closed()) != 0 {
+		t.Error(`expected v1 to linger again, but closed: `, closed())
+	}
+
+	// stopping does not wait for lingering versions
+	d.StopAndJoin()
+	if fmt.Sprint(closed()) != `[v1 v2]` {
+		t.Error(`expected every version to be closed on stop, but closed: `, closed())
+	}
+	time.Sleep(100 * time.Millisecond)
+	if fmt.Sprint(closed()) != `[v1 v2]` {
+		t.Error(`expected lingering versions to be closed once, but closed: `, closed())
+	}
+}
@@ -0,0 +1,41 @@
+package go_drain
+
+import (
+	"context"
+	"net/http"
+)
+
+// ClaimMiddleware wraps http handlers so each request runs with a claim on the
+// current configuration. The configuration is claimed when the request starts,
+// stored in the request's context under key, and released when the request
+// completes, even if the next handler panics. Retrieve it in handlers with
+// ConfigFromContext. If no configuration can be claimed, such as when the
+// Drainer is stopped, the request is answered with 503 Service Unavailable and
+// next is not called
+// @param d is the Drainer to claim from
+// @param key is the context key the configuration is stored under. Use an
+//   unexported type to avoid collisions, as with context.WithValue
+// @return the middleware
+func ClaimMiddleware(d Drainer, key interface{}) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cc, err := d.Claim()
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			defer d.Release(&cc)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), key, cc.Config())))
+		})
+	}
+}
+
+// ConfigFromContext gets the configuration that ClaimMiddleware stored in ctx.
+// The configuration is only valid until the request completes
+// @param key is the key given to ClaimMiddleware
+// @return config the configuration
+// @return ok is false if ctx does not hold a configuration under key
+func ConfigFromContext(ctx context.Context, key interface{}) (config interface{}, ok bool) {
+	config = ctx.Value(key)
+	return config, config != nil
+}
@@ -0,0 +1,67 @@
+package go_drain
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type configKey struct{}
+
+func TestClaimMiddleware(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(`/name`, func(w http.ResponseWriter, r *http.Request) {
+		config, ok := ConfigFromContext(r.Context(), configKey{})
+		if !ok {
+			t.Error(`expected the config in the request context`)
+			return
+		}
+		_, _ = io.WriteString(w, config.(*myConfig).name)
+	})
+	mux.HandleFunc(`/panic`, func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	server := httptest.NewServer(ClaimMiddleware(d, configKey{})(mux))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + `/name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if string(body) != `v1` {
+		t.Error(`expected the handler to get the config, but got: `, string(body))
+	}
+
+	if res, err = http.Get(server.URL + `/panic`); err == nil {
+		_ = res.Body.Close()
+	}
+	if active := d.TotalOutstandingClaims(); active != 0 {
+		t.Error(`expected claims to be balanced, but got: `, active)
+	}
+
+	d.StopAndJoin()
+	res, err = http.Get(server.URL + `/name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Error(`expected 503 once stopped, but got: `, res.StatusCode)
+	}
+}
+
+func TestConfigFromContext_Missing(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, `/`, nil)
+	if _, ok := ConfigFromContext(req.Context(), configKey{}); ok {
+		t.Error(`expected no config without the middleware`)
+	}
+}
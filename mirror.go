@@ -0,0 +1,46 @@
+package go_drain
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// AtomicConfig gets an atomic.Value that always holds the latest configuration.
+// It's updated on every reload, so consumers that only read the current
+// configuration and accept eventual consistency can Load it without any locking
+// or claim bookkeeping.
+//
+// Reads of the mirror do not claim the version, so the configuration may be
+// closed at any time after it's superseded. Only use it for immutable
+// snapshots, such as plain settings. As with any atomic.Value, the mirror can
+// only hold one concrete type, so configurations of any other type are not
+// mirrored and the mirror keeps the last configuration it could hold. The
+// mirror keeps the last configuration after the Drain is stopped
+// @return the mirror, which is the same on every call. It's empty if there is
+//   no configuration yet
+func (d *Drain) AtomicConfig() *atomic.Value {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.mirror == nil {
+		d.mirror = &atomic.Value{}
+		if config := d.latestVersion(); config != nil {
+			d.mirror.Store(config)
+		}
+	}
+	return d.mirror
+}
+
+// updateMirror stores the latest configuration in the mirror, if it was
+// requested and the configuration has the type the mirror holds. Storing
+// another type would panic while the d.mu is locked
+//
+// Assumes that the d.mu is locked
+func (d *Drain) updateMirror(config interface{}) {
+	if d.mirror == nil || config == nil {
+		return
+	}
+	if held := d.mirror.Load(); held != nil && reflect.TypeOf(held) != reflect.TypeOf(config) {
+		return
+	}
+	d.mirror.Store(config)
+}
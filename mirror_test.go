@@ -0,0 +1,62 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDrain_AtomicConfig(t *testing.T) {
+	loaded := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirror := d.AtomicConfig()
+	if mirror != d.AtomicConfig() {
+		t.Error(`expected the same mirror on every call`)
+	}
+	if name := mirror.Load().(*myConfig).name; name != `v1` {
+		t.Error(`expected the mirror to hold the current config, but got: `, name)
+	}
+	_ = d.ReLoad()
+	_ = d.ReLoad()
+	if name := mirror.Load().(*myConfig).name; name != `v3` {
+		t.Error(`expected the mirror to reflect reloads, but got: `, name)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_AtomicConfig_TypeChange(t *testing.T) {
+	var next interface{} = &myConfig{name: `v1`}
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return next, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirror := d.AtomicConfig()
+
+	// a configuration of another type can't be mirrored, but still reloads
+	next = `v2`
+	if err = d.ReLoad(); err != nil {
+		t.Fatal(err)
+	}
+	if name := mirror.Load().(*myConfig).name; name != `v1` {
+		t.Error(`expected the mirror to keep the last config it could hold, but got: `, name)
+	}
+	if d.CurrentConfig() != `v2` {
+		t.Error(`expected the reload to succeed, but got: `, d.CurrentConfig())
+	}
+	next = &myConfig{name: `v3`}
+	if err = d.ReLoad(); err != nil {
+		t.Fatal(err)
+	}
+	if name := mirror.Load().(*myConfig).name; name != `v3` {
+		t.Error(`expected the mirror to resume, but got: `, name)
+	}
+	d.StopAndJoin()
+}
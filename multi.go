@@ -0,0 +1,98 @@
+package go_drain
+
+import "errors"
+
+// ErrUnknownDrain is returned when a named Drainer does not exist
+var ErrUnknownDrain = errors.New(`unknown drain`)
+
+// MultiDrainer manages several named configuration families, such as auth,
+// billing, and search, behind one object
+type MultiDrainer interface {
+	// ClaimNamed is Claim on the Drainer with the given name
+	// @return ConfigClaim representing the claim with the configuration
+	// @return error ErrUnknownDrain if there is no Drainer with that name or
+	//   any error returned by the Drainer's Claim
+	ClaimNamed(name string) (ConfigClaim, error)
+
+	// Release releases a claim made by ClaimNamed on the Drainer that issued it
+	Release(*ConfigClaim)
+
+	// ReLoadNamed is ReLoad on the Drainer with the given name
+	// @return ErrUnknownDrain if there is no Drainer with that name or any
+	//   error returned by the Drainer's ReLoad
+	ReLoadNamed(name string) error
+
+	// ReLoadAll calls ReLoad on every Drainer in parallel
+	// @return errs the errors keyed by the name of the Drainer that failed to reload
+	// @return err the errors as a *MultiReloadError, nil if all succeeded
+	ReLoadAll() (errs map[string]error, err error)
+
+	// StopAndJoin calls StopAndJoin on every Drainer in parallel and blocks
+	// until all of them have stopped
+	StopAndJoin()
+}
+
+// multiDrainer implements MultiDrainer on top of a Registry
+type multiDrainer struct {
+	drains *Registry
+}
+
+// NewMultiDrainer creates a MultiDrainer from the Drainers by name
+// @param drains are the Drainers to manage. The map is copied
+// @return the MultiDrainer
+func NewMultiDrainer(drains map[string]Drainer) MultiDrainer {
+	m := &multiDrainer{
+		drains: NewRegistry(),
+	}
+	for name, d := range drains {
+		m.drains.Register(name, d)
+	}
+	return m
+}
+
+// ClaimNamed claims from the named Drainer and records the name on the claim for Release
+func (m *multiDrainer) ClaimNamed(name string) (ConfigClaim, error) {
+	d, ok := m.drains.Get(name)
+	if !ok {
+		return ConfigClaim{}, ErrUnknownDrain
+	}
+	cc, err := d.Claim()
+	if err != nil {
+		return cc, err
+	}
+	cc.drainName = name
+	return cc, nil
+}
+
+// Release routes the claim to the Drainer that issued it. Claims not issued by
+// ClaimNamed are ignored
+func (m *multiDrainer) Release(cc *ConfigClaim) {
+	if cc == nil {
+		return
+	}
+	d, ok := m.drains.Get(cc.drainName)
+	if !ok {
+		return
+	}
+	cc.drainName = ``
+	d.Release(cc)
+}
+
+// ReLoadNamed reloads the named Drainer
+func (m *multiDrainer) ReLoadNamed(name string) error {
+	d, ok := m.drains.Get(name)
+	if !ok {
+		return ErrUnknownDrain
+	}
+	return d.ReLoad()
+}
+
+// ReLoadAll reloads every Drainer
+func (m *multiDrainer) ReLoadAll() (errs map[string]error, err error) {
+	return m.drains.ReLoadAll()
+}
+
+// StopAndJoin stops every Drainer
+func (m *multiDrainer) StopAndJoin() {
+	m.drains.StopAndJoinAll()
+}
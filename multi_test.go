@@ -0,0 +1,75 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestNewMultiDrainer(t *testing.T) {
+	var mu sync.Mutex
+	loads := make(map[string]int)
+	closes := make(map[string]int)
+	newNamed := func(name string) *Drain {
+		d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			loads[name]++
+			return &myConfig{name: fmt.Sprintf(`%s-v%d`, name, loads[name])}, nil
+		}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			closes[name]++
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	auth := newNamed(`auth`)
+	billing := newNamed(`billing`)
+	m := NewMultiDrainer(map[string]Drainer{
+		`auth`:    auth,
+		`billing`: billing,
+	})
+
+	if _, err := m.ClaimNamed(`search`); err != ErrUnknownDrain {
+		t.Error(`expected ErrUnknownDrain, but got: `, err)
+	}
+	if err := m.ReLoadNamed(`search`); err != ErrUnknownDrain {
+		t.Error(`expected ErrUnknownDrain, but got: `, err)
+	}
+
+	cc, err := m.ClaimNamed(`auth`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.Config().(*myConfig).name != `auth-v1` {
+		t.Error(`expected auth-v1, but got: `, cc.Config().(*myConfig).name)
+	}
+
+	if err = m.ReLoadNamed(`billing`); err != nil {
+		t.Error(err)
+	}
+	if loads[`auth`] != 1 || loads[`billing`] != 2 {
+		t.Error(`expected only billing to reload, but got: `, loads)
+	}
+
+	// routes to auth, which should not disturb billing
+	m.Release(&cc)
+	if auth.versionTracking.Back().Value.(*configVersion).count != 0 {
+		t.Error(`expected the auth claim to be released`)
+	}
+
+	if errs, err := m.ReLoadAll(); len(errs) != 0 || err != nil {
+		t.Error(`expected all reloads to succeed, but got: `, errs)
+	}
+	if loads[`auth`] != 2 || loads[`billing`] != 3 {
+		t.Error(`expected both to reload, but got: `, loads)
+	}
+
+	m.StopAndJoin()
+	if closes[`auth`] != 2 || closes[`billing`] != 3 {
+		t.Error(`expected both to be shut down, but got closes: `, closes)
+	}
+}
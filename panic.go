@@ -0,0 +1,89 @@
+package go_drain
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Sites passed to the handler set by SetCallbackPanicHandler, identifying which
+// user callback panicked
+const (
+	CallbackSiteLoadAndTester          = `LoadAndTester`
+	CallbackSiteCloser                 = `Closer`
+	CallbackSiteInherit                = `Inherit`
+	CallbackSiteClaimIf                = `ClaimIf`
+	CallbackSiteWarmup                 = `Warmup`
+	CallbackSiteDiff                   = `Diff`
+	CallbackSiteOnDiff                 = `OnDiff`
+	CallbackSiteOnCancel               = `OnCancel`
+	CallbackSiteTracer                 = `Tracer`
+	CallbackSiteOpenAndTest            = `OpenAndTest`
+	CallbackSiteClose                  = `Close`
+	CallbackSiteShouldCopy             = `ShouldCopy`
+	CallbackSiteCopy                   = `Copy`
+	CallbackSiteOnSuperseded           = `OnSuperseded`
+	CallbackSiteEqual                  = `Equal`
+	CallbackSiteOnCloseTimeout         = `OnCloseTimeout`
+	CallbackSiteOnReloadStorm          = `OnReloadStorm`
+	CallbackSiteOnLongHold             = `OnLongHold`
+	CallbackSiteOnReloadError          = `OnReloadError`
+	CallbackSiteCloserTracer           = `CloserTracer`
+	CallbackSiteOnActivated            = `OnActivated`
+	CallbackSiteValidator              = `Validator`
+	CallbackSiteOnReloadConfigs        = `OnReloadConfigs`
+	CallbackSiteOnExceed               = `OnExceed`
+	CallbackSiteOnVersionDrainComplete = `OnVersionDrainComplete`
+	CallbackSiteReloadGate             = `ReloadGate`
+	CallbackSiteOnStuckVersion         = `OnStuckVersion`
+	CallbackSiteHealthy                = `Healthy`
+	CallbackSiteOnGuardLeaked          = `OnGuardLeaked`
+	CallbackSiteRetentionSizer         = `RetentionSizer`
+	CallbackSiteOnRetire               = `OnRetire`
+	CallbackSiteReady                  = `Ready`
+	CallbackSiteFetchToken             = `FetchToken`
+)
+
+// ErrCallbackPanicked is returned in place of the result of a user callback
+// that panicked when a handler is set with SetCallbackPanicHandler
+var ErrCallbackPanicked = errors.New(`callback panicked`)
+
+// panicHandlerHolder wraps the handler so that it can be stored in an atomic.Value
+type panicHandlerHolder struct {
+	handler func(recovered interface{}, site string)
+}
+
+// callbackPanicHandler holds a panicHandlerHolder with the handler set by SetCallbackPanicHandler
+var callbackPanicHandler atomic.Value
+
+// SetCallbackPanicHandler sets how panics in user callbacks, such as the
+// LoadAndTesterFunc, CloserFunc, and component functions, are handled for all
+// Drains. By default, panics are not recovered and crash the go routine, which
+// may leave a Drain locked or with unbalanced claims. With a handler set, the
+// panic is recovered where the callback is invoked and passed to the handler,
+// leaving the Drain usable. A panicking LoadAndTesterFunc or OpenAndTest fails
+// the load with ErrCallbackPanicked, a panicking ShouldCopy or ClaimIf predicate
+// is treated as false, and the results of other callbacks are ignored.
+// @param handler is called with the recovered value and the CallbackSite* that
+//   panicked. Pass nil to stop recovering panics
+func SetCallbackPanicHandler(handler func(recovered interface{}, site string)) {
+	callbackPanicHandler.Store(panicHandlerHolder{handler: handler})
+}
+
+// protect calls fn. If a panic handler is set, a panic in fn is recovered and
+// reported to the handler with the site
+// @return true if fn panicked and was recovered, false if not
+func protect(site string, fn func()) (panicked bool) {
+	h, _ := callbackPanicHandler.Load().(panicHandlerHolder)
+	if h.handler == nil {
+		fn()
+		return false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			h.handler(r, site)
+		}
+	}()
+	fn()
+	return false
+}
@@ -0,0 +1,152 @@
+package go_drain
+
+import (
+	"errors"
+	"testing"
+)
+
+type panicTracer struct{}
+
+func (panicTracer) StartSpan(name string) func(err error) {
+	panic(`tracer`)
+}
+
+func TestSetCallbackPanicHandler(t *testing.T) {
+	sites := make([]string, 0)
+	SetCallbackPanicHandler(func(recovered interface{}, site string) {
+		sites = append(sites, site)
+	})
+	defer SetCallbackPanicHandler(nil)
+
+	panicLoad, panicClose := false, false
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		if panicLoad {
+			panic(`load`)
+		}
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		if panicClose {
+			panic(`close`)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	panicLoad = true
+	if err = d.ReLoad(); err != ErrCallbackPanicked {
+		t.Error(`expected ErrCallbackPanicked, but got: `, err)
+	}
+	panicLoad = false
+
+	panicClose = true
+	if err = d.ReLoad(); err != nil {
+		t.Error(`expected a panicking closer not to fail the reload, but got: `, err)
+	}
+	panicClose = false
+
+	if err = d.ReLoadInheriting(func(old, new interface{}) {
+		panic(`inherit`)
+	}); err != nil {
+		t.Error(`expected a panicking inherit not to fail the reload, but got: `, err)
+	}
+
+	errUnavailable := errors.New(`unavailable`)
+	if _, err = d.ClaimIf(func(config interface{}) bool {
+		panic(`pred`)
+	}, errUnavailable); err != errUnavailable {
+		t.Error(`expected a panicking predicate to be unavailable, but got: `, err)
+	}
+
+	d.SetTracer(panicTracer{})
+	cc, err := d.Claim()
+	if err != nil {
+		t.Error(`expected a panicking tracer not to fail the claim, but got: `, err)
+	}
+	d.Release(&cc)
+	d.SetTracer(nil)
+
+	expected := []string{
+		CallbackSiteLoadAndTester,
+		CallbackSiteCloser,
+		CallbackSiteInherit,
+		CallbackSiteClaimIf,
+		CallbackSiteTracer,
+	}
+	if len(sites) != len(expected) {
+		t.Fatal(`expected panics at `, expected, ` but got: `, sites)
+	}
+	for i := range expected {
+		if sites[i] != expected[i] {
+			t.Errorf(`expected panic %d at "%s" but got "%s"`, i, expected[i], sites[i])
+		}
+	}
+
+	// still usable and balanced
+	if err = d.ClaimRelease(func(currentlyRunningConfig interface{}) {}); err != nil {
+		t.Error(`expected the drain to be usable, but got: `, err)
+	}
+	d.StopAndJoin()
+}
+
+func TestSetCallbackPanicHandler_Components(t *testing.T) {
+	sites := make([]string, 0)
+	SetCallbackPanicHandler(func(recovered interface{}, site string) {
+		sites = append(sites, site)
+	})
+	defer SetCallbackPanicHandler(nil)
+
+	panicOpen := false
+	d, err := NewDrainWithComponents(func() (interface{}, error) {
+		return &omniConfig{}, nil
+	}, []ComponentReloader{
+		NewAutoComponent(func(buildingConfig interface{}) error {
+			return nil
+		}, func(buildingConfig interface{}) {
+			panic(`close`)
+		}, func(buildingConfig interface{}, currentlyRunningConfig interface{}) bool {
+			panic(`should copy`)
+		}, func(dst interface{}, src interface{}) {}),
+		NewAutoComponent(func(buildingConfig interface{}) error {
+			return nil
+		}, nil, func(buildingConfig interface{}, currentlyRunningConfig interface{}) bool {
+			return true
+		}, func(dst interface{}, src interface{}) {
+			panic(`copy`)
+		}),
+		NewAutoComponent(func(buildingConfig interface{}) error {
+			if panicOpen {
+				panic(`open`)
+			}
+			return nil
+		}, nil, nil, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	panicOpen = true
+	if err = d.ReLoad(); err != ErrCallbackPanicked {
+		t.Error(`expected ErrCallbackPanicked, but got: `, err)
+	}
+
+	expected := []string{
+		CallbackSiteShouldCopy,
+		CallbackSiteCopy,
+		CallbackSiteOpenAndTest,
+		CallbackSiteClose,
+	}
+	if len(sites) != len(expected) {
+		t.Fatal(`expected panics at `, expected, ` but got: `, sites)
+	}
+	for i := range expected {
+		if sites[i] != expected[i] {
+			t.Errorf(`expected panic %d at "%s" but got "%s"`, i, expected[i], sites[i])
+		}
+	}
+
+	if err = d.ClaimRelease(func(currentlyRunningConfig interface{}) {}); err != nil {
+		t.Error(`expected the drain to be usable, but got: `, err)
+	}
+	d.StopAndJoin()
+}
@@ -0,0 +1,91 @@
+package go_drain
+
+// OperationKind is the kind of background work an Operation describes
+type OperationKind int
+
+const (
+	// OperationClose is a close queued for the background worker of a Drain
+	// created with NewAsyncClose
+	OperationClose OperationKind = iota
+
+	// OperationLinger is a drained version that a Drain created with
+	// NewWithLinger is keeping open until its linger expires
+	OperationLinger
+)
+
+// Operation is background work the Drain has yet to complete
+type Operation struct {
+	// Kind is what the work is
+	Kind OperationKind
+
+	// Version is the version the work is for
+	Version uint64
+}
+
+// PendingOperations lists the background work the Drain has yet to complete:
+// closes queued for the background worker and versions that are lingering
+// before they are closed. Closes that the worker has already started are not
+// listed
+// @return the pending work, lingering versions first, then queued closes in the order they will run
+func (d *Drain) PendingOperations() []Operation {
+	ops := make([]Operation, 0)
+	d.mu.Lock()
+	for e := d.versionTracking.Front(); e != nil; e = e.Next() {
+		if ccv := e.Value.(*configVersion); ccv.lingering != nil {
+			ops = append(ops, Operation{Kind: OperationLinger, Version: ccv.version})
+		}
+	}
+	d.mu.Unlock()
+
+	d.closeQueueMu.Lock()
+	for _, q := range d.closeQueue {
+		ops = append(ops, Operation{Kind: OperationClose, Version: q.version})
+	}
+	d.closeQueueMu.Unlock()
+	return ops
+}
+
+// FlushPending completes all background work synchronously before returning.
+// Lingering versions that are still unclaimed are closed at once, and queued
+// closes are performed on the calling go routine rather than waiting for the
+// background worker. This is useful before a hard shutdown, to ensure that no
+// background go routines are left holding resources
+func (d *Drain) FlushPending() {
+	d.mu.Lock()
+	toClose := make([]*configVersion, 0)
+	for e := d.versionTracking.Front(); e != nil; {
+		next := e.Next()
+		if ccv := e.Value.(*configVersion); ccv.lingering != nil && d.shouldCleanup(*ccv) {
+			ccv.stopLinger()
+			d.versionTracking.Remove(e)
+			toClose = append(toClose, ccv)
+		}
+		e = next
+	}
+	latestVersion := d.latestVersion()
+	d.mu.Unlock()
+
+	// unlock while calling closer, could be long
+	for _, ccv := range toClose {
+		d.drainCompleted(ccv)
+		d.closeConfig(ccv.version, ccv.config, latestVersion, CloseReasonReplaced)
+	}
+
+	// take over queued closes from the background worker
+	for {
+		d.closeQueueMu.Lock()
+		if len(d.closeQueue) == 0 {
+			d.closeQueueMu.Unlock()
+			break
+		}
+		q := d.closeQueue[0]
+		d.closeQueue[0] = queuedClose{}
+		d.closeQueue = d.closeQueue[1:]
+		d.closeQueueMu.Unlock()
+
+		q.run()
+		d.pendingCloses.Done()
+	}
+	// wait for the close the worker was running, if any
+	d.pendingCloses.Wait()
+}
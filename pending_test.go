@@ -0,0 +1,88 @@
+package go_drain
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDrain_FlushPending_AsyncClose(t *testing.T) {
+	var mu sync.Mutex
+	loadCalled := 0
+	closed := make([]string, 0)
+	gate := make(chan struct{})
+	d, err := NewAsyncClose(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		if configToClose.(*myConfig).name == `v1` {
+			// hold up the background worker
+			<-gate
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		held, _ := d.Claim()
+		_ = d.ReLoad()
+		d.Release(&held)
+	}
+	// v1 is being closed by the worker, v2 and v3 are queued behind it
+	time.Sleep(20 * time.Millisecond)
+	ops := d.PendingOperations()
+	if fmt.Sprint(ops) != fmt.Sprint([]Operation{{Kind: OperationClose, Version: 2}, {Kind: OperationClose, Version: 3}}) {
+		t.Error(`expected the queued closes of v2 and v3, but got: `, ops)
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		d.FlushPending()
+		close(flushed)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	if fmt.Sprint(closed) != `[v2 v3]` {
+		t.Error(`expected the queued closes to be run by FlushPending, but got: `, closed)
+	}
+	mu.Unlock()
+	select {
+	case <-flushed:
+		t.Error(`expected FlushPending to wait for the running close`)
+	default:
+	}
+	close(gate)
+	<-flushed
+	if len(d.PendingOperations()) != 0 {
+		t.Error(`expected nothing pending, but got: `, d.PendingOperations())
+	}
+	mu.Lock()
+	sort.Strings(closed)
+	if fmt.Sprint(closed) != `[v1 v2 v3]` {
+		t.Error(`expected all closes to complete, but got: `, closed)
+	}
+	mu.Unlock()
+	d.StopAndJoin()
+}
+
+func TestDrain_FlushPending_Linger(t *testing.T) {
+	d, closed := newLingerTestDrain(t, time.Hour)
+	_ = d.ReLoad()
+	if ops := d.PendingOperations(); len(ops) != 1 || ops[0] != (Operation{Kind: OperationLinger, Version: 1}) {
+		t.Error(`expected v1 to be lingering, but got: `, ops)
+	}
+	d.FlushPending()
+	if fmt.Sprint(closed()) != `[v1]` {
+		t.Error(`expected the lingering version to be closed, but closed: `, closed())
+	}
+	if len(d.PendingOperations()) != 0 {
+		t.Error(`expected nothing pending, but got: `, d.PendingOperations())
+	}
+	d.StopAndJoin()
+}
@@ -0,0 +1,98 @@
+package go_drain
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidInterval is returned by NewPeriodic and NewWithHealthMonitor when
+// interval is not positive
+var ErrInvalidInterval = errors.New(`interval must be positive`)
+
+// NewPeriodic is New, but ReLoad is called every interval by a background go
+// routine. This suits configurations backed by a polled source, such as a remote
+// endpoint without push notifications. Reload errors are reported to the
+// callback set by SetOnReloadError. The background go routine exits when the
+// returned stop function is called or the Drain is stopped. Reset starts it
+// again, unless the stop function was called
+// @param interval is the time between reloads, must be positive
+// @return c the Drain object or nil, if there was an error
+// @return stop halts the periodic reloads and waits for an in-flight reload to
+//   return. It's safe to call more than once. nil if there was an error
+// @return err ErrInvalidInterval if interval is not positive, or any errors
+//   encountered when loading or testing the config
+func NewPeriodic(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	interval time.Duration,
+) (c *Drain, stop func(), err error) {
+	if interval <= 0 {
+		return nil, nil, ErrInvalidInterval
+	}
+	c, err = New(loadAndTest, closer)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.mu.Lock()
+	c.periodicInterval = interval
+	c.startPeriodicReloads()
+	c.mu.Unlock()
+	return c, c.stopPeriodicReloads, nil
+}
+
+// startPeriodicReloads starts reloading every periodicInterval until the Drain
+// is stopped. It does nothing if the Drain doesn't reload periodically or is stopped
+//
+// Assumes that the d.mu is locked
+func (d *Drain) startPeriodicReloads() {
+	if d.periodicInterval <= 0 || d.isStopped {
+		return
+	}
+	ticks, stopTicks := d.newTicker(d.periodicInterval)
+	d.haltPeriodic = d.reloadOnTicks(ticks, stopTicks, d.stopped)
+}
+
+// stopPeriodicReloads halts the periodic reloads for good, so that Reset does
+// not start them again, and waits for the background go routine to exit
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) stopPeriodicReloads() {
+	d.mu.Lock()
+	d.periodicInterval = 0
+	halt := d.haltPeriodic
+	d.mu.Unlock()
+	if halt != nil {
+		halt()
+	}
+}
+
+// reloadOnTicks calls ReLoad on each tick until the returned function is called or the Drain is stopped
+// @param ticks triggers the reloads
+// @param stopTicks is called once the reloads have halted
+// @param stopped is closed when the Drain is stopped
+// @return halts the reloads and waits for the background go routine to exit
+func (d *Drain) reloadOnTicks(ticks <-chan time.Time, stopTicks func(), stopped <-chan struct{}) func() {
+	halt := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer stopTicks()
+		for {
+			select {
+			case <-ticks:
+				// errors are reported by the reload error hook
+				_ = d.ReLoad()
+			case <-halt:
+				return
+			case <-stopped:
+				return
+			}
+		}
+	}()
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { close(halt) })
+		<-done
+	}
+}
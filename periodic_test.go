@@ -0,0 +1,115 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrain_reloadOnTicks(t *testing.T) {
+	errLoad := errors.New(`load failed`)
+	loaded := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		if loaded == 3 {
+			return nil, errLoad
+		}
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloadErrs := make(chan error, 1)
+	d.SetOnReloadError(func(err error) {
+		reloadErrs <- err
+	})
+
+	// a fake clock
+	ticks := make(chan time.Time)
+	tickerStopped := false
+	stop := d.reloadOnTicks(ticks, func() { tickerStopped = true }, d.stopped)
+	ticks <- time.Now()
+	ticks <- time.Now()
+	ticks <- time.Now()
+	stop()
+	stop()
+	if !tickerStopped {
+		t.Error(`expected the ticker to be stopped`)
+	}
+	if loaded != 4 {
+		t.Error(`expected a reload on each tick, but loaded `, loaded, ` times`)
+	}
+	if err = <-reloadErrs; err != errLoad {
+		t.Error(`expected the failed reload to be reported, but got: `, err)
+	}
+	d.StopAndJoin()
+}
+
+func TestNewPeriodic(t *testing.T) {
+	d, stop, err := NewPeriodic(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if d.CurrentVersion() < 2 {
+		t.Error(`expected periodic reloads, but the version is `, d.CurrentVersion())
+	}
+
+	// stopping the drain ends the background go routine
+	d.StopAndJoin()
+	stop()
+}
+
+func TestNewPeriodic_Reset(t *testing.T) {
+	var loaded int32
+	d, stop, err := NewPeriodic(func(currentConfig interface{}) (config interface{}, err error) {
+		atomic.AddInt32(&loaded, 1)
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.StopAndJoin()
+	if err = d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for d.CurrentVersion() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if d.CurrentVersion() < 2 {
+		t.Error(`expected the reset drain to keep reloading, but the version is `, d.CurrentVersion())
+	}
+
+	// once stopped for good, Reset does not restart the reloads
+	stop()
+	d.StopAndJoin()
+	if err = d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	before := atomic.LoadInt32(&loaded)
+	time.Sleep(20 * time.Millisecond)
+	if after := atomic.LoadInt32(&loaded); after != before {
+		t.Error(`expected no reloads after stop, but loaded `, after-before, ` times`)
+	}
+	stop()
+	d.StopAndJoin()
+}
+
+func TestNewPeriodic_InvalidInterval(t *testing.T) {
+	loaded := false
+	d, stop, err := NewPeriodic(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded = true
+		return &myConfig{}, nil
+	}, nil, 0)
+	if err != ErrInvalidInterval || d != nil || stop != nil {
+		t.Error(`expected ErrInvalidInterval, but got: `, err)
+	}
+	if loaded {
+		t.Error(`expected no config to be loaded`)
+	}
+}
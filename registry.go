@@ -0,0 +1,119 @@
+package go_drain
+
+import "sync"
+
+// Registry tracks Drainers by name so that large applications have one place
+// to trigger reloads and shutdown, such as from a SIGHUP or SIGTERM handler.
+// Registry is safe to use among many go-routines
+type Registry struct {
+	// mu guards drains
+	mu sync.RWMutex
+
+	// drains is the set of registered Drainers by name
+	drains map[string]Drainer
+}
+
+// DefaultRegistry is a package-level Registry for applications that want a
+// globally accessible place to register their Drainers
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		drains: make(map[string]Drainer),
+	}
+}
+
+// Register adds the Drainer to the Registry under name. If a Drainer is
+// already registered under name, it is replaced
+// @param name uniquely identifies the Drainer
+// @param d is the Drainer to register
+func (r *Registry) Register(name string, d Drainer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.drains[name] = d
+}
+
+// Get looks up a Drainer by name
+// @return the Drainer registered under name
+// @return true if it was found, false if not
+func (r *Registry) Get(name string) (Drainer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.drains[name]
+	return d, ok
+}
+
+// ReLoadAll calls ReLoad on every registered Drainer in parallel and waits for them to complete
+// @return errs the errors returned by ReLoad keyed by the name of the Drainer
+//   that failed. Drainers that reloaded successfully are not in the map. Empty
+//   if all succeeded
+// @return err the errors as a *MultiReloadError, to handle them as one error.
+//   nil if all succeeded
+func (r *Registry) ReLoadAll() (errs map[string]error, err error) {
+	return r.ReLoadAllLimited(0)
+}
+
+// ReLoadAllLimited is ReLoadAll, but with at most maxConcurrency reloads running
+// at a time. This keeps a fleet of reloads from overwhelming a shared backend,
+// such as a config server that every Drainer loads from
+// @param maxConcurrency is the maximum number of parallel reloads, 0 or less for unbounded
+// @return errs the errors returned by ReLoad keyed by the name of the Drainer that failed
+// @return err the errors as a *MultiReloadError, nil if all succeeded
+func (r *Registry) ReLoadAllLimited(maxConcurrency int) (errs map[string]error, err error) {
+	errs = make(map[string]error)
+	var errsMu sync.Mutex
+	r.forEach(maxConcurrency, func(name string, d Drainer) {
+		if err := d.ReLoad(); err != nil {
+			errsMu.Lock()
+			errs[name] = err
+			errsMu.Unlock()
+		}
+	})
+	return errs, JoinReloadErrors(errs)
+}
+
+// StopAndJoinAll calls StopAndJoin on every registered Drainer in parallel and
+// blocks until all of them have stopped
+func (r *Registry) StopAndJoinAll() {
+	r.forEach(0, func(name string, d Drainer) {
+		d.StopAndJoin()
+	})
+}
+
+// forEach calls op on every registered Drainer in its own go-routine and waits
+// for all of them to return. The set of Drainers is copied first so that op may
+// use the Registry
+// @param maxConcurrency is the maximum number of ops running at once, 0 or less for unbounded
+func (r *Registry) forEach(maxConcurrency int, op func(name string, d Drainer)) {
+	r.mu.RLock()
+	drains := make(map[string]Drainer, len(r.drains))
+	for name, d := range r.drains {
+		drains[name] = d
+	}
+	r.mu.RUnlock()
+
+	if maxConcurrency <= 0 || maxConcurrency > len(drains) {
+		maxConcurrency = len(drains)
+	}
+	type namedDrainer struct {
+		name string
+		d    Drainer
+	}
+	work := make(chan namedDrainer)
+	var wg sync.WaitGroup
+	wg.Add(maxConcurrency)
+	for i := 0; i < maxConcurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for w := range work {
+				op(w.name, w.d)
+			}
+		}()
+	}
+	for name, d := range drains {
+		work <- namedDrainer{name: name, d: d}
+	}
+	close(work)
+	wg.Wait()
+}
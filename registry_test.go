@@ -0,0 +1,107 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCountingDrain(t *testing.T, loadErr *error, loads *int, closes *int, mu *sync.Mutex) *Drain {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		*loads++
+		if *loadErr != nil {
+			return nil, *loadErr
+		}
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		*closes++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestRegistry(t *testing.T) {
+	var mu sync.Mutex
+	var okErr, badErr error
+	okLoads, okCloses, badLoads, badCloses := 0, 0, 0, 0
+	ok := newCountingDrain(t, &okErr, &okLoads, &okCloses, &mu)
+	bad := newCountingDrain(t, &badErr, &badLoads, &badCloses, &mu)
+
+	r := NewRegistry()
+	r.Register(`ok`, ok)
+	r.Register(`bad`, bad)
+
+	if d, found := r.Get(`ok`); !found || d != Drainer(ok) {
+		t.Error(`expected to find the ok drain`)
+	}
+	if _, found := r.Get(`missing`); found {
+		t.Error(`expected not to find an unregistered drain`)
+	}
+
+	badErr = errors.New(`bad config`)
+	errs, err := r.ReLoadAll()
+	if len(errs) != 1 || errs[`bad`] != badErr {
+		t.Error(`expected only the bad drain to fail, but got: `, errs)
+	}
+	if !errors.Is(err, badErr) {
+		t.Error(`expected the combined error to hold the bad drain's error, but got: `, err)
+	}
+	if okLoads != 2 || badLoads != 2 {
+		t.Error(`expected every drain to reload, but got: `, okLoads, ` and `, badLoads)
+	}
+
+	r.StopAndJoinAll()
+	if okCloses != 2 || badCloses != 1 {
+		t.Error(`expected every drain to be stopped, but got closes: `, okCloses, ` and `, badCloses)
+	}
+	if _, err := ok.Claim(); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ok drain to be stopped`)
+	}
+	if _, err := bad.Claim(); err != ErrDrainAlreadyStopped {
+		t.Error(`expected bad drain to be stopped`)
+	}
+}
+
+func TestRegistry_ReLoadAllLimited(t *testing.T) {
+	var inFlight, maxInFlight int32
+	r := NewRegistry()
+	for i := 0; i < 10; i++ {
+		first := true
+		d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+			if !first {
+				n := atomic.AddInt32(&inFlight, 1)
+				for {
+					m := atomic.LoadInt32(&maxInFlight)
+					if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&inFlight, -1)
+			}
+			first = false
+			return &myConfig{}, nil
+		}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Register(fmt.Sprintf(`drain-%d`, i), d)
+	}
+
+	if errs, err := r.ReLoadAllLimited(2); len(errs) != 0 || err != nil {
+		t.Error(`expected all reloads to succeed, but got: `, errs)
+	}
+	if maxInFlight != 2 {
+		t.Error(`expected at most 2 reloads at once, but got `, maxInFlight)
+	}
+	r.StopAndJoinAll()
+}
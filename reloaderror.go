@@ -0,0 +1,85 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MultiReloadError collects the errors of a fleet reload, as returned by
+// Registry.ReLoadAll, so that they may be handled as one error. errors.Is and
+// errors.As look through it to the errors of the individual Drainers
+type MultiReloadError struct {
+	// Errors are the errors returned by ReLoad, by the name of the Drainer that failed
+	Errors map[string]error
+}
+
+// JoinReloadErrors wraps errors of Drainers, by name, in a MultiReloadError, as
+// ReLoadAll of a Registry or MultiDrainer does
+// @param errs are the errors by the name of the Drainer that failed. The map is not copied
+// @return a *MultiReloadError or nil if errs is empty, so that it may be compared to nil
+func JoinReloadErrors(errs map[string]error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &MultiReloadError{Errors: errs}
+}
+
+// Failed gets the names of the Drainers that failed to reload. Drainers are
+// registered by name, not by index, so the names identify them
+// @return the names, sorted
+func (e *MultiReloadError) Failed() []string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Unwrap gets the errors of the Drainers that failed, for errors.Is and errors.As
+// @return the errors in the order of Failed
+func (e *MultiReloadError) Unwrap() []error {
+	names := e.Failed()
+	errs := make([]error, len(names))
+	for i, name := range names {
+		errs[i] = e.Errors[name]
+	}
+	return errs
+}
+
+// Is reports whether the error of any Drainer that failed matches target
+// @param target is the error to find, as with errors.Is
+// @return true if errors.Is matches the error of a Drainer that failed
+func (e *MultiReloadError) Is(target error) bool {
+	for _, err := range e.Unwrap() {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of a Drainer that failed, in the order of Failed,
+// that matches target and sets target to it
+// @param target is a pointer to the type of error to find, as with errors.As
+// @return true if target was set
+func (e *MultiReloadError) As(target interface{}) bool {
+	for _, err := range e.Unwrap() {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Error lists the Drainers that failed, by name
+func (e *MultiReloadError) Error() string {
+	names := e.Failed()
+	failed := make([]string, len(names))
+	for i, name := range names {
+		failed[i] = fmt.Sprintf(`%s: %v`, name, e.Errors[name])
+	}
+	return fmt.Sprintf(`%d drains failed to reload: %s`, len(names), strings.Join(failed, `; `))
+}
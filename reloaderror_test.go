@@ -0,0 +1,64 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// reloadStatusError is an error type to extract with errors.As
+type reloadStatusError struct {
+	status int
+}
+
+func (e *reloadStatusError) Error() string {
+	return fmt.Sprintf(`status %d`, e.status)
+}
+
+func TestJoinReloadErrors(t *testing.T) {
+	var mu sync.Mutex
+	errSourceDown := errors.New(`source down`)
+	var okErr, badErr, worseErr error
+	loads, closes := 0, 0
+	r := NewRegistry()
+	r.Register(`ok`, newCountingDrain(t, &okErr, &loads, &closes, &mu))
+	r.Register(`bad`, newCountingDrain(t, &badErr, &loads, &closes, &mu))
+	r.Register(`worse`, newCountingDrain(t, &worseErr, &loads, &closes, &mu))
+	defer r.StopAndJoinAll()
+
+	// the initial loads succeed, only reloads fail
+	badErr, worseErr = &reloadStatusError{status: 503}, fmt.Errorf(`fetching: %w`, errSourceDown)
+	_, err := r.ReLoadAll()
+	var multi *MultiReloadError
+	if !errors.As(err, &multi) {
+		t.Fatal(`expected a MultiReloadError, got `, err)
+	}
+	if fmt.Sprint(multi.Failed()) != `[bad worse]` {
+		t.Error(`expected bad and worse to fail, got `, multi.Failed())
+	}
+	var statusErr *reloadStatusError
+	if !errors.As(err, &statusErr) || statusErr.status != 503 {
+		t.Error(`expected to extract the status error of bad, got `, statusErr)
+	}
+	if !errors.Is(err, errSourceDown) {
+		t.Error(`expected to find the error wrapped by worse`)
+	}
+	if err.Error() != `2 drains failed to reload: bad: status 503; worse: fetching: source down` {
+		t.Error(`unexpected message: `, err.Error())
+	}
+
+	// without relying on errors.Is and errors.As walking Unwrap() []error
+	if !multi.Is(errSourceDown) || multi.Is(errors.New(`source down`)) {
+		t.Error(`expected Is to match only the error wrapped by worse`)
+	}
+	statusErr = nil
+	if !multi.As(&statusErr) || statusErr.status != 503 {
+		t.Error(`expected As to extract the status error of bad, got `, statusErr)
+	}
+
+	badErr, worseErr = nil, nil
+	if _, err = r.ReLoadAll(); err != nil {
+		t.Error(`expected no error once every drain reloads, got `, err)
+	}
+}
@@ -0,0 +1,67 @@
+package go_drain
+
+import "time"
+
+// reloadHistorySize is how many reloads are remembered for ReloadRate. Rates
+// above this are reported as this
+const reloadHistorySize = 256
+
+// ReloadRate counts the successful reloads in the last window. A high rate
+// often indicates a flapping configuration source. At most the last 256
+// reloads are remembered
+// @param window is how far back to count
+// @return the number of reloads that swapped in a new version within window
+func (d *Drain) ReloadRate(window time.Duration) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reloadRate(d.now(), window)
+}
+
+// SetOnReloadStorm sets the callback that is notified when more than threshold
+// reloads happen within window. It's checked after each successful reload, so
+// it fires on every reload for as long as the storm lasts. This lets operators
+// alert on a flapping configuration source
+// @param threshold is the most reloads allowed within window before it is a storm
+// @param window is how far back to count reloads
+// @param onReloadStorm receives the number of reloads within window. Pass nil to disable
+func (d *Drain) SetOnReloadStorm(threshold int, window time.Duration, onReloadStorm func(rate int)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stormThreshold = threshold
+	d.stormWindow = window
+	d.hooks.onReloadStorm = onReloadStorm
+}
+
+// recordReload remembers that a reload just happened
+// @return the reload rate if it exceeds the storm threshold, 0 if not
+//
+// Assumes that the d.mu is locked
+func (d *Drain) recordReload() int {
+	now := d.now()
+	d.reloadTimes[d.reloadNext] = now
+	d.reloadNext = (d.reloadNext + 1) % reloadHistorySize
+	if d.hooks.onReloadStorm == nil {
+		return 0
+	}
+	if rate := d.reloadRate(now, d.stormWindow); rate > d.stormThreshold {
+		return rate
+	}
+	return 0
+}
+
+// reloadRate counts the recorded reloads within window of now
+//
+// Assumes that the d.mu is locked
+func (d *Drain) reloadRate(now time.Time, window time.Duration) int {
+	since := now.Add(-window)
+	rate := 0
+	// walk back from the most recent reload until one is too old or unset
+	for i := 1; i <= reloadHistorySize; i++ {
+		at := d.reloadTimes[(d.reloadNext-i+reloadHistorySize)%reloadHistorySize]
+		if at.IsZero() || at.Before(since) {
+			break
+		}
+		rate++
+	}
+	return rate
+}
@@ -0,0 +1,53 @@
+package go_drain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDrain_ReloadRate(t *testing.T) {
+	loaded := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storms := make([]int, 0)
+	d.SetOnReloadStorm(3, time.Minute, func(rate int) {
+		storms = append(storms, rate)
+	})
+
+	for i := 0; i < 5; i++ {
+		_ = d.ReLoad()
+	}
+	if rate := d.ReloadRate(time.Minute); rate != 5 {
+		t.Error(`expected 5 reloads in the window, but got: `, rate)
+	}
+	if len(storms) != 2 || storms[0] != 4 || storms[1] != 5 {
+		t.Error(`expected the storm handler to fire for the 4th and 5th reloads, but got: `, storms)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if rate := d.ReloadRate(10 * time.Millisecond); rate != 0 {
+		t.Error(`expected no reloads in a short window, but got: `, rate)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_ReloadRate_History(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < reloadHistorySize+10; i++ {
+		_ = d.ReLoad()
+	}
+	if rate := d.ReloadRate(time.Minute); rate != reloadHistorySize {
+		t.Error(`expected the rate to be capped by the history, but got: `, rate)
+	}
+	d.StopAndJoin()
+}
@@ -0,0 +1,55 @@
+package go_drain
+
+import "errors"
+
+// ErrDrainNotStopped is returned by Reset when the Drain has not been stopped
+var ErrDrainNotStopped = errors.New(`drain not stopped`)
+
+// ErrClaimsOutstanding is returned by Reset when the Drain is stopped, but not every claim has been Released
+var ErrClaimsOutstanding = errors.New(`claims outstanding`)
+
+// Reset makes a stopped and fully drained Drain usable again, keeping its
+// loadAndTester, closer, and options. The initial load is re-run to establish
+// version 1, just as New does. This is useful for test suites and restartable
+// subsystems that would otherwise have to re-wire a new Drain.
+//
+// Reset must not be called concurrently with ReLoad
+// @return ErrDrainNotStopped if the Drain is running, ErrClaimsOutstanding if
+//   claims have not been Released, or the error from loadAndTester, in which
+//   case the Drain remains stopped
+func (d *Drain) Reset() (err error) {
+	d.mu.Lock()
+	if !d.isStopped {
+		d.mu.Unlock()
+		return ErrDrainNotStopped
+	}
+	if d.versionTracking.Len() != 0 {
+		// the last version is only removed once all of its claims are released
+		d.mu.Unlock()
+		return ErrClaimsOutstanding
+	}
+	d.isStopped = false
+	d.stopped = make(chan struct{})
+	d.ctx, d.cancelCtx = nil, nil
+	// claims made after the reset have not been canceled
+	d.canceledOutstanding = false
+	d.cancelers = nil
+	d.mu.Unlock()
+
+	cv, _, err := d.doLoadAndTest(d.loadAndTester)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.isStopped = true
+		close(d.stopped)
+		return err
+	}
+	cv.version = 1
+	d.versionTracking.PushBack(&cv)
+	d.updateMirror(cv.config)
+	d.restartStuckMonitor()
+	d.startHealthMonitor()
+	d.startPeriodicReloads()
+	return nil
+}
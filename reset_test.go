@@ -0,0 +1,62 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDrain_Reset(t *testing.T) {
+	loaded := 0
+	failLoad := false
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		if failLoad {
+			return nil, errors.New(`load failed`)
+		}
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Reset(); err != ErrDrainNotStopped {
+		t.Error(`expected ErrDrainNotStopped, but got: `, err)
+	}
+
+	held, _ := d.Claim()
+	d.Stop()
+	if err = d.Reset(); err != ErrClaimsOutstanding {
+		t.Error(`expected ErrClaimsOutstanding, but got: `, err)
+	}
+	d.Release(&held)
+
+	failLoad = true
+	if err = d.Reset(); err == nil {
+		t.Error(`expected the load error`)
+	}
+	if _, err = d.Claim(); err != ErrDrainAlreadyStopped {
+		t.Error(`expected the drain to remain stopped, but got: `, err)
+	}
+
+	failLoad = false
+	if err = d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	cc, err := d.Claim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.Version() != 1 || cc.Config().(*myConfig).name != `v2` {
+		t.Error(`expected the freshly loaded config as version 1, but got: `, cc.Version(), cc.Config())
+	}
+	d.Release(&cc)
+
+	d.StopAndJoin()
+	if fmt.Sprint(closed) != `[v1 v2]` {
+		t.Error(`expected both configs to be closed, but got: `, closed)
+	}
+	assertBalanced(t, d)
+}
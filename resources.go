@@ -0,0 +1,55 @@
+package go_drain
+
+import "errors"
+
+// ErrTooManyVersions is returned by ReLoad when publishing would exceed the cap
+// set by SetMaxConcurrentVersionResources and onExceed did not supply an error
+var ErrTooManyVersions = errors.New(`too many versions holding resources`)
+
+// SetMaxConcurrentVersionResources caps how many versions may be live at once,
+// that is, published and not yet fully drained and closed. When draining is
+// slow, each stacked version may hold expensive resources such as connections,
+// multiplying usage. With the cap set, ReLoad fails instead of publishing a
+// version that would push the number of live versions above n, and the new
+// configuration is closed. Versions that would be closed by the swap itself
+// because nothing claims them do not count.
+//
+// This is intentional backpressure: reloads succeed again once old versions
+// are Released
+// @param n is the most versions that may be live. 0 to remove the cap
+// @param onExceed is called without the lock to produce the error ReLoad fails
+//   with. If nil or it returns nil, ReLoad fails with ErrTooManyVersions
+func (d *Drain) SetMaxConcurrentVersionResources(n int, onExceed func() error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxVersionResources = n
+	d.onExceedVersionResources = onExceed
+}
+
+// exceedsVersionResources is true if publishing another version would exceed maxVersionResources
+//
+// Assumes that the d.mu is locked
+func (d *Drain) exceedsVersionResources() bool {
+	if d.maxVersionResources <= 0 {
+		return false
+	}
+	live := d.versionTracking.Len() + 1
+	// the current version is closed by the swap if nothing claims it
+	if back := d.versionTracking.Back(); d.linger <= 0 && d.shouldCleanup(*back.Value.(*configVersion)) {
+		live--
+	}
+	return live > d.maxVersionResources
+}
+
+// versionResourcesError gets the error a reload rejected by SetMaxConcurrentVersionResources fails with
+func versionResourcesError(onExceed func() error) (err error) {
+	if onExceed != nil {
+		if protect(CallbackSiteOnExceed, func() { err = onExceed() }) {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = ErrTooManyVersions
+	}
+	return
+}
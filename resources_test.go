@@ -0,0 +1,59 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDrain_SetMaxConcurrentVersionResources(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errBackpressure := errors.New(`backpressure`)
+	d.SetMaxConcurrentVersionResources(2, func() error { return errBackpressure })
+
+	// unclaimed versions are closed by the swap and do not stack
+	_ = d.ReLoad()
+	_ = d.ReLoad()
+	if err = d.ReLoad(); err != nil {
+		t.Fatal(`expected idle versions not to count, but got: `, err)
+	}
+
+	// stack v4 and v5 by holding claims on them
+	held4, _ := d.Claim()
+	_ = d.ReLoad()
+	held5, _ := d.Claim()
+	if err = d.ReLoad(); err != errBackpressure {
+		t.Error(`expected the reload past the cap to be rejected, but got: `, err)
+	}
+	if closed[len(closed)-1] != `v6` {
+		t.Error(`expected the rejected config to be closed, but got: `, closed)
+	}
+	if d.CurrentVersion() != 5 {
+		t.Error(`expected the current version to stay active, but got: `, d.CurrentVersion())
+	}
+
+	// draining the old version relieves the pressure
+	d.Release(&held4)
+	if err = d.ReLoad(); err != nil {
+		t.Error(`expected the reload to succeed once drained, but got: `, err)
+	}
+	d.Release(&held5)
+
+	d.SetMaxConcurrentVersionResources(1, nil)
+	held, _ := d.Claim()
+	if err = d.ReLoad(); err != ErrTooManyVersions {
+		t.Error(`expected ErrTooManyVersions without onExceed, but got: `, err)
+	}
+	d.Release(&held)
+	d.StopAndJoin()
+}
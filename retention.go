@@ -0,0 +1,79 @@
+package go_drain
+
+// SetRetentionSizer bounds the memory held by versions that linger after they
+// drain, see NewWithLinger. Whenever a version starts lingering, the sizes of
+// the lingering versions are totaled and, while the total is over
+// maxRetainedBytes, the oldest lingering versions are closed early, before
+// their linger expires. A version is only sized once. The sizer should
+// estimate from the configuration's own fields, as it's called without locks
+// and may race with the close of a version whose linger expires at that moment
+// @param sizer estimates the bytes held by a configuration. Pass nil to stop
+//   bounding lingering versions by size
+// @param maxRetainedBytes is the most bytes that lingering versions may hold in total
+func (d *Drain) SetRetentionSizer(sizer func(config interface{}) int64, maxRetainedBytes int64) {
+	d.mu.Lock()
+	d.retentionSizer = sizer
+	d.maxRetainedBytes = maxRetainedBytes
+	d.mu.Unlock()
+	d.enforceRetentionBudget()
+}
+
+// enforceRetentionBudget sizes the lingering versions that have not been
+// sized, then closes the oldest lingering versions while they hold more than
+// maxRetainedBytes
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) enforceRetentionBudget() {
+	d.mu.Lock()
+	sizer := d.retentionSizer
+	if sizer == nil {
+		d.mu.Unlock()
+		return
+	}
+	unsized := make([]*configVersion, 0)
+	for e := d.versionTracking.Front(); e != nil; e = e.Next() {
+		if ccv := e.Value.(*configVersion); ccv.lingering != nil && !ccv.retentionSized {
+			unsized = append(unsized, ccv)
+		}
+	}
+	d.mu.Unlock()
+
+	// unlock while calling sizer, could be long
+	sizes := make([]int64, len(unsized))
+	for i, ccv := range unsized {
+		if protect(CallbackSiteRetentionSizer, func() { sizes[i] = sizer(ccv.config) }) {
+			sizes[i] = 0
+		}
+	}
+
+	d.mu.Lock()
+	for i, ccv := range unsized {
+		ccv.retainedBytes = sizes[i]
+		ccv.retentionSized = true
+	}
+	var total int64
+	for e := d.versionTracking.Front(); e != nil; e = e.Next() {
+		if ccv := e.Value.(*configVersion); ccv.lingering != nil {
+			total += ccv.retainedBytes
+		}
+	}
+	toClose := make([]*configVersion, 0)
+	for e := d.versionTracking.Front(); e != nil && total > d.maxRetainedBytes; {
+		next := e.Next()
+		if ccv := e.Value.(*configVersion); ccv.lingering != nil && d.shouldCleanup(*ccv) {
+			ccv.stopLinger()
+			total -= ccv.retainedBytes
+			d.versionTracking.Remove(e)
+			toClose = append(toClose, ccv)
+		}
+		e = next
+	}
+	latestVersion := d.latestVersion()
+	d.mu.Unlock()
+
+	// unlock while calling closer, could be long
+	for _, ccv := range toClose {
+		d.drainCompleted(ccv)
+		d.closeConfig(ccv.version, ccv.config, latestVersion, CloseReasonReplaced)
+	}
+}
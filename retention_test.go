@@ -0,0 +1,58 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDrain_SetRetentionSizer(t *testing.T) {
+	sizes := map[string]int64{`v1`: 40, `v2`: 50, `v3`: 30}
+	loaded := 0
+	closed := make(chan string, 4)
+	d, err := NewWithLinger(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed <- configToClose.(*myConfig).name
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetRetentionSizer(func(config interface{}) int64 {
+		return sizes[config.(*myConfig).name]
+	}, 100)
+
+	// v1 and v2 linger within the budget
+	for i := 0; i < 2; i++ {
+		held, _ := d.Claim()
+		_ = d.ReLoad()
+		d.Release(&held)
+	}
+	select {
+	case name := <-closed:
+		t.Error(`expected v1 and v2 to linger within the budget, but closed: `, name)
+	default:
+	}
+
+	// v3 puts retention over budget, so the oldest is closed early
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+	d.Release(&held)
+	select {
+	case name := <-closed:
+		if name != `v1` {
+			t.Error(`expected the oldest lingering version to be closed, but closed: `, name)
+		}
+	default:
+		t.Error(`expected v1 to be closed to stay under budget`)
+	}
+	lingering := make([]uint64, 0)
+	for _, op := range d.PendingOperations() {
+		lingering = append(lingering, op.Version)
+	}
+	if fmt.Sprint(lingering) != `[2 3]` {
+		t.Error(`expected v2 and v3 to keep lingering, got `, lingering)
+	}
+	d.StopAndJoin()
+}
@@ -0,0 +1,120 @@
+package go_drain
+
+import "context"
+
+// retireSignal is how the holder of a claim learns that its version was retired
+type retireSignal struct {
+	// version is the claimed version
+	version uint64
+
+	// retired is closed when version is superseded or the Drain stops. nil if cancel signals instead
+	retired chan struct{}
+
+	// cancel is called when version is superseded, the Drain stops, or the claim is Released. nil if retired signals instead
+	cancel context.CancelFunc
+}
+
+// ClaimWithRetireSignal is Claim, but also returns a channel that is closed
+// when the claimed version is superseded by a reload or the Drain stops. This
+// lets a long-running holder select on it to learn that it should wrap up and
+// Release the claim. The channel is closed at most once, and it's safe to never
+// read it
+// @return cc the claim or an invalidated claim if there was an error
+// @return retired is closed when the claimed version is retired, nil if there was an error
+// @return err the error returned by Claim
+func (d *Drain) ClaimWithRetireSignal() (cc ConfigClaim, retired <-chan struct{}, err error) {
+	if cc, err = d.Claim(); err != nil {
+		return
+	}
+	signal := retireSignal{version: cc.version, retired: make(chan struct{})}
+	d.watchRetire(&cc, signal)
+	return cc, signal.retired, nil
+}
+
+// ClaimUntilSuperseded is the context flavored ClaimWithRetireSignal. It's
+// Claim, but also returns a context derived from parent that is canceled when
+// the claimed version is superseded by a reload, the Drain stops, or parent is
+// done. Pass it to work done with the configuration so that the work aborts
+// once the configuration is stale. The context is also canceled when the claim
+// is Released
+// @param parent is the context to derive from
+// @return cc the claim or an invalidated claim if the claim failed
+// @return ctx the derived context. It's already canceled if the claim failed
+func (d *Drain) ClaimUntilSuperseded(parent context.Context) (cc ConfigClaim, ctx context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	cc, err := d.Claim()
+	if err != nil {
+		cancel()
+		return
+	}
+	d.watchRetire(&cc, retireSignal{version: cc.version, cancel: cancel})
+	return
+}
+
+// ClaimWithRetireHook is Claim, but onRetire is called once the claimed
+// version is retired: after it was superseded or the Drain stopped, and every
+// claim on it, not just this one, has been Released. Each claimant on a version
+// gets its own hook called, in the order they were registered, just before the
+// version is closed. This ties per-consumer cleanup, such as dropping a cache
+// derived from the configuration, to the lifecycle of the version. Unlike
+// ClaimWithRetireSignal, onRetire is not called when the version is merely
+// superseded, as claims may still be using it
+// @param onRetire is called once, on the go routine that retires the version
+// @return cc the claim or an invalidated claim if there was an error
+// @return err the error returned by Claim, in which case onRetire is never called
+func (d *Drain) ClaimWithRetireHook(onRetire func()) (cc ConfigClaim, err error) {
+	if cc, err = d.Claim(); err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// the claim keeps the version from being retired
+	if e := d.findElementWithVersion(cc.version); e != nil {
+		ccv := e.Value.(*configVersion)
+		ccv.retireHooks = append(ccv.retireHooks, onRetire)
+	}
+	return
+}
+
+// watchRetire registers signal to be signaled when the version of cc is retired,
+// signaling it at once if that has already happened
+func (d *Drain) watchRetire(cc *ConfigClaim, signal retireSignal) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cc.id == 0 {
+		d.lastClaimID++
+		cc.id = d.lastClaimID
+	}
+	// a reload or stop may have retired the version since it was claimed
+	if back := d.versionTracking.Back(); d.isStopped || back == nil || back.Value.(*configVersion).version != cc.version {
+		signal.signal()
+		return
+	}
+	if d.retireSignals == nil {
+		d.retireSignals = make(map[uint64]retireSignal)
+	}
+	d.retireSignals[cc.id] = signal
+}
+
+// signal tells the holder of the claim that its version was retired
+func (s retireSignal) signal() {
+	if s.retired != nil {
+		close(s.retired)
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// signalRetired signals the retire signals of claims on version
+// @param version is the retired version, 0 to signal every claim
+//
+// Assumes that the d.mu is locked
+func (d *Drain) signalRetired(version uint64) {
+	for id, signal := range d.retireSignals {
+		if version == 0 || signal.version == version {
+			signal.signal()
+			delete(d.retireSignals, id)
+		}
+	}
+}
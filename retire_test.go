@@ -0,0 +1,166 @@
+package go_drain
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func isClosed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	case <-time.After(10 * time.Millisecond):
+		return false
+	}
+}
+
+func TestDrain_ClaimWithRetireSignal(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, oldRetired, err := d.ClaimWithRetireSignal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// never read by the holder
+	unread, _, _ := d.ClaimWithRetireSignal()
+	if isClosed(oldRetired) {
+		t.Error(`expected the signal to stay open while the version is current`)
+	}
+
+	_ = d.ReLoad()
+	if !isClosed(oldRetired) {
+		t.Error(`expected the signal to close when the version is superseded`)
+	}
+	d.Release(&old)
+
+	// another reload must not close it twice
+	current, currentRetired, err := d.ClaimWithRetireSignal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = d.ReLoad()
+	d.Release(&current)
+	if !isClosed(currentRetired) {
+		t.Error(`expected the signal to close when the version is superseded`)
+	}
+
+	held, heldRetired, _ := d.ClaimWithRetireSignal()
+	d.Stop()
+	if !isClosed(heldRetired) {
+		t.Error(`expected the signal to close when the drain stops`)
+	}
+	d.Release(&held)
+	d.Release(&unread)
+	d.StopAndJoin()
+}
+
+func TestDrain_ClaimWithRetireSignal_Released(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc, retired, _ := d.ClaimWithRetireSignal()
+	d.Release(&cc)
+	_ = d.ReLoad()
+	if isClosed(retired) {
+		t.Error(`expected released claims not to be signaled`)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_ClaimUntilSuperseded(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, oldCtx := d.ClaimUntilSuperseded(context.Background())
+	if old.Version() != 1 {
+		t.Fatal(`expected to claim version 1, but got: `, old.Version())
+	}
+	if isClosed(oldCtx.Done()) {
+		t.Error(`expected the context to stay live while the version is current`)
+	}
+	_ = d.ReLoad()
+	if !isClosed(oldCtx.Done()) {
+		t.Error(`expected the context to be canceled when the version is superseded`)
+	}
+	d.Release(&old)
+
+	parent, cancelParent := context.WithCancel(context.Background())
+	cc, ctx := d.ClaimUntilSuperseded(parent)
+	cancelParent()
+	if !isClosed(ctx.Done()) {
+		t.Error(`expected the context to be canceled with its parent`)
+	}
+	d.Release(&cc)
+
+	cc, ctx = d.ClaimUntilSuperseded(context.Background())
+	d.Release(&cc)
+	if !isClosed(ctx.Done()) {
+		t.Error(`expected the context to be canceled when the claim is released`)
+	}
+
+	cc, ctx = d.ClaimUntilSuperseded(context.Background())
+	d.Stop()
+	if !isClosed(ctx.Done()) {
+		t.Error(`expected the context to be canceled when the drain stops`)
+	}
+	d.Release(&cc)
+	d.StopAndJoin()
+
+	if cc, ctx = d.ClaimUntilSuperseded(context.Background()); cc.Version() != 0 || !isClosed(ctx.Done()) {
+		t.Error(`expected a failed claim to return a canceled context`)
+	}
+}
+
+func TestDrain_ClaimWithRetireHook(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retired := make([]string, 0)
+	first, _ := d.ClaimWithRetireHook(func() {
+		retired = append(retired, `first`)
+	})
+	second, _ := d.ClaimWithRetireHook(func() {
+		retired = append(retired, `second`)
+	})
+
+	_ = d.ReLoad()
+	if len(retired) != 0 {
+		t.Error(`expected no hooks while the superseded version is claimed, got `, retired)
+	}
+	d.Release(&first)
+	if len(retired) != 0 {
+		t.Error(`expected no hooks while the other claimant holds the version, got `, retired)
+	}
+	d.Release(&second)
+	if fmt.Sprint(retired) != `[first second]` {
+		t.Error(`expected both hooks once the version was retired, got `, retired)
+	}
+
+	// the current version is retired when the Drain stops
+	cc, _ := d.ClaimWithRetireHook(func() {
+		retired = append(retired, `current`)
+	})
+	d.Release(&cc)
+	d.StopAndJoin()
+	if fmt.Sprint(retired) != `[first second current]` {
+		t.Error(`expected the hook of the current version once the Drain stopped, got `, retired)
+	}
+}
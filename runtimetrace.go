@@ -0,0 +1,34 @@
+package go_drain
+
+import (
+	"context"
+	"runtime/trace"
+	"strconv"
+	"sync/atomic"
+)
+
+// SetRuntimeTracing sets whether claims show up as regions in execution traces
+// from the runtime/trace package, for use with go tool trace. When on and a
+// trace is being collected, each claim starts a region named "drain-claim-v"
+// followed by the claimed version, and Release ends it. Regions must end on the
+// go routine that started them, so claims must be Released on the go routine
+// that made them for the trace to be accurate. When off, or when no trace is
+// being collected, claims cost nothing extra
+// @param enabled is true to start regions for claims, false to stop
+func (d *Drain) SetRuntimeTracing(enabled bool) {
+	var flag int32
+	if enabled {
+		flag = 1
+	}
+	atomic.StoreInt32(&d.runtimeTracing, flag)
+}
+
+// startClaimRegion starts the execution trace region of a claim on version, if
+// runtime tracing is on and a trace is being collected
+// @return the region or nil if none was started
+func (d *Drain) startClaimRegion(version uint64) *trace.Region {
+	if atomic.LoadInt32(&d.runtimeTracing) == 0 || !trace.IsEnabled() {
+		return nil
+	}
+	return trace.StartRegion(context.Background(), `drain-claim-v`+strconv.FormatUint(version, 10))
+}
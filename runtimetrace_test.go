@@ -0,0 +1,48 @@
+package go_drain
+
+import (
+	"bytes"
+	"runtime/trace"
+	"testing"
+)
+
+func TestDrain_SetRuntimeTracing(t *testing.T) {
+	d, err := NewWithShardedClaims(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = trace.Start(&buf); err != nil {
+		t.Skip(`a trace is already being collected: `, err)
+	}
+
+	// no regions while off
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {})
+	_ = d.ReLoad()
+	d.SetRuntimeTracing(true)
+	cc, _ := d.Claim()
+	if cc.region == nil {
+		t.Error(`expected the claim to start a region`)
+	}
+	d.Release(&cc)
+	d.SetRuntimeTracing(false)
+	cc, _ = d.Claim()
+	if cc.region != nil {
+		t.Error(`expected no region once tracing is off`)
+	}
+	d.Release(&cc)
+	trace.Stop()
+
+	if bytes.Contains(buf.Bytes(), []byte(`drain-claim-v1`)) {
+		t.Error(`expected no region for the claim made while off`)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`drain-claim-v2`)) {
+		t.Error(`expected a region for the claim made while on`)
+	}
+	if d.TotalOutstandingClaims() != 0 || d.PendingCloses() != 0 {
+		t.Error(`expected every claim to be released, but got: `, d.TotalOutstandingClaims(), d.PendingCloses())
+	}
+	d.StopAndJoin()
+}
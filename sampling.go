@@ -0,0 +1,111 @@
+package go_drain
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ClaimSample is a released claim recorded by a Drain created with NewWithClaimSampling
+type ClaimSample struct {
+	// Version is the version that was claimed
+	Version uint64
+
+	// Held is how long the claim was held, from Claim to Release
+	Held time.Duration
+}
+
+// NewWithClaimSampling is New, but the version and hold duration of the most
+// recently released claims are kept in a fixed-size ring buffer, see
+// RecentClaims. This is lightweight profiling, cheap enough to leave on and
+// dump on demand, such as on a signal. Recording a release does not lock or
+// allocate
+// @param size is how many released claims are kept. Less than 1 is treated as 1
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithClaimSampling(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	size int,
+) (c *Drain, err error) {
+	if size < 1 {
+		size = 1
+	}
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.claimSamples = &claimSampleRing{slots: make([]claimSampleSlot, size)}
+	})
+}
+
+// RecentClaims gets the most recently released claims, oldest first. Only
+// populated for Drains created with NewWithClaimSampling. Claims being recorded
+// by a concurrent Release may be left out
+// @param n is the most samples to return. At most the size given to
+//   NewWithClaimSampling are kept
+// @return the samples or empty if none were recorded or sampling is off
+func (d *Drain) RecentClaims(n int) []ClaimSample {
+	if d.claimSamples == nil || n < 1 {
+		return []ClaimSample{}
+	}
+	return d.claimSamples.recent(n)
+}
+
+// claimSampleRing is a lock-free ring buffer of released claims. Writers
+// claim a slot by advancing next, then publish the sample by setting the
+// slot's seq, which readers check before and after reading it
+type claimSampleRing struct {
+	// next is the sequence number of the next sample. Accessed atomically, kept
+	// first to be 64-bit aligned
+	next uint64
+
+	// slots hold the samples, the sample with sequence number s is in slot s % len(slots)
+	slots []claimSampleSlot
+}
+
+// claimSampleSlot holds one sample. All fields are accessed atomically
+type claimSampleSlot struct {
+	// seq is one more than the sequence number of the sample in the slot, 0 while it's being written
+	seq uint64
+
+	// version is the version that was claimed
+	version uint64
+
+	// held is the hold duration in nanoseconds
+	held int64
+}
+
+// record adds a sample, overwriting the oldest if the ring is full
+func (r *claimSampleRing) record(version uint64, held time.Duration) {
+	seq := atomic.AddUint64(&r.next, 1) - 1
+	slot := &r.slots[seq%uint64(len(r.slots))]
+	atomic.StoreUint64(&slot.seq, 0)
+	atomic.StoreUint64(&slot.version, version)
+	atomic.StoreInt64(&slot.held, int64(held))
+	atomic.StoreUint64(&slot.seq, seq+1)
+}
+
+// recent gets up to n of the latest samples, oldest first, skipping any that are being written
+func (r *claimSampleRing) recent(n int) []ClaimSample {
+	next := atomic.LoadUint64(&r.next)
+	if size := uint64(len(r.slots)); uint64(n) > size {
+		n = int(size)
+	}
+	if uint64(n) > next {
+		n = int(next)
+	}
+	samples := make([]ClaimSample, 0, n)
+	for seq := next - uint64(n); seq < next; seq++ {
+		slot := &r.slots[seq%uint64(len(r.slots))]
+		if atomic.LoadUint64(&slot.seq) != seq+1 {
+			continue
+		}
+		sample := ClaimSample{
+			Version: atomic.LoadUint64(&slot.version),
+			Held:    time.Duration(atomic.LoadInt64(&slot.held)),
+		}
+		if atomic.LoadUint64(&slot.seq) != seq+1 {
+			// overwritten while reading
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
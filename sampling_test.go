@@ -0,0 +1,54 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNewWithClaimSampling(t *testing.T) {
+	clock := newFakeClock()
+	d, err := NewWithClaimSampling(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetClock(clock)
+	if samples := d.RecentClaims(3); len(samples) != 0 {
+		t.Error(`expected no samples yet, but got: `, samples)
+	}
+
+	// hold claims for 1s, 2s, 3s, then 4s on version 2
+	for i := 1; i <= 4; i++ {
+		if i == 4 {
+			_ = d.ReLoad()
+		}
+		cc, _ := d.Claim()
+		clock.Advance(time.Duration(i) * time.Second)
+		d.Release(&cc)
+	}
+
+	if samples := fmt.Sprint(d.RecentClaims(2)); samples != `[{1 3s} {2 4s}]` {
+		t.Error(`expected the last 2 releases, oldest first, but got: `, samples)
+	}
+	// only the size given is kept
+	if samples := fmt.Sprint(d.RecentClaims(10)); samples != `[{1 2s} {1 3s} {2 4s}]` {
+		t.Error(`expected the last 3 releases, but got: `, samples)
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_RecentClaims_NotSampling(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {})
+	if samples := d.RecentClaims(1); len(samples) != 0 {
+		t.Error(`expected no samples without sampling, but got: `, samples)
+	}
+	d.StopAndJoin()
+}
@@ -0,0 +1,153 @@
+package go_drain
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// claimShard counts claims on one shard of a version. It's padded to a cache
+// line so that go routines counting on different shards do not contend
+type claimShard struct {
+	count int64
+	_     [56]byte
+}
+
+// NewWithShardedClaims is New, but reduces contention under heavy claim churn.
+// Plain Claim and Release of the latest version share a read lock and count
+// the claim in one of several per-version shards, rather than serializing on
+// the Drain's lock and a single counter. Outstanding claims are summed across
+// the shards whenever they are needed, such as to decide if a version can be
+// closed, and StopAndJoin still waits for every claim to be Released.
+//
+// Claims fall back to the regular path whenever a feature needs to inspect
+// each claim, such as goroutine tracking, the hold watchdog, claim smoothing,
+// weighted versions, or an exclusive reload. Until the Drain is stopped,
+// PendingCloses does not include claims counted in shards
+// @param shards is how many shards each version counts claims in. Less than 1
+//   uses runtime.GOMAXPROCS(0)
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithShardedClaims(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	shards int,
+) (c *Drain, err error) {
+	if shards < 1 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.claimShards = shards
+	})
+}
+
+// claims counts the outstanding claims on the version, across its shards
+func (cv configVersion) claims() uint64 {
+	total := cv.count
+	for i := range cv.shards {
+		total += uint64(atomic.LoadInt64(&cv.shards[i].count))
+	}
+	return total
+}
+
+// newClaimShards makes the shards a new version counts its claims in
+// @return the shards or nil if the Drain does not shard claims
+func (d *Drain) newClaimShards() []claimShard {
+	if d.claimShards == 0 {
+		return nil
+	}
+	return make([]claimShard, d.claimShards)
+}
+
+// claimFast claims the latest version under the read lock, counting the claim
+// in a shard
+// @return false if the claim must take the regular path
+func (d *Drain) claimFast(cc *ConfigClaim) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if !d.fastPathAvailable() {
+		return false
+	}
+	e := d.versionTracking.Back()
+	if e == nil {
+		return false
+	}
+	ccv := e.Value.(*configVersion)
+	if ccv.lingering != nil || ccv.shards == nil {
+		return false
+	}
+	shard := shardFor(cc, len(ccv.shards))
+	atomic.AddInt64(&ccv.shards[shard].count, 1)
+	atomic.AddUint64(&ccv.totalClaims, 1)
+	*cc = ConfigClaim{
+		version: ccv.version,
+		drainID: d.id,
+		config:  ccv.config,
+		meta:    ccv.meta,
+		shard:   shard + 1,
+	}
+	if d.timesClaims() {
+		cc.claimedAt = d.now()
+	}
+	cc.region = d.startClaimRegion(ccv.version)
+	return true
+}
+
+// releaseFast releases a claim counted in a shard under the read lock. Only
+// claims on the latest version of a running Drain are released this way, as
+// releasing any other claim may close the version
+// @return false if the release must take the regular path
+func (d *Drain) releaseFast(cc *ConfigClaim) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.isStopped || cc.id != 0 {
+		return false
+	}
+	e := d.versionTracking.Back()
+	if e == nil || e == d.exclusiveWaitOn {
+		return false
+	}
+	ccv := e.Value.(*configVersion)
+	if ccv.version != cc.version {
+		return false
+	}
+	atomic.AddInt64(&ccv.shards[cc.shard-1].count, -1)
+	return true
+}
+
+// fastPathAvailable is true if claims may skip the regular path
+//
+// Assumes that the d.mu is read locked
+func (d *Drain) fastPathAvailable() bool {
+	return !d.isStopped &&
+		d.exclusiveGate == nil &&
+		d.activeWeights == nil &&
+		d.smoothingRate == 0 &&
+		!d.trackGoroutines &&
+		d.holdThreshold == 0
+}
+
+// countShardedClaims counts the claims in every shard in closeWg, so that
+// StopAndJoin waits for them. Once stopped, claims are only Released on the
+// regular path, which counts them out of closeWg
+//
+// Assumes that the d.mu is locked and the Drain is stopping
+func (d *Drain) countShardedClaims() {
+	if d.claimShards == 0 {
+		return
+	}
+	for e := d.versionTracking.Front(); e != nil; e = e.Next() {
+		ccv := e.Value.(*configVersion)
+		sharded := ccv.claims() - ccv.count
+		atomic.AddInt64(&d.pendingCloseCount, int64(sharded))
+		d.closeWg.Add(int(sharded))
+	}
+}
+
+// shardFor picks the shard to count a claim in. Claims made on different go
+// routines are held in different stacks or allocations, so the address of the
+// claim spreads them across shards without any shared state
+func shardFor(cc *ConfigClaim, shards int) int {
+	h := uint64(uintptr(unsafe.Pointer(cc))) * 0x9E3779B97F4A7C15
+	return int((h >> 32) % uint64(shards))
+}
@@ -0,0 +1,120 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewWithShardedClaims(t *testing.T) {
+	loadCalled := 0
+	closed := make([]string, 0)
+	d, err := NewWithShardedClaims(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held1, _ := d.Claim()
+	held2, _ := d.Claim()
+	if held1.shard == 0 {
+		t.Error(`expected the claim to be counted in a shard`)
+	}
+	if d.TotalOutstandingClaims() != 2 {
+		t.Error(`expected the claims to be summed across shards, but got: `, d.TotalOutstandingClaims())
+	}
+	_ = d.ReLoad()
+	if len(closed) != 0 {
+		t.Error(`expected the claimed version to stay open, but closed: `, closed)
+	}
+	d.Release(&held1)
+	d.Release(&held2)
+	if fmt.Sprint(closed) != `[v1]` {
+		t.Error(`expected the old version to be closed once its shards drained, but got: `, closed)
+	}
+
+	held, _ := d.Claim()
+	d.Stop()
+	if d.PendingCloses() != 1 {
+		t.Error(`expected the sharded claim to be counted once stopped, but got: `, d.PendingCloses())
+	}
+	d.Release(&held)
+	d.StopAndJoin()
+	if fmt.Sprint(closed) != `[v1 v2]` {
+		t.Error(`expected the last version to be closed at shutdown, but got: `, closed)
+	}
+}
+
+func TestNewWithShardedClaims_Race(t *testing.T) {
+	opened, closed := int64(0), int64(0)
+	d, err := NewWithShardedClaims(func(currentConfig interface{}) (config interface{}, err error) {
+		atomic.AddInt64(&opened, 1)
+		return &closableConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		if !atomic.CompareAndSwapInt32(&configToClose.(*closableConfig).closed, 0, 1) {
+			t.Error(`expected each config to be closed once`)
+		}
+		atomic.AddInt64(&closed, 1)
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+					if atomic.LoadInt32(&currentlyRunningConfig.(*closableConfig).closed) != 0 {
+						t.Error(`expected claims to never see a closed config`)
+					}
+				})
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		_ = d.ReLoad()
+	}
+	held, _ := d.Claim()
+	go func() {
+		d.Release(&held)
+	}()
+	d.StopAndJoin()
+	wg.Wait()
+	if atomic.LoadInt64(&opened) != atomic.LoadInt64(&closed) {
+		t.Error(`expected every config to be closed, but opened `, opened, ` and closed `, closed)
+	}
+}
+
+func benchmarkParallelClaims(b *testing.B, d *Drain) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var cc ConfigClaim
+		for pb.Next() {
+			_ = d.ClaimInto(&cc)
+			d.Release(&cc)
+		}
+	})
+}
+
+func BenchmarkDrain_ClaimParallel(b *testing.B) {
+	d, _ := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	benchmarkParallelClaims(b, d)
+}
+
+func BenchmarkDrain_ClaimParallelSharded(b *testing.B) {
+	d, _ := NewWithShardedClaims(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, 0)
+	benchmarkParallelClaims(b, d)
+}
@@ -0,0 +1,84 @@
+package go_drain
+
+import (
+	"container/list"
+	"errors"
+	"time"
+)
+
+// ErrInvalidSmoothingRate is returned by NewWithClaimSmoothing when rate is less than 1
+var ErrInvalidSmoothingRate = errors.New(`smoothing rate must be at least 1`)
+
+// ClaimSmoothingWindow is how long after a reload claims are smoothed onto the
+// new version by a Drain created with NewWithClaimSmoothing
+const ClaimSmoothingWindow = time.Second
+
+// NewWithClaimSmoothing is New, but smooths the migration of claims to a new
+// version right after a reload. Without smoothing, every go routine claiming
+// after a reload lands on the new version at once, which can spike contention
+// on the new version's resources, such as a fresh connection pool. With
+// smoothing, for ClaimSmoothingWindow after a reload, claims move to the new
+// version at up to rate per second, while the rest continue to get the version
+// that was current before the reload, as long as it has not been retired
+// @param rate is how many claims per second may move to the new version
+//   while smoothing, must be at least 1
+// @return c the Drain object or nil, if there was an error
+// @return err ErrInvalidSmoothingRate if rate is less than 1, or any errors
+//   encountered when loading or testing the config
+func NewWithClaimSmoothing(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	rate int,
+) (c *Drain, err error) {
+	if rate < 1 {
+		return nil, ErrInvalidSmoothingRate
+	}
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.smoothingRate = rate
+	})
+}
+
+// startSmoothing begins smoothing claims onto the new version
+// @param previous is the version that was current before the reload
+//
+// Assumes that the d.mu is locked
+func (d *Drain) startSmoothing(previous *configVersion) {
+	now := d.now()
+	d.smoothingFrom = previous
+	d.smoothingUntil = now.Add(ClaimSmoothingWindow)
+	d.smoothingTokens = 0
+	d.smoothingRefilled = now
+}
+
+// smoothClaim picks which version a claim gets while smoothing
+// @param latest is the element of the latest version
+// @return latest if not smoothing or a token is available, otherwise the
+//   version that was current before the reload
+//
+// Assumes that the d.mu is locked
+func (d *Drain) smoothClaim(latest *list.Element) *list.Element {
+	if d.smoothingFrom == nil {
+		return latest
+	}
+	now := d.now()
+	if !now.Before(d.smoothingUntil) {
+		d.smoothingFrom = nil
+		return latest
+	}
+	// fall back only while the version that was current is still tracked
+	previous := d.findElementWithVersion(d.smoothingFrom.version)
+	if previous == nil || previous == latest {
+		return latest
+	}
+	// refill the bucket up to one second of claims
+	d.smoothingTokens += now.Sub(d.smoothingRefilled).Seconds() * float64(d.smoothingRate)
+	if max := float64(d.smoothingRate); d.smoothingTokens > max {
+		d.smoothingTokens = max
+	}
+	d.smoothingRefilled = now
+	if d.smoothingTokens >= 1 {
+		d.smoothingTokens--
+		return latest
+	}
+	return previous
+}
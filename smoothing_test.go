@@ -0,0 +1,166 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewWithClaimSmoothing(t *testing.T) {
+	loadCalled := 0
+	d, err := NewWithClaimSmoothing(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keep v1 alive so that claims can stay on it
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+
+	claims := make([]ConfigClaim, 0)
+	versions := make(map[uint64]int)
+	for i := 0; i < 20; i++ {
+		cc, _ := d.Claim()
+		versions[cc.Version()]++
+		claims = append(claims, cc)
+	}
+	if versions[1] == 0 {
+		t.Error(`expected some claims to stay on the previous version, but got: `, versions)
+	}
+
+	// once the tokens refill, claims move over
+	time.Sleep(150 * time.Millisecond)
+	cc, _ := d.Claim()
+	if cc.Version() != 2 {
+		t.Error(`expected a claim to move to the new version after refilling, but got `, cc.Version())
+	}
+	d.Release(&cc)
+
+	// once the window ends, every claim gets the new version
+	time.Sleep(ClaimSmoothingWindow)
+	for i := 0; i < 20; i++ {
+		cc, _ = d.Claim()
+		if cc.Version() != 2 {
+			t.Fatal(`expected the new version after the smoothing window, but got `, cc.Version())
+		}
+		d.Release(&cc)
+	}
+
+	for i := range claims {
+		d.Release(&claims[i])
+	}
+	d.Release(&held)
+	d.StopAndJoin()
+}
+
+func TestNewWithClaimSmoothing_OnlyFallsBackToPreviousCurrent(t *testing.T) {
+	clock := newFakeClock()
+	loadCalled := 0
+	d, err := NewWithClaimSmoothing(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetClock(clock)
+
+	// v1 is held long after it's superseded
+	v1, _ := d.Claim()
+	_ = d.ReLoad()
+	clock.Advance(2 * ClaimSmoothingWindow)
+	v2, _ := d.Claim()
+	_ = d.ReLoad()
+
+	claimVersions := func() map[uint64]int {
+		versions := make(map[uint64]int)
+		claims := make([]ConfigClaim, 10)
+		for i := range claims {
+			claims[i], _ = d.Claim()
+			versions[claims[i].Version()]++
+		}
+		for i := range claims {
+			d.Release(&claims[i])
+		}
+		return versions
+	}
+	if versions := claimVersions(); versions[1] != 0 || versions[2] == 0 {
+		t.Error(`expected claims to fall back to v2 only, but got: `, versions)
+	}
+
+	// v2 is retired by the next reload, so there is nothing to fall back to
+	d.Release(&v2)
+	clock.Advance(2 * ClaimSmoothingWindow)
+	_ = d.ReLoad()
+	if versions := claimVersions(); versions[4] != 10 {
+		t.Error(`expected every claim on v4 rather than a stale version, but got: `, versions)
+	}
+	d.Release(&v1)
+	d.StopAndJoin()
+}
+
+func TestNewWithClaimSmoothing_InvalidRate(t *testing.T) {
+	load := func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}
+	if _, err := NewWithClaimSmoothing(load, nil, 0); err != ErrInvalidSmoothingRate {
+		t.Error(`expected ErrInvalidSmoothingRate, but got: `, err)
+	}
+	if _, err := NewBuilder(load).ClaimSmoothing(-1).Build(); err != ErrInvalidSmoothingRate {
+		t.Error(`expected the builder to return ErrInvalidSmoothingRate, but got: `, err)
+	}
+}
+
+// benchmarkReloadUnderClaims reloads, then has many go routines claim at once.
+// It reports how many of those claims landed on the new version, which is the
+// burst of load placed on the new version's resources
+func benchmarkReloadUnderClaims(b *testing.B, d *Drain) {
+	var wg sync.WaitGroup
+	var onNew uint64
+	var mu sync.Mutex
+	for i := 0; i < b.N; i++ {
+		_ = d.ReLoad()
+		d.mu.Lock()
+		latest := d.versionTracking.Back().Value.(*configVersion).version
+		d.mu.Unlock()
+		wg.Add(64)
+		for g := 0; g < 64; g++ {
+			go func() {
+				defer wg.Done()
+				cc, _ := d.Claim()
+				if cc.Version() == latest {
+					mu.Lock()
+					onNew++
+					mu.Unlock()
+				}
+				d.Release(&cc)
+			}()
+		}
+		wg.Wait()
+	}
+	b.ReportMetric(float64(onNew)/float64(b.N), `new-version-claims/op`)
+}
+
+func BenchmarkReLoad_ClaimStorm(b *testing.B) {
+	d, _ := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	held, _ := d.Claim()
+	defer d.Release(&held)
+	b.ResetTimer()
+	benchmarkReloadUnderClaims(b, d)
+}
+
+func BenchmarkReLoad_ClaimStormSmoothed(b *testing.B) {
+	d, _ := NewWithClaimSmoothing(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, 1000)
+	held, _ := d.Claim()
+	defer d.Release(&held)
+	b.ResetTimer()
+	benchmarkReloadUnderClaims(b, d)
+}
@@ -0,0 +1,83 @@
+package go_drain
+
+// CurrentConfig gets the current configuration without claiming it. This is a
+// momentary read that does not participate in the Drain's accounting, so the
+// configuration may be superseded by a ReLoad and closed at any time after this
+// returns. Only use it for values that remain safe after closing, such as plain
+// settings. Use Claim if you need the resources in the configuration to stay open
+// @return the current configuration or nil if there is none because the Drain is stopped
+func (d *Drain) CurrentConfig() interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.latestVersion()
+}
+
+// CurrentVersion gets the version that Claim currently hands out
+// @return the latest version or 0 if there is none because the Drain is stopped
+func (d *Drain) CurrentVersion() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e := d.versionTracking.Back(); e != nil && !d.isStopped {
+		return e.Value.(*configVersion).version
+	}
+	return 0
+}
+
+// Snapshot is the typed companion to CurrentConfig. It has the same safety
+// model: no claim is made, so the configuration may be swapped and closed
+// concurrently. It's intended for callers who accept that caveat and want no
+// Release bookkeeping
+// @param d is the Drain to read from
+// @return the current configuration as a T or the zero value of T
+// @return true if there was a current configuration and it is a T, false if not
+func Snapshot[T any](d *Drain) (T, bool) {
+	cfg, ok := d.CurrentConfig().(T)
+	return cfg, ok
+}
+
+// UpdateCurrentConfig runs fn on the current configuration in place, without
+// creating a new version or draining anything. Existing claims and new claims
+// see the change. fn runs while the Drain is locked, so it must be fast and
+// must not call back into the Drain.
+//
+// This is dangerous. It is only safe for mutations that claim holders can
+// observe concurrently, such as swapping an atomic pointer inside the
+// configuration. Never use it to replace anything that holds resources: the
+// replaced resource would never be closed. Use ReLoad for those
+// @param fn mutates the current configuration
+// @return ErrDrainAlreadyStopped if stopped, ErrNoConfigYet if nothing is loaded, nil otherwise
+func (d *Drain) UpdateCurrentConfig(fn func(config interface{})) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.isStopped {
+		return ErrDrainAlreadyStopped
+	}
+	e := d.versionTracking.Back()
+	if e == nil || e.Value.(*configVersion).config == nil {
+		return ErrNoConfigYet
+	}
+	fn(e.Value.(*configVersion).config)
+	return nil
+}
+
+// WithCurrent runs fn with the current configuration, guaranteeing that every
+// read fn makes comes from a single version. Two calls to CurrentConfig can
+// straddle a ReLoad and see fields from different versions, WithCurrent cannot.
+// The configuration also cannot be closed while fn runs. fn runs while the Drain
+// is locked, so it must be fast and must not call back into the Drain, or it
+// will deadlock. Use Claim for anything slow
+// @param fn reads the current configuration
+// @return ErrDrainAlreadyStopped if stopped, ErrNoConfigYet if nothing is loaded, nil otherwise
+func (d *Drain) WithCurrent(fn func(config interface{})) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.isStopped {
+		return ErrDrainAlreadyStopped
+	}
+	config := d.latestVersion()
+	if config == nil {
+		return ErrNoConfigYet
+	}
+	fn(config)
+	return nil
+}
@@ -0,0 +1,127 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	loadCalled := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, ok := Snapshot[*myConfig](d)
+	if !ok || cfg.name != `v1` {
+		t.Error(`expected snapshot of v1, but got: `, cfg, ok)
+	}
+
+	_ = d.ReLoad()
+	if d.CurrentVersion() != 2 {
+		t.Error(`expected current version 2, but got `, d.CurrentVersion())
+	}
+	cfg, ok = Snapshot[*myConfig](d)
+	if !ok || cfg.name != `v2` {
+		t.Error(`expected snapshot to reflect the reload, but got: `, cfg, ok)
+	}
+
+	if _, ok = Snapshot[*omniConfig](d); ok {
+		t.Error(`expected snapshot of the wrong type to fail`)
+	}
+
+	d.StopAndJoin()
+	if d.CurrentVersion() != 0 {
+		t.Error(`expected no current version after stopping, but got `, d.CurrentVersion())
+	}
+	if cfg, ok = Snapshot[*myConfig](d); ok || cfg != nil {
+		t.Error(`expected no snapshot after stopping, but got: `, cfg)
+	}
+}
+
+func TestDrain_UpdateCurrentConfig(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, _ := d.Claim()
+	if err = d.UpdateCurrentConfig(func(config interface{}) {
+		config.(*myConfig).name = `v1-updated`
+	}); err != nil {
+		t.Error(`expected the update to succeed, but got: `, err)
+	}
+	if held.Config().(*myConfig).name != `v1-updated` {
+		t.Error(`expected the existing claim to observe the update, but got: `, held.Config().(*myConfig).name)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig.(*myConfig).name != `v1-updated` {
+			t.Error(`expected a new claim to observe the update, but got: `, currentlyRunningConfig.(*myConfig).name)
+		}
+	})
+	if d.CurrentVersion() != 1 {
+		t.Error(`expected no new version, but got: `, d.CurrentVersion())
+	}
+	d.Release(&held)
+
+	d.StopAndJoin()
+	if err = d.UpdateCurrentConfig(func(config interface{}) {
+		t.Error(`expected fn not to be called on a stopped drain`)
+	}); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+}
+
+type pairedConfig struct {
+	a, b   int
+	closed bool
+}
+
+func TestDrain_WithCurrent(t *testing.T) {
+	loaded := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &pairedConfig{a: loaded, b: loaded}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		configToClose.(*pairedConfig).closed = true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			_ = d.ReLoad()
+		}
+	}()
+	for reloading := true; reloading; {
+		select {
+		case <-done:
+			reloading = false
+		default:
+		}
+		_ = d.WithCurrent(func(config interface{}) {
+			c := config.(*pairedConfig)
+			if c.a != c.b {
+				t.Error(`expected a consistent view, but got: `, c.a, c.b)
+			}
+			if c.closed {
+				t.Error(`expected the config to be open`)
+			}
+		})
+	}
+
+	d.StopAndJoin()
+	if err = d.WithCurrent(func(config interface{}) {
+		t.Error(`expected fn not to be called on a stopped drain`)
+	}); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+}
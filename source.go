@@ -0,0 +1,23 @@
+package go_drain
+
+// NewFromSource is New for configurations parsed from raw bytes, such as a
+// file or a stream. On each load, including the initial one, the bytes are read
+// from source and parsed into a new configuration. The source subpackage
+// provides sources for files and streams
+// @param source reads the raw configuration
+// @param parse builds and tests a configuration from the raw bytes
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when reading, parsing, or testing the config
+func NewFromSource(
+	source func() ([]byte, error),
+	parse func(raw []byte) (interface{}, error),
+	closer CloserFunc,
+) (c *Drain, err error) {
+	return New(func(currentlyRunningConfig interface{}) (newConfig interface{}, err error) {
+		raw, err := source()
+		if err != nil {
+			return nil, err
+		}
+		return parse(raw)
+	}, closer)
+}
@@ -0,0 +1,36 @@
+// Package source provides reusable ways to read the raw bytes of a
+// configuration for go_drain.NewFromSource
+package source
+
+import (
+	"io"
+	"os"
+)
+
+// FromFile reads the whole file at path on each call
+// @param path is the path of the file to read
+// @return a source that returns the content of the file, or the error encountered reading it
+func FromFile(path string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
+
+// FromReaderFunc reads a stream to its end on each call. The stream is closed
+// once read, even if reading fails
+// @param open opens a new stream, such as an HTTP response body, on each call
+// @return a source that returns the content of the stream, or the error encountered opening or reading it
+func FromReaderFunc(open func() (io.ReadCloser, error)) func() ([]byte, error) {
+	return func() (content []byte, err error) {
+		r, err := open()
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if closeErr := r.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		return io.ReadAll(r)
+	}
+}
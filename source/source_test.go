@@ -0,0 +1,65 @@
+package source
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `config.json`)
+	if err := os.WriteFile(path, []byte(`v1`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	read := FromFile(path)
+	if content, err := read(); err != nil || string(content) != `v1` {
+		t.Error(`expected the file content, but got: `, string(content), err)
+	}
+
+	// re-read on each call
+	if err := os.WriteFile(path, []byte(`v2`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := read(); err != nil || string(content) != `v2` {
+		t.Error(`expected the updated file content, but got: `, string(content), err)
+	}
+
+	if _, err := FromFile(filepath.Join(t.TempDir(), `missing`))(); !os.IsNotExist(err) {
+		t.Error(`expected a not exist error, but got: `, err)
+	}
+}
+
+type trackedReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *trackedReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestFromReaderFunc(t *testing.T) {
+	var opened *trackedReader
+	read := FromReaderFunc(func() (io.ReadCloser, error) {
+		opened = &trackedReader{Reader: strings.NewReader(`content`)}
+		return opened, nil
+	})
+	if content, err := read(); err != nil || string(content) != `content` {
+		t.Error(`expected the stream content, but got: `, string(content), err)
+	}
+	if !opened.closed {
+		t.Error(`expected the stream to be closed`)
+	}
+
+	errOpen := errors.New(`unavailable`)
+	_, err := FromReaderFunc(func() (io.ReadCloser, error) {
+		return nil, errOpen
+	})()
+	if err != errOpen {
+		t.Error(`expected the open error, but got: `, err)
+	}
+}
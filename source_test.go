@@ -0,0 +1,60 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNewFromSource(t *testing.T) {
+	errEmpty := errors.New(`empty config`)
+	content := `v1`
+	closed := make([]string, 0)
+	d, err := NewFromSource(func() ([]byte, error) {
+		return []byte(content), nil
+	}, func(raw []byte) (interface{}, error) {
+		if len(raw) == 0 {
+			return nil, errEmpty
+		}
+		return &myConfig{name: strings.TrimSpace(string(raw))}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		if configToClose != nil {
+			closed = append(closed, configToClose.(*myConfig).name)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content = ``
+	if err = d.ReLoad(); err != errEmpty {
+		t.Error(`expected the parse error, but got: `, err)
+	}
+	content = `v2`
+	if err = d.ReLoad(); err != nil {
+		t.Error(`expected the reload to succeed, but got: `, err)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig.(*myConfig).name != `v2` {
+			t.Error(`expected the re-read config, but got: `, currentlyRunningConfig.(*myConfig).name)
+		}
+	})
+	d.StopAndJoin()
+	if fmt.Sprint(closed) != `[v1 v2]` {
+		t.Error(`expected both configs to be closed, but got: `, closed)
+	}
+}
+
+func TestNewFromSource_ReadFails(t *testing.T) {
+	errRead := errors.New(`read failed`)
+	_, err := NewFromSource(func() ([]byte, error) {
+		return nil, errRead
+	}, func(raw []byte) (interface{}, error) {
+		t.Error(`expected parse not to be called`)
+		return nil, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != errRead {
+		t.Error(`expected the read error, but got: `, err)
+	}
+}
@@ -0,0 +1,44 @@
+package go_drain
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SetStaleClaimWindow counts claims that raced just ahead of a reload, for
+// auditing how much near-miss staleness a workload sees. A claim is stale when
+// a reload supersedes the version it got within window after the claim was
+// made, while the claim is still held. Claims of versions that were already
+// superseded when claimed, such as by ClaimVersion, are not stale. Stale claims are counted when they are
+// Released, see Stats. Only claims made while a window is set are counted
+// @param window is how soon after a claim a reload makes the claim stale. 0 to
+//   stop counting
+func (d *Drain) SetStaleClaimWindow(window time.Duration) {
+	if window < 0 {
+		window = 0
+	}
+	atomic.StoreInt64(&d.staleClaimWindow, int64(window))
+}
+
+// timesClaims is true if claims must record when they were made
+func (d *Drain) timesClaims() bool {
+	return d.claimSamples != nil || atomic.LoadInt64(&d.staleClaimWindow) > 0
+}
+
+// countStaleClaim counts cc in staleClaims if the version it got was
+// superseded within the stale claim window of cc being made
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) countStaleClaim(cc *ConfigClaim) {
+	window := time.Duration(atomic.LoadInt64(&d.staleClaimWindow))
+	d.mu.RLock()
+	var supersededAt time.Time
+	if e := d.findElementWithVersion(cc.version); e != nil {
+		supersededAt = e.Value.(*configVersion).supersededAt
+	}
+	d.mu.RUnlock()
+	// a claim made after the reload, such as by ClaimVersion, did not race it
+	if !supersededAt.IsZero() && !supersededAt.Before(cc.claimedAt) && supersededAt.Sub(cc.claimedAt) <= window {
+		atomic.AddUint64(&d.staleClaims, 1)
+	}
+}
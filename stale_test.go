@@ -0,0 +1,63 @@
+package go_drain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDrain_SetStaleClaimWindow(t *testing.T) {
+	clock := newFakeClock()
+	loaded := 0
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetClock(clock)
+	d.SetStaleClaimWindow(10 * time.Millisecond)
+
+	// claimed just ahead of the reload
+	held, _ := d.Claim()
+	clock.Advance(5 * time.Millisecond)
+	_ = d.ReLoad()
+	d.Release(&held)
+	if stale := d.Stats().StaleClaims; stale != 1 {
+		t.Error(`expected the claim that raced the reload to be stale, got `, stale)
+	}
+
+	// claimed well before the reload
+	held, _ = d.Claim()
+	clock.Advance(time.Second)
+	_ = d.ReLoad()
+	d.Release(&held)
+
+	// released before the reload
+	held, _ = d.Claim()
+	d.Release(&held)
+	_ = d.ReLoad()
+
+	// claimed after the reload, of the version it superseded
+	d.SetStaleClaimWindow(time.Millisecond)
+	pinned, _ := d.Claim()
+	clock.Advance(5 * time.Millisecond)
+	_ = d.ReLoad()
+	clock.Advance(5 * time.Millisecond)
+	late, err := d.ClaimVersion(pinned.Version())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Release(&late)
+	d.Release(&pinned)
+
+	// never superseded
+	held, _ = d.Claim()
+	d.Release(&held)
+
+	if stale := d.Stats().StaleClaims; stale != 1 {
+		t.Error(`expected only the claim that raced the reload to be stale, got `, stale)
+	}
+	d.StopAndJoin()
+}
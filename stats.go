@@ -0,0 +1,43 @@
+package go_drain
+
+import "sync/atomic"
+
+// Stats is a point-in-time snapshot of the Drain's counters
+type Stats struct {
+	// RejectedClaims is how many calls to Claim returned ErrDrainAlreadyStopped.
+	// A growing value after shutdown has begun indicates that clients, such as
+	// load balancers, are still routing traffic to a draining instance
+	RejectedClaims uint64
+
+	// WaitingClaims is how many go routines are parked in a blocking claim,
+	// such as waiting for a slot under a claim limit, see WaitingClaims
+	WaitingClaims int
+
+	// CurrentVersion is the version new claims get, 0 if there is none or the Drain is stopped
+	CurrentVersion uint64
+
+	// OutstandingClaims is how many claims have not been Released across all versions
+	OutstandingClaims uint64
+
+	// StaleClaims is how many claims got a version that was superseded within
+	// the stale claim window of being claimed, see SetStaleClaimWindow
+	StaleClaims uint64
+}
+
+// Stats gets a snapshot of the Drain's counters
+func (d *Drain) Stats() Stats {
+	return Stats{
+		RejectedClaims:    atomic.LoadUint64(&d.rejectedClaims),
+		WaitingClaims:     d.WaitingClaims(),
+		CurrentVersion:    d.CurrentVersion(),
+		OutstandingClaims: d.TotalOutstandingClaims(),
+		StaleClaims:       atomic.LoadUint64(&d.staleClaims),
+	}
+}
+
+// WaitingClaims counts the go routines currently parked in a blocking claim:
+// waiting for a slot on a Drain created with NewWithClaimLimit, or waiting for
+// ReLoadExclusive to swap versions. Useful for capacity planning and alerting
+func (d *Drain) WaitingClaims() int {
+	return int(atomic.LoadInt64(&d.waitingClaims))
+}
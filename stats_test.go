@@ -0,0 +1,58 @@
+package go_drain
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDrain_Stats_RejectedClaims(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc, _ := d.Claim()
+	d.Release(&cc)
+	if rejected := d.Stats().RejectedClaims; rejected != 0 {
+		t.Error(`expected no rejected claims while running, but got `, rejected)
+	}
+
+	d.StopAndJoin()
+	for i := 0; i < 3; i++ {
+		_, _ = d.Claim()
+	}
+	if rejected := d.Stats().RejectedClaims; rejected != 3 {
+		t.Error(`expected 3 rejected claims, but got `, rejected)
+	}
+}
+
+func TestDrain_WaitingClaims(t *testing.T) {
+	d := newLimitedDrain(t, 1)
+	held, _ := d.Claim()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cc, _ := d.Claim()
+			d.Release(&cc)
+		}()
+	}
+	for deadline := time.Now().Add(time.Second); d.WaitingClaims() != 3 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if waiting := d.Stats().WaitingClaims; waiting != 3 {
+		t.Error(`expected 3 parked claims, but got `, waiting)
+	}
+
+	d.Release(&held)
+	wg.Wait()
+	if waiting := d.WaitingClaims(); waiting != 0 {
+		t.Error(`expected no parked claims, but got `, waiting)
+	}
+	d.StopAndJoin()
+}
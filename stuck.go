@@ -0,0 +1,92 @@
+package go_drain
+
+import "time"
+
+// stuckChecksPerThreshold is how many times per threshold the stuck version
+// monitor checks, so a stuck version is reported within a quarter threshold of
+// becoming stuck
+const stuckChecksPerThreshold = 4
+
+// SetStuckVersionHandler sets the callback that is notified when a superseded
+// version has still not drained, because some of its claims have not been
+// Released, threshold after the reload that superseded it. In a long-running
+// process this usually means a leaked claim. A background go routine checks the
+// versions periodically and reports each stuck version once. It exits when the
+// Drain is stopped and is started again by Reset
+// @param threshold is how long a superseded version may take to drain
+// @param handler receives the stuck version and how long ago it was
+//   superseded. It's called from the background go routine. Pass nil to disable
+func (d *Drain) SetStuckVersionHandler(threshold time.Duration, handler func(version uint64, age time.Duration)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stuckThreshold = threshold
+	d.hooks.onStuckVersion = handler
+	d.restartStuckMonitor()
+}
+
+// restartStuckMonitor halts the stuck version monitor, if running, and starts a
+// new one if a handler is set and the Drain is running
+//
+// Assumes that the d.mu is locked
+func (d *Drain) restartStuckMonitor() {
+	if d.stuckHalt != nil {
+		close(d.stuckHalt)
+		d.stuckHalt = nil
+	}
+	if d.hooks.onStuckVersion == nil || d.stuckThreshold <= 0 || d.isStopped {
+		return
+	}
+	halt := make(chan struct{})
+	d.stuckHalt = halt
+	stopped := d.stopped
+	interval := d.stuckThreshold / stuckChecksPerThreshold
+	if interval <= 0 {
+		interval = d.stuckThreshold
+	}
+	ticks, stopTicks := d.newTicker(interval)
+	go func() {
+		defer stopTicks()
+		for {
+			select {
+			case <-ticks:
+				d.reportStuckVersions()
+			case <-halt:
+				return
+			case <-stopped:
+				return
+			}
+		}
+	}()
+}
+
+// reportStuckVersions notifies the handler set by SetStuckVersionHandler of
+// the superseded versions that became stuck since the last check
+//
+// Assumes that the d.mu is not locked
+func (d *Drain) reportStuckVersions() {
+	type stuckVersion struct {
+		version uint64
+		age     time.Duration
+	}
+	now := d.now()
+	stuck := make([]stuckVersion, 0)
+	d.mu.Lock()
+	onStuckVersion := d.hooks.onStuckVersion
+	for e := d.versionTracking.Front(); e != nil; e = e.Next() {
+		ccv := e.Value.(*configVersion)
+		if ccv.supersededAt.IsZero() || ccv.reportedStuck {
+			continue
+		}
+		if age := now.Sub(ccv.supersededAt); age >= d.stuckThreshold {
+			ccv.reportedStuck = true
+			stuck = append(stuck, stuckVersion{version: ccv.version, age: age})
+		}
+	}
+	d.mu.Unlock()
+	if onStuckVersion == nil {
+		return
+	}
+	for _, s := range stuck {
+		protect(CallbackSiteOnStuckVersion, func() { onStuckVersion(s.version, s.age) })
+	}
+}
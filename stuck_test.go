@@ -0,0 +1,41 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDrain_SetStuckVersionHandler(t *testing.T) {
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	stuck := make([]string, 0)
+	d.SetStuckVersionHandler(20*time.Millisecond, func(version uint64, age time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		if age < 20*time.Millisecond {
+			t.Error(`expected the version to have been superseded for the threshold, but got: `, age)
+		}
+		stuck = append(stuck, fmt.Sprint(version))
+	})
+
+	// version 1 is held, version 2 drains right away
+	held, _ := d.Claim()
+	_ = d.ReLoad()
+	_ = d.ReLoad()
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	if fmt.Sprint(stuck) != `[1]` {
+		t.Error(`expected only the held version to be reported once, but got: `, stuck)
+	}
+	mu.Unlock()
+
+	d.Release(&held)
+	d.StopAndJoin()
+}
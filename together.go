@@ -0,0 +1,121 @@
+package go_drain
+
+import (
+	"context"
+	"sort"
+)
+
+// preparedReload is a configuration built by ReLoadTogether that has not been published yet
+type preparedReload struct {
+	// d is the Drain the configuration was built for
+	d *Drain
+
+	// cv is the built configuration
+	cv configVersion
+
+	// unchanged is true if loadAndTester returned the current configuration, so there is nothing to publish
+	unchanged bool
+}
+
+// ReLoadTogether reloads interdependent Drains as a unit, such as a writer and
+// a reader pool that must agree on a schema. It's a two-phase reload: first,
+// every Drain builds and tests its new configuration. Only if all succeed are
+// the new versions published, all at once while every Drain is locked, so no
+// claim can see a mix of old and new versions. If any Drain fails to build, or
+// one is stopped or over its SetMaxConcurrentVersionResources cap by the time
+// of the swap, nothing is published and every built configuration is closed.
+//
+// A Drain whose loadAndTester returns its current configuration keeps it
+// without failing the others
+// @param drains are the Drains to reload. Duplicates are reloaded once
+// @return the first error encountered, in the order of drains, or nil if all swapped
+func ReLoadTogether(drains ...*Drain) (err error) {
+	drains = uniqueDrains(drains)
+	for _, d := range drains {
+		_ = d.freezeGate.enter(context.Background())
+		defer d.freezeGate.exit()
+	}
+	prepared := make([]preparedReload, 0, len(drains))
+	// prepare
+	for _, d := range drains {
+		d.mu.Lock()
+		stopped := d.isStopped
+		d.mu.Unlock()
+		if stopped {
+			err = ErrDrainAlreadyStopped
+		} else if err = d.checkReloadGate(); err == nil {
+			var p preparedReload
+			p.d = d
+			if p.cv, p.unchanged, err = d.doLoadAndTest(d.loadAndTester); err == nil {
+				prepared = append(prepared, p)
+			}
+		}
+		if err != nil {
+			d.reloadFailed(err)
+			closePrepared(prepared)
+			return
+		}
+	}
+
+	// commit, locking in a consistent order so concurrent calls cannot deadlock
+	locked := make([]*Drain, len(drains))
+	copy(locked, drains)
+	sort.Slice(locked, func(i, j int) bool { return locked[i].id < locked[j].id })
+	for _, d := range locked {
+		d.mu.Lock()
+	}
+	for _, p := range prepared {
+		if err = p.d.checkPublish(); err != nil {
+			for _, d := range locked {
+				d.mu.Unlock()
+			}
+			closePrepared(prepared)
+			if err == ErrTooManyVersions {
+				err = versionResourcesError(p.d.onExceedVersionResources)
+			}
+			p.d.reloadFailed(err)
+			return
+		}
+	}
+	finishes := make([]func(), 0, len(prepared))
+	for i := range prepared {
+		if !prepared[i].unchanged {
+			finishes = append(finishes, prepared[i].d.publish(&prepared[i].cv, prepared[i].d.lastToken, reloadOptions{}))
+		}
+	}
+	for _, d := range locked {
+		d.mu.Unlock()
+	}
+	for _, finish := range finishes {
+		finish()
+	}
+	return nil
+}
+
+// closePrepared closes the configurations built by ReLoadTogether that will not be published
+//
+// Assumes that no d.mu is locked
+func closePrepared(prepared []preparedReload) {
+	for _, p := range prepared {
+		if p.unchanged {
+			continue
+		}
+		p.d.mu.Lock()
+		latestVersion := p.d.latestVersion()
+		p.d.mu.Unlock()
+		p.d.closeConfig(p.cv.version, p.cv.config, latestVersion, CloseReasonReplaced)
+	}
+}
+
+// uniqueDrains removes duplicate and nil Drains, keeping the first of each
+func uniqueDrains(drains []*Drain) []*Drain {
+	seen := make(map[*Drain]bool, len(drains))
+	unique := make([]*Drain, 0, len(drains))
+	for _, d := range drains {
+		if d != nil && !seen[d] {
+			seen[d] = true
+			unique = append(unique, d)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,64 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestReLoadTogether(t *testing.T) {
+	newDrain := func(name string, fail *bool, closed *[]string) *Drain {
+		loadCalled := 0
+		d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+			loadCalled++
+			cfg := &myConfig{name: fmt.Sprintf(`%s%d`, name, loadCalled)}
+			if *fail {
+				return cfg, errors.New(`build failed`)
+			}
+			return cfg, nil
+		}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+			*closed = append(*closed, configToClose.(*myConfig).name)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	writerFail, readerFail := false, false
+	closed := make([]string, 0)
+	writer := newDrain(`w`, &writerFail, &closed)
+	reader := newDrain(`r`, &readerFail, &closed)
+
+	readerFail = true
+	if err := ReLoadTogether(writer, reader); err == nil {
+		t.Error(`expected the reader's build error`)
+	}
+	if writer.CurrentVersion() != 1 || reader.CurrentVersion() != 1 {
+		t.Error(`expected neither drain to swap, but got versions: `, writer.CurrentVersion(), reader.CurrentVersion())
+	}
+	if fmt.Sprint(closed) != `[r2 w2]` {
+		t.Error(`expected both built configs to be closed, but got: `, closed)
+	}
+
+	readerFail = false
+	closed = closed[:0]
+	if err := ReLoadTogether(writer, reader, writer); err != nil {
+		t.Error(`expected both drains to swap, but got: `, err)
+	}
+	if writer.CurrentVersion() != 2 || reader.CurrentVersion() != 2 {
+		t.Error(`expected both drains to swap once, but got versions: `, writer.CurrentVersion(), reader.CurrentVersion())
+	}
+	if fmt.Sprint(closed) != `[w1 r1]` {
+		t.Error(`expected the old configs to be closed, but got: `, closed)
+	}
+
+	reader.StopAndJoin()
+	closed = closed[:0]
+	if err := ReLoadTogether(writer, reader); err != ErrDrainAlreadyStopped {
+		t.Error(`expected a stopped drain to fail the reload, but got: `, err)
+	}
+	if writer.CurrentVersion() != 2 || fmt.Sprint(closed) != `[w4]` {
+		t.Error(`expected the writer not to swap, but got: `, writer.CurrentVersion(), closed)
+	}
+	writer.StopAndJoin()
+}
@@ -0,0 +1,52 @@
+package go_drain
+
+const (
+	// SpanReload is the name of the span started by ReLoad
+	SpanReload = `drain.reload`
+
+	// SpanClaim is the name of the span started by Claim
+	SpanClaim = `drain.claim`
+
+	// SpanStopAndJoin is the name of the span started by StopAndJoin
+	SpanStopAndJoin = `drain.stop_and_join`
+)
+
+// Tracer is a minimal tracing interface used by the Drain to report spans
+// around reloads, claims, and shutdown. This keeps tracing libraries, such as
+// OpenTelemetry, out of this package. Adapt your tracer to this interface to
+// get spans from the Drain
+type Tracer interface {
+	// StartSpan begins a span with the given name
+	// @param name is one of SpanReload, SpanClaim, or SpanStopAndJoin
+	// @return end is called exactly once when the operation completes with
+	//   the error returned by the operation, or nil if it succeeded
+	StartSpan(name string) (end func(err error))
+}
+
+// tracerHolder wraps the Tracer so that it can be stored in an atomic.Value,
+// which requires the same concrete type on every Store
+type tracerHolder struct {
+	tracer Tracer
+}
+
+// SetTracer installs a Tracer on the Drain. Pass nil to stop tracing
+// @param tracer receives spans for ReLoad, Claim, and StopAndJoin
+func (d *Drain) SetTracer(tracer Tracer) {
+	d.tracer.Store(tracerHolder{tracer: tracer})
+}
+
+// startSpan starts a span if a tracer is set
+// @return the function to end the span or nil if no tracer is set
+func (d *Drain) startSpan(name string) func(err error) {
+	h, ok := d.tracer.Load().(tracerHolder)
+	if !ok || h.tracer == nil {
+		return nil
+	}
+	var end func(err error)
+	if protect(CallbackSiteTracer, func() { end = h.tracer.StartSpan(name) }) || end == nil {
+		return nil
+	}
+	return func(err error) {
+		protect(CallbackSiteTracer, func() { end(err) })
+	}
+}
@@ -0,0 +1,85 @@
+package go_drain
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeSpan struct {
+	name  string
+	ended bool
+	err   error
+}
+
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (f *fakeTracer) StartSpan(name string) func(err error) {
+	s := &fakeSpan{name: name}
+	f.spans = append(f.spans, s)
+	return func(err error) {
+		s.ended = true
+		s.err = err
+	}
+}
+
+func TestDrain_SetTracer(t *testing.T) {
+	loadErr := errors.New(`load failed`)
+	failLoad := false
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		if failLoad {
+			return nil, loadErr
+		}
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := &fakeTracer{}
+	d.SetTracer(tracer)
+
+	cc, _ := d.Claim()
+	d.Release(&cc)
+	_ = d.ReLoad()
+	failLoad = true
+	_ = d.ReLoad()
+	d.StopAndJoin()
+	_, _ = d.Claim()
+
+	// reloads claim internally to pass in the current config
+	expected := []struct {
+		name string
+		err  error
+	}{
+		{SpanClaim, nil},
+		{SpanReload, nil},
+		{SpanClaim, nil},
+		{SpanReload, loadErr},
+		{SpanClaim, nil},
+		{SpanStopAndJoin, nil},
+		{SpanClaim, ErrDrainAlreadyStopped},
+	}
+	if len(tracer.spans) != len(expected) {
+		t.Fatal(`expected `, len(expected), ` spans, but got `, len(tracer.spans))
+	}
+	for i, e := range expected {
+		s := tracer.spans[i]
+		if s.name != e.name {
+			t.Errorf(`expected span %d to be "%s" but got "%s"`, i, e.name, s.name)
+		}
+		if !s.ended {
+			t.Errorf(`expected span %d to be ended`, i)
+		}
+		if s.err != e.err {
+			t.Errorf(`expected span %d to end with "%v" but got "%v"`, i, e.err, s.err)
+		}
+	}
+
+	// removing the tracer stops spans
+	d.SetTracer(nil)
+	_, _ = d.Claim()
+	if len(tracer.spans) != len(expected) {
+		t.Error(`expected no spans after removing the tracer`)
+	}
+}
@@ -0,0 +1,121 @@
+package go_drain
+
+import (
+	"bytes"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// CallerInfo describes the go routine that holds an outstanding claim
+type CallerInfo struct {
+	// GoroutineID is the id of the go routine that called Claim
+	GoroutineID uint64
+
+	// Version is the version of the configuration that was claimed
+	Version uint64
+
+	// TraceID is the trace id the claim was made with by ClaimWithTrace, or empty
+	TraceID string
+
+	// ClaimedAt is when the claim was made
+	ClaimedAt time.Time
+
+	// Age is how long the claim has been held at the time ActiveClaimCallers was called
+	Age time.Duration
+
+	// Stack is the stack trace of the go routine at the time Claim was called
+	Stack []byte
+
+	// pcs are the program counters of the call to Claim, captured for the hold watchdog
+	pcs []uintptr
+}
+
+// NewWithGoroutineTracking is New, but records the go routine and stack of every
+// Claim until it is Released. This is intended to debug StopAndJoin calls that
+// never return by listing who still holds claims with ActiveClaimCallers.
+// Capturing the stack on every Claim is expensive, do not use this in production
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithGoroutineTracking(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.trackGoroutines = true
+		d.claimCallers = make(map[uint64]CallerInfo)
+	})
+}
+
+// ActiveClaimCallers lists the callers of all outstanding claims, oldest first.
+// Only populated for Drains created with NewWithGoroutineTracking
+// @return the callers holding claims or empty if there are none or tracking is off
+func (d *Drain) ActiveClaimCallers() []CallerInfo {
+	now := d.now()
+	d.mu.Lock()
+	callers := make([]CallerInfo, 0, len(d.claimCallers))
+	ids := make([]uint64, 0, len(d.claimCallers))
+	for id := range d.claimCallers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		caller := d.claimCallers[id]
+		caller.Age = now.Sub(caller.ClaimedAt)
+		callers = append(callers, caller)
+	}
+	d.mu.Unlock()
+	return callers
+}
+
+// captureClaimCaller records what the Drain needs to know about the caller of a claim
+// @return the caller, which is empty if the Drain does not track callers or hold times
+func (d *Drain) captureClaimCaller() (caller CallerInfo) {
+	if d.trackGoroutines {
+		caller = captureCaller(d.now())
+	}
+	if d.holdThreshold > 0 {
+		caller.pcs = capturePCs()
+		if caller.ClaimedAt.IsZero() {
+			caller.ClaimedAt = d.now()
+		}
+	}
+	return
+}
+
+// captureCaller records the current go routine's id and stack
+// @param claimedAt is when the claim is being made
+func captureCaller(claimedAt time.Time) CallerInfo {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+	return CallerInfo{
+		GoroutineID: goroutineID(buf),
+		ClaimedAt:   claimedAt,
+		Stack:       buf,
+	}
+}
+
+// currentGoroutineID gets the id of the calling go routine
+func currentGoroutineID() uint64 {
+	var buf [64]byte
+	return goroutineID(buf[:runtime.Stack(buf[:], false)])
+}
+
+// goroutineID parses the go routine id from a stack trace that begins with "goroutine N ["
+// @return the id or 0 if it could not be parsed
+func goroutineID(stack []byte) uint64 {
+	stack = bytes.TrimPrefix(stack, []byte(`goroutine `))
+	if i := bytes.IndexByte(stack, ' '); i >= 0 {
+		stack = stack[:i]
+	}
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
@@ -0,0 +1,94 @@
+package go_drain
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestNewWithGoroutineTracking(t *testing.T) {
+	d, err := NewWithGoroutineTracking(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const holders = 3
+	claimed := sync.WaitGroup{}
+	claimed.Add(holders)
+	release := make(chan struct{})
+	done := sync.WaitGroup{}
+	done.Add(holders)
+	for i := 0; i < holders; i++ {
+		go func() {
+			defer done.Done()
+			cc, _ := d.Claim()
+			claimed.Done()
+			<-release
+			d.Release(&cc)
+		}()
+	}
+	claimed.Wait()
+
+	callers := d.ActiveClaimCallers()
+	if len(callers) != holders {
+		t.Fatal(`expected `, holders, ` callers, but got `, len(callers))
+	}
+	seen := make(map[uint64]bool)
+	for _, caller := range callers {
+		if caller.GoroutineID == 0 {
+			t.Error(`expected the go routine id to be captured`)
+		}
+		seen[caller.GoroutineID] = true
+		if caller.Version != 1 {
+			t.Error(`expected version 1, but got `, caller.Version)
+		}
+		if caller.Age < 0 || caller.ClaimedAt.IsZero() {
+			t.Error(`expected the claim age to be recorded`)
+		}
+		if !bytes.Contains(caller.Stack, []byte(`TestNewWithGoroutineTracking`)) {
+			t.Error(`expected the stack to include the claiming function, but got: `, string(caller.Stack))
+		}
+	}
+	if len(seen) != holders {
+		t.Error(`expected distinct go routines, but got: `, seen)
+	}
+
+	close(release)
+	done.Wait()
+	if callers = d.ActiveClaimCallers(); len(callers) != 0 {
+		t.Error(`expected no callers after release, but got `, len(callers))
+	}
+	d.StopAndJoin()
+}
+
+func TestDrain_ClaimWithTrace(t *testing.T) {
+	d, err := NewWithGoroutineTracking(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc, err := d.ClaimWithTrace(`trace-1234`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.TraceID() != `trace-1234` {
+		t.Error(`expected the trace id on the claim, but got: `, cc.TraceID())
+	}
+	callers := d.ActiveClaimCallers()
+	if len(callers) != 1 || callers[0].TraceID != `trace-1234` {
+		t.Error(`expected the trace id in the outstanding claims, but got: `, callers)
+	}
+	d.Release(&cc)
+	if cc.TraceID() != `` {
+		t.Error(`expected the trace id to be cleared on release`)
+	}
+
+	d.StopAndJoin()
+	if cc, err = d.ClaimWithTrace(`trace-5678`); err != ErrDrainAlreadyStopped || cc.TraceID() != `` {
+		t.Error(`expected an invalid claim after stopping, but got: `, cc, err)
+	}
+}
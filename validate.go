@@ -0,0 +1,116 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidQuorum is returned by NewWithValidators when required is not between 1 and the number of validators
+var ErrInvalidQuorum = errors.New(`required must be between 1 and the number of validators`)
+
+// Validator is implemented by configurations that can check themselves. This
+// lets validation logic live on the configuration type rather than in each
+// LoadAndTesterFunc
+type Validator interface {
+	// Validate checks the configuration
+	// @return nil if the configuration is valid, the reason it is not if invalid
+	Validate() error
+}
+
+// NewSelfValidating is New, but each configuration built by loadAndTest that
+// implements Validator is validated before it is published. A validation error
+// is treated like a loadAndTest error: the swap is declined and the built
+// configuration is closed. Configurations that do not implement Validator are
+// published as usual
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading, testing, or validating the config
+func NewSelfValidating(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.validate = selfValidate
+	})
+}
+
+// selfValidate validates config if it implements Validator
+func selfValidate(config interface{}) error {
+	if v, ok := config.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// QuorumError is returned when fewer validators passed than NewWithValidators requires
+type QuorumError struct {
+	// Required is the number of validators that must pass
+	Required int
+
+	// Passed is the number of validators that passed
+	Passed int
+
+	// Failures are the errors of the validators that failed, by their index in the validators given to NewWithValidators
+	Failures map[int]error
+}
+
+// Error lists the validators that failed, in order
+func (e *QuorumError) Error() string {
+	failed := make([]string, 0, len(e.Failures))
+	for i := 0; len(failed) < len(e.Failures); i++ {
+		if err, ok := e.Failures[i]; ok {
+			failed = append(failed, fmt.Sprintf(`validator %d: %v`, i, err))
+		}
+	}
+	return fmt.Sprintf(`%d of %d required validators passed: %s`, e.Passed, e.Required, strings.Join(failed, `; `))
+}
+
+// NewWithValidators is New, but each configuration built by loadAndTest must
+// pass at least required of the validators before it is published, such as a
+// schema check, a connectivity check, and a policy check. Every validator is
+// run, so the error describes all that failed. Falling short of the quorum is
+// treated like a loadAndTest error: the swap is declined and the built
+// configuration is closed
+// @param validators check the built configuration, returning nil if it passes
+// @param required is how many validators must pass. Use len(validators) to require all of them
+// @return c the Drain object or nil, if there was an error
+// @return err ErrInvalidQuorum if required is out of range, a *QuorumError if
+//   the initial configuration fell short of the quorum, or any errors
+//   encountered when loading or testing the config
+func NewWithValidators(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	validators []func(config interface{}) error,
+	required int,
+) (c *Drain, err error) {
+	if required < 1 || required > len(validators) {
+		return nil, ErrInvalidQuorum
+	}
+	validators = append([]func(config interface{}) error(nil), validators...)
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.validate = func(config interface{}) error {
+			return runQuorum(config, validators, required)
+		}
+	})
+}
+
+// runQuorum runs every validator against config
+// @return nil if at least required passed, a *QuorumError otherwise
+func runQuorum(config interface{}, validators []func(config interface{}) error, required int) error {
+	qErr := &QuorumError{Required: required, Failures: make(map[int]error)}
+	for i, validator := range validators {
+		var err error
+		if protect(CallbackSiteValidator, func() { err = validator(config) }) {
+			err = ErrCallbackPanicked
+		}
+		if err != nil {
+			qErr.Failures[i] = err
+		} else {
+			qErr.Passed++
+		}
+	}
+	if qErr.Passed < required {
+		return qErr
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var errNoPort = errors.New(`port is required`)
+
+type validatingConfig struct {
+	name string
+	port int
+}
+
+func (c *validatingConfig) Validate() error {
+	if c.port == 0 {
+		return errNoPort
+	}
+	return nil
+}
+
+func TestNewSelfValidating(t *testing.T) {
+	next := validatingConfig{name: `v1`, port: 80}
+	closed := make([]string, 0)
+	d, err := NewSelfValidating(func(currentConfig interface{}) (config interface{}, err error) {
+		x := next
+		return &x, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*validatingConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next = validatingConfig{name: `v2`}
+	if err = d.ReLoad(); err != errNoPort {
+		t.Error(`expected the validation error, but got: `, err)
+	}
+	if fmt.Sprint(closed) != `[v2]` {
+		t.Error(`expected the invalid config to be closed, but got: `, closed)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig.(*validatingConfig).name != `v1` {
+			t.Error(`expected the old version to stay active, but got: `, currentlyRunningConfig.(*validatingConfig).name)
+		}
+	})
+
+	next = validatingConfig{name: `v3`, port: 8080}
+	if err = d.ReLoad(); err != nil {
+		t.Error(`expected the valid config to load, but got: `, err)
+	}
+	d.StopAndJoin()
+}
+
+func TestNewSelfValidating_InitialLoadFails(t *testing.T) {
+	_, err := NewSelfValidating(func(currentConfig interface{}) (config interface{}, err error) {
+		return &validatingConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != errNoPort {
+		t.Error(`expected the validation error, but got: `, err)
+	}
+}
+
+func TestNewSelfValidating_NotAValidator(t *testing.T) {
+	d, err := NewSelfValidating(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.StopAndJoin()
+}
+
+func TestNewWithValidators(t *testing.T) {
+	next := validatingConfig{name: `v1`, port: 80}
+	closed := make([]string, 0)
+	errPolicy := errors.New(`policy denies port`)
+	validators := []func(config interface{}) error{
+		func(config interface{}) error {
+			return config.(*validatingConfig).Validate()
+		},
+		func(config interface{}) error {
+			if config.(*validatingConfig).name == `` {
+				return errors.New(`name is required`)
+			}
+			return nil
+		},
+		func(config interface{}) error {
+			if config.(*validatingConfig).port == 22 {
+				return errPolicy
+			}
+			return nil
+		},
+	}
+	d, err := NewWithValidators(func(currentConfig interface{}) (config interface{}, err error) {
+		x := next
+		return &x, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*validatingConfig).name)
+	}, validators, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next = validatingConfig{name: `v2`, port: 22}
+	err = d.ReLoad()
+	qErr, ok := err.(*QuorumError)
+	if !ok {
+		t.Fatal(`expected a QuorumError, but got: `, err)
+	}
+	if qErr.Passed != 2 || len(qErr.Failures) != 1 || qErr.Failures[2] != errPolicy {
+		t.Error(`expected only the policy validator to fail, but got: `, qErr)
+	}
+	if qErr.Error() != `2 of 3 required validators passed: validator 2: policy denies port` {
+		t.Error(`expected the error to list the failed validator, but got: `, qErr.Error())
+	}
+	if fmt.Sprint(closed) != `[v2]` {
+		t.Error(`expected the rejected config to be closed, but got: `, closed)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig.(*validatingConfig).name != `v1` {
+			t.Error(`expected the old version to stay active, but got: `, currentlyRunningConfig.(*validatingConfig).name)
+		}
+	})
+	d.StopAndJoin()
+}
+
+func TestNewWithValidators_Quorum(t *testing.T) {
+	validators := []func(config interface{}) error{
+		func(config interface{}) error { return nil },
+		func(config interface{}) error { return errNoPort },
+		func(config interface{}) error { return nil },
+	}
+	d, err := NewWithValidators(func(currentConfig interface{}) (config interface{}, err error) {
+		return &validatingConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, validators, 2)
+	if err != nil {
+		t.Error(`expected 2 of 3 validators to meet the quorum, but got: `, err)
+	} else {
+		d.StopAndJoin()
+	}
+
+	_, err = NewWithValidators(func(currentConfig interface{}) (config interface{}, err error) {
+		return &validatingConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, validators, 4)
+	if err != ErrInvalidQuorum {
+		t.Error(`expected an unreachable quorum to be rejected, but got: `, err)
+	}
+}
@@ -0,0 +1,44 @@
+package go_drain
+
+// WarmupFunc prepares a configuration that has loaded and tested successfully
+// to serve traffic, such as by priming caches or filling connection pools. This
+// separates "valid" from "ready to serve"
+// @param config is the configuration to warm up. This will always be non-nil
+// @return nil if the configuration is ready, the error encountered if not
+type WarmupFunc func(config interface{}) error
+
+// NewWithWarmup is New, but each new configuration is warmed up after it loads
+// and tests, and before it is published. The reload sequence is build, test,
+// warmup, then publish. If warmup returns an error, the swap is declined and the
+// built configuration is closed, just as if loadAndTest had failed
+// @param warmup is called on each newly built configuration
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading, testing, or warming up the config
+func NewWithWarmup(
+	loadAndTest LoadAndTesterFunc,
+	warmup WarmupFunc,
+	closer CloserFunc,
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.warmup = warmup
+	})
+}
+
+// Warmup re-runs the warmup function on the current configuration on demand.
+// The current configuration is claimed for the duration of the warmup
+// @return ErrDrainAlreadyStopped if stopped, the error returned by the warmup
+//   function, or nil if there is no warmup function
+func (d *Drain) Warmup() (err error) {
+	if d.warmup == nil {
+		return nil
+	}
+	claimErr := d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if protect(CallbackSiteWarmup, func() { err = d.warmup(currentlyRunningConfig) }) {
+			err = ErrCallbackPanicked
+		}
+	})
+	if claimErr != nil {
+		return claimErr
+	}
+	return
+}
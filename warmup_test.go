@@ -0,0 +1,60 @@
+package go_drain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewWithWarmup(t *testing.T) {
+	errNotReady := errors.New(`not ready`)
+	loadCalled := 0
+	warmed := make([]string, 0)
+	closed := make([]string, 0)
+	failWarmup := false
+	d, err := NewWithWarmup(func(currentConfig interface{}) (config interface{}, err error) {
+		loadCalled++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loadCalled)}, nil
+	}, func(config interface{}) error {
+		warmed = append(warmed, config.(*myConfig).name)
+		if failWarmup {
+			return errNotReady
+		}
+		return nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		closed = append(closed, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failWarmup = true
+	if err = d.ReLoad(); err != errNotReady {
+		t.Error(`expected the warmup error, but got: `, err)
+	}
+	if fmt.Sprint(closed) != `[v2]` {
+		t.Error(`expected the cold config to be closed, but got: `, closed)
+	}
+	_ = d.ClaimRelease(func(currentlyRunningConfig interface{}) {
+		if currentlyRunningConfig.(*myConfig).name != `v1` {
+			t.Error(`expected the old version to stay active, but got: `, currentlyRunningConfig.(*myConfig).name)
+		}
+	})
+
+	// re-warm the current config
+	if err = d.Warmup(); err != errNotReady {
+		t.Error(`expected the warmup error, but got: `, err)
+	}
+	failWarmup = false
+	if err = d.Warmup(); err != nil {
+		t.Error(`expected warmup to succeed, but got: `, err)
+	}
+	if fmt.Sprint(warmed) != `[v1 v2 v1 v1]` {
+		t.Error(`expected warmups of v1, v2, v1, v1, but got: `, warmed)
+	}
+
+	d.StopAndJoin()
+	if err = d.Warmup(); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+}
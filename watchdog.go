@@ -0,0 +1,88 @@
+package go_drain
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// NewWithHoldWatchdog is New, but reports claims that are held longer than
+// threshold. onLongHold is called once per such claim with the stack trace of
+// the call to Claim, then the claim is left alone. This is purely for
+// observability, it does not change how claims are served or released.
+//
+// Only the program counters are recorded when claiming, which is cheap. The
+// stack trace is only built for claims that exceed the threshold
+// @param threshold is how long a claim may be held before it is reported
+// @param onLongHold receives the claimed version, how long it has been held,
+//   and the stack trace of the call to Claim. It's called from its own go routine
+// @return c the Drain object or nil, if there was an error
+// @return err any errors encountered when loading or testing the config
+func NewWithHoldWatchdog(
+	loadAndTest LoadAndTesterFunc,
+	closer CloserFunc,
+	threshold time.Duration,
+	onLongHold func(version uint64, age time.Duration, stack []byte),
+) (c *Drain, err error) {
+	return newDrain(loadAndTest, closer, func(d *Drain) {
+		d.holdThreshold = threshold
+		d.onLongHold = onLongHold
+		d.holdWatches = make(map[uint64]Timer)
+	})
+}
+
+// maxClaimStackDepth is how many frames of the call to Claim the hold watchdog records
+const maxClaimStackDepth = 32
+
+// capturePCs records the program counters of the calling go routine
+func capturePCs() []uintptr {
+	pcs := make([]uintptr, maxClaimStackDepth)
+	// skip runtime.Callers and capturePCs
+	return pcs[:runtime.Callers(2, pcs)]
+}
+
+// watchHold starts the watchdog for a claim
+//
+// Assumes that the d.mu is locked
+func (d *Drain) watchHold(id uint64, caller CallerInfo) {
+	var timer Timer
+	timer = d.clock().AfterFunc(d.holdThreshold, func() {
+		d.mu.Lock()
+		if d.holdWatches[id] != timer {
+			// released
+			d.mu.Unlock()
+			return
+		}
+		delete(d.holdWatches, id)
+		d.mu.Unlock()
+		stack := formatStack(caller.pcs)
+		protect(CallbackSiteOnLongHold, func() { d.onLongHold(caller.Version, d.since(caller.ClaimedAt), stack) })
+	})
+	d.holdWatches[id] = timer
+}
+
+// unwatchHold stops the watchdog for a released claim, if any
+//
+// Assumes that the d.mu is locked
+func (d *Drain) unwatchHold(id uint64) {
+	if timer, ok := d.holdWatches[id]; ok {
+		timer.Stop()
+		delete(d.holdWatches, id)
+	}
+}
+
+// formatStack renders program counters as a stack trace, one function per
+// frame followed by its file and line
+func formatStack(pcs []uintptr) []byte {
+	var buf bytes.Buffer
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&buf, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return buf.Bytes()
+}
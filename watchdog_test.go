@@ -0,0 +1,49 @@
+package go_drain
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewWithHoldWatchdog(t *testing.T) {
+	var mu sync.Mutex
+	type longHold struct {
+		version uint64
+		age     time.Duration
+		stack   []byte
+	}
+	holds := make([]longHold, 0)
+	d, err := NewWithHoldWatchdog(func(currentConfig interface{}) (config interface{}, err error) {
+		return &myConfig{name: `v1`}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {}, 30*time.Millisecond, func(version uint64, age time.Duration, stack []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		holds = append(holds, longHold{version: version, age: age, stack: stack})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// released in time, never reported
+	quick, _ := d.Claim()
+	d.Release(&quick)
+
+	held, _ := d.Claim()
+	time.Sleep(100 * time.Millisecond)
+	d.Release(&held)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(holds) != 1 {
+		t.Fatal(`expected exactly one long hold to be reported, but got: `, len(holds))
+	}
+	if holds[0].version != 1 || holds[0].age < 30*time.Millisecond {
+		t.Error(`expected the long hold of version 1, but got: `, holds[0].version, holds[0].age)
+	}
+	if !bytes.Contains(holds[0].stack, []byte(`TestNewWithHoldWatchdog`)) {
+		t.Error(`expected the stack of the call to Claim, but got: `, string(holds[0].stack))
+	}
+	d.StopAndJoin()
+}
@@ -0,0 +1,155 @@
+package go_drain
+
+import (
+	"container/list"
+	"errors"
+)
+
+// ErrInvalidWeights is returned by SetActiveWeights when no weights are given or any weight is not positive
+var ErrInvalidWeights = errors.New(`weights must be positive and non-empty`)
+
+// SetActiveWeights serves several versions at once, such as for a blue/green
+// rollout. Claims are distributed among the weighted versions by smooth
+// weighted round-robin, so a version with weight 3 gets 3 of every 4 claims
+// when the other has weight 1. Call it again to shift weight over time, and
+// PromoteSingle to collapse back to serving one version.
+//
+// While weights are set, a version is only retired once it is both unweighted
+// and unclaimed. ReLoad still appends new versions, but they receive no claims
+// until they are weighted, so a typical rollout sets the weight of the current
+// version, reloads, then shifts weight to the new version
+// @param weights are the claim weights by version
+// @return ErrDrainAlreadyStopped if stopped, ErrVersionNotAvailable if any version
+//   was retired or never existed, ErrInvalidWeights if weights is empty or any
+//   weight is not positive, nil otherwise
+func (d *Drain) SetActiveWeights(weights map[uint64]int) error {
+	if len(weights) == 0 {
+		return ErrInvalidWeights
+	}
+	for _, weight := range weights {
+		if weight <= 0 {
+			return ErrInvalidWeights
+		}
+	}
+	d.mu.Lock()
+	if d.isStopped {
+		d.mu.Unlock()
+		return ErrDrainAlreadyStopped
+	}
+	for version := range weights {
+		if d.findElementWithVersion(version) == nil {
+			d.mu.Unlock()
+			return ErrVersionNotAvailable
+		}
+	}
+	d.activeWeights = make(map[uint64]int, len(weights))
+	for version, weight := range weights {
+		d.activeWeights[version] = weight
+	}
+	d.activeCurrent = make(map[uint64]int, len(weights))
+	d.retireIdleVersions()
+	return nil
+}
+
+// PromoteSingle collapses weighted serving back to a single version. All claims
+// go to version afterwards, and every other version is retired once its claims
+// are Released. If version is the latest, the Drain returns to its normal
+// behavior, otherwise version stays pinned until the next SetActiveWeights or
+// PromoteSingle
+// @param version is the version to serve
+// @return ErrDrainAlreadyStopped if stopped, ErrVersionNotAvailable if the
+//   version was retired or never existed, nil otherwise
+func (d *Drain) PromoteSingle(version uint64) error {
+	d.mu.Lock()
+	if d.isStopped {
+		d.mu.Unlock()
+		return ErrDrainAlreadyStopped
+	}
+	if d.findElementWithVersion(version) == nil {
+		d.mu.Unlock()
+		return ErrVersionNotAvailable
+	}
+	if back := d.versionTracking.Back(); back.Value.(*configVersion).version == version {
+		d.activeWeights = nil
+		d.activeCurrent = nil
+	} else {
+		d.activeWeights = map[uint64]int{version: 1}
+		d.activeCurrent = map[uint64]int{}
+	}
+	d.retireIdleVersions()
+	return nil
+}
+
+// ActiveWeights gets the weights set by SetActiveWeights
+// @return the weights by version, or nil if a single version is served
+func (d *Drain) ActiveWeights() map[uint64]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.activeWeights == nil {
+		return nil
+	}
+	weights := make(map[uint64]int, len(d.activeWeights))
+	for version, weight := range d.activeWeights {
+		weights[version] = weight
+	}
+	return weights
+}
+
+// isWeighted is true if the version is served by SetActiveWeights
+//
+// Assumes that the d.mu is locked
+func (d *Drain) isWeighted(version uint64) bool {
+	_, ok := d.activeWeights[version]
+	return ok
+}
+
+// pickWeighted picks the version the next claim gets by smooth weighted
+// round-robin. Ties go to the oldest version
+// @return the element of the picked version
+//
+// Assumes that the d.mu is locked
+func (d *Drain) pickWeighted() *list.Element {
+	var picked *list.Element
+	total := 0
+	for e := d.versionTracking.Front(); e != nil; e = e.Next() {
+		version := e.Value.(*configVersion).version
+		weight, ok := d.activeWeights[version]
+		if !ok {
+			continue
+		}
+		total += weight
+		d.activeCurrent[version] += weight
+		if picked == nil || d.activeCurrent[version] > d.activeCurrent[picked.Value.(*configVersion).version] {
+			picked = e
+		}
+	}
+	d.activeCurrent[picked.Value.(*configVersion).version] -= total
+	return picked
+}
+
+// retireIdleVersions removes the versions that should be cleaned up, other than
+// the latest, then unlocks and closes them. Versions that still have claims are
+// left to be closed by Release
+//
+// Assumes that the d.mu is locked, unlocks it
+func (d *Drain) retireIdleVersions() {
+	toClose := make([]*configVersion, 0)
+	back := d.versionTracking.Back()
+	for e := d.versionTracking.Front(); e != nil && e != back; {
+		next := e.Next()
+		if ccv := e.Value.(*configVersion); d.shouldCleanup(*ccv) {
+			ccv.stopLinger()
+			d.versionTracking.Remove(e)
+			toClose = append(toClose, ccv)
+		}
+		e = next
+	}
+	latestVersion := d.latestVersion()
+	d.mu.Unlock()
+
+	// unlock while calling closer, could be long
+	for _, ccv := range toClose {
+		d.drainCompleted(ccv)
+		d.closeConfig(ccv.version, ccv.config, latestVersion, CloseReasonReplaced)
+	}
+}
@@ -0,0 +1,135 @@
+package go_drain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func newWeightedTestDrain(t *testing.T) (d *Drain, closed func() []string) {
+	mu := sync.Mutex{}
+	loaded := 0
+	closedNames := make([]string, 0)
+	d, err := New(func(currentConfig interface{}) (config interface{}, err error) {
+		loaded++
+		return &myConfig{name: fmt.Sprintf(`v%d`, loaded)}, nil
+	}, func(configToClose interface{}, currentlyRunningConfig interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		closedNames = append(closedNames, configToClose.(*myConfig).name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, closedNames...)
+	}
+}
+
+func claimCounts(d *Drain, claims int) map[uint64]int {
+	counts := make(map[uint64]int)
+	for i := 0; i < claims; i++ {
+		cc, _ := d.Claim()
+		counts[cc.Version()]++
+		d.Release(&cc)
+	}
+	return counts
+}
+
+func TestDrain_SetActiveWeights(t *testing.T) {
+	d, closed := newWeightedTestDrain(t)
+
+	// pin v1 so that the reload does not retire it
+	if err := d.SetActiveWeights(map[uint64]int{1: 1}); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.ReLoad()
+	if counts := claimCounts(d, 10); counts[1] != 10 {
+		t.Error(`expected unweighted v2 to get no claims, but got: `, counts)
+	}
+	if len(closed()) != 0 {
+		t.Error(`expected weighted v1 to survive the reload, but closed: `, closed())
+	}
+
+	if err := d.SetActiveWeights(map[uint64]int{1: 3, 2: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if counts := claimCounts(d, 400); counts[1] != 300 || counts[2] != 100 {
+		t.Error(`expected a 3:1 distribution, but got: `, counts)
+	}
+
+	// a held claim keeps v1 open after it's unweighted
+	held, _ := d.ClaimVersion(1)
+	if err := d.PromoteSingle(2); err != nil {
+		t.Fatal(err)
+	}
+	if d.ActiveWeights() != nil {
+		t.Error(`expected promoting the latest version to clear the weights, but got: `, d.ActiveWeights())
+	}
+	if len(closed()) != 0 {
+		t.Error(`expected claimed v1 to stay open, but closed: `, closed())
+	}
+	d.Release(&held)
+	if fmt.Sprint(closed()) != `[v1]` {
+		t.Error(`expected v1 to retire once unweighted and unclaimed, but closed: `, closed())
+	}
+	if counts := claimCounts(d, 10); counts[2] != 10 {
+		t.Error(`expected all claims on v2, but got: `, counts)
+	}
+
+	// back to normal, reloading retires v2
+	_ = d.ReLoad()
+	if fmt.Sprint(closed()) != `[v1 v2]` {
+		t.Error(`expected v2 to be replaced, but closed: `, closed())
+	}
+	d.StopAndJoin()
+	assertBalanced(t, d)
+}
+
+func TestDrain_PromoteSingle_Older(t *testing.T) {
+	d, closed := newWeightedTestDrain(t)
+	_ = d.SetActiveWeights(map[uint64]int{1: 1})
+	_ = d.ReLoad()
+	_ = d.SetActiveWeights(map[uint64]int{1: 1, 2: 1})
+
+	// roll back to v1, v2 is the latest so it's kept, but gets no claims
+	if err := d.PromoteSingle(1); err != nil {
+		t.Fatal(err)
+	}
+	if counts := claimCounts(d, 10); counts[1] != 10 {
+		t.Error(`expected all claims on v1, but got: `, counts)
+	}
+	_ = d.ReLoad()
+	if fmt.Sprint(closed()) != `[v2]` {
+		t.Error(`expected v2 to retire once superseded, but closed: `, closed())
+	}
+	d.StopAndJoin()
+	if fmt.Sprint(closed()) != `[v2 v1 v3]` {
+		t.Error(`expected every version to be closed on stop, but closed: `, closed())
+	}
+}
+
+func TestDrain_SetActiveWeights_Errors(t *testing.T) {
+	d, _ := newWeightedTestDrain(t)
+	if err := d.SetActiveWeights(nil); err != ErrInvalidWeights {
+		t.Error(`expected ErrInvalidWeights, but got: `, err)
+	}
+	if err := d.SetActiveWeights(map[uint64]int{1: 0}); err != ErrInvalidWeights {
+		t.Error(`expected ErrInvalidWeights, but got: `, err)
+	}
+	if err := d.SetActiveWeights(map[uint64]int{7: 1}); err != ErrVersionNotAvailable {
+		t.Error(`expected ErrVersionNotAvailable, but got: `, err)
+	}
+	if err := d.PromoteSingle(7); err != ErrVersionNotAvailable {
+		t.Error(`expected ErrVersionNotAvailable, but got: `, err)
+	}
+	d.StopAndJoin()
+	if err := d.SetActiveWeights(map[uint64]int{1: 1}); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+	if err := d.PromoteSingle(1); err != ErrDrainAlreadyStopped {
+		t.Error(`expected ErrDrainAlreadyStopped, but got: `, err)
+	}
+}